@@ -17,22 +17,48 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog/v2"
 
+	"github.com/caicloud/event_exporter/pkg/anomaly"
 	"github.com/caicloud/event_exporter/pkg/collector"
+	"github.com/caicloud/event_exporter/pkg/config"
+	"github.com/caicloud/event_exporter/pkg/detectors"
 	_ "github.com/caicloud/event_exporter/pkg/exporter"
+	"github.com/caicloud/event_exporter/pkg/filters"
+	"github.com/caicloud/event_exporter/pkg/incidents"
+	"github.com/caicloud/event_exporter/pkg/notify"
 	"github.com/caicloud/event_exporter/pkg/options"
+	"github.com/caicloud/event_exporter/pkg/problems"
+	"github.com/caicloud/event_exporter/pkg/pushgateway"
+	"github.com/caicloud/event_exporter/pkg/redact"
+	"github.com/caicloud/event_exporter/pkg/remotewrite"
+	"github.com/caicloud/event_exporter/pkg/severity"
 	"github.com/caicloud/event_exporter/pkg/signal"
+	"github.com/caicloud/event_exporter/pkg/sinks"
+	_ "github.com/caicloud/event_exporter/pkg/sinks/alertmanager"
+	_ "github.com/caicloud/event_exporter/pkg/sinks/chat"
+	_ "github.com/caicloud/event_exporter/pkg/sinks/elasticsearch"
+	_ "github.com/caicloud/event_exporter/pkg/sinks/jsonlog"
+	_ "github.com/caicloud/event_exporter/pkg/sinks/kafka"
+	_ "github.com/caicloud/event_exporter/pkg/sinks/loki"
+	_ "github.com/caicloud/event_exporter/pkg/sinks/otlp"
+	_ "github.com/caicloud/event_exporter/pkg/sinks/statsd"
+	_ "github.com/caicloud/event_exporter/pkg/sinks/syslog"
+	_ "github.com/caicloud/event_exporter/pkg/sinks/webhook"
+	"github.com/caicloud/event_exporter/pkg/topn"
 	"github.com/caicloud/event_exporter/pkg/version"
+	"github.com/caicloud/event_exporter/pkg/workload"
 )
 
 const (
@@ -50,6 +76,14 @@ func main() {
 		os.Exit(0)
 	}
 
+	cfg := &config.Config{}
+	if opts.ConfigPath != "" {
+		var err error
+		if cfg, err = config.Load(opts.ConfigPath); err != nil {
+			klog.Fatalf("failed to load configuration file,err:%s", err.Error())
+		}
+	}
+
 	group, stopChan := signal.SetupStopSignalContext()
 
 	kubeConfig, err := clientcmd.BuildConfigFromFlags(opts.KubeMasterURL, opts.KubeConfigPath)
@@ -61,8 +95,131 @@ func main() {
 		klog.Fatalf("failed to build kubernetes client,err:%s", err.Error())
 	}
 
+	classifier, err := severity.New(cfg.Severity)
+	if err != nil {
+		klog.Fatalf("failed to create severity classifier,err:%s", err.Error())
+	}
+	redactor, err := redact.New(cfg.Redaction)
+	if err != nil {
+		klog.Fatalf("failed to create redactor,err:%s", err.Error())
+	}
+
 	factory := informers.NewSharedInformerFactory(kubeClient, resync)
 	eventCollector := collector.NewEventCollector(kubeClient, factory, opts)
+	eventCollector.SetClassifier(classifier)
+	eventCollector.SetRedactor(redactor)
+	var notifier notify.Notifier = notify.LogNotifier{}
+	var sinkManager *sinks.Manager
+	if len(cfg.Sinks) > 0 {
+		sinkManager, err = sinks.NewManager(cfg.Sinks, cfg.Route)
+		if err != nil {
+			klog.Fatalf("failed to create sinks,err:%s", err.Error())
+		}
+		if err := sinkManager.Start(stopChan); err != nil {
+			klog.Fatalf("failed to start sinks,err:%s", err.Error())
+		}
+		sinkManager.SetClassifier(classifier)
+		eventCollector.SetSinks(sinkManager)
+		notifier = sinkManager
+		group.Go(func() error {
+			sinkManager.Run(stopChan)
+			return nil
+		})
+	}
+	if len(opts.ProblemPairs) > 0 {
+		pairs, err := problems.ParsePairs(opts.ProblemPairs)
+		if err != nil {
+			klog.Fatalf("failed to parse problem pairs,err:%s", err.Error())
+		}
+		tracker := problems.NewTracker(pairs, opts.ProblemTimeout)
+		eventCollector.AddObserver(tracker)
+		group.Go(func() error {
+			tracker.Run(stopChan)
+			return nil
+		})
+	}
+	var resolver *workload.Resolver
+	sinksNeedWorkloads := sinkManager != nil && sinkManager.NeedsWorkloads()
+	if len(opts.Detectors) > 0 || opts.Incidents.Enabled || sinksNeedWorkloads {
+		resolver = workload.NewResolver(factory)
+		eventCollector.WaitFor(resolver.HasSynced)
+	}
+	if sinksNeedWorkloads {
+		sinkManager.SetResolver(resolver)
+	}
+	if len(opts.Detectors) > 0 {
+		d, err := detectors.New(opts.Detectors, resolver, opts.DetectorWindow)
+		if err != nil {
+			klog.Fatalf("failed to create detectors,err:%s", err.Error())
+		}
+		if opts.DetectorAlerts {
+			d.SetNotifier(notifier)
+		}
+		eventCollector.AddObserver(d)
+		group.Go(func() error {
+			d.Run(stopChan)
+			return nil
+		})
+	}
+	if opts.Anomaly.Enabled {
+		a, err := anomaly.New(opts.Anomaly.Window, opts.Anomaly.Baseline, opts.Anomaly.Threshold)
+		if err != nil {
+			klog.Fatalf("failed to create anomaly detector,err:%s", err.Error())
+		}
+		if opts.Anomaly.Alerts {
+			a.SetNotifier(notifier)
+		}
+		eventCollector.AddObserver(a)
+		group.Go(func() error {
+			a.Run(stopChan)
+			return nil
+		})
+	}
+	if opts.TopN > 0 {
+		top, err := topn.New(opts.TopWindows, opts.TopN)
+		if err != nil {
+			klog.Fatalf("failed to create top n report,err:%s", err.Error())
+		}
+		eventCollector.AddObserver(top)
+		prometheus.MustRegister(top)
+		http.Handle("/top", top)
+	}
+	if opts.Incidents.Enabled {
+		typeFilter := filters.NewEventTypeFilter(opts.EventType)
+		grouper, err := incidents.New(typeFilter, resolver, opts.Incidents.Window, opts.Incidents.GroupWait)
+		if err != nil {
+			klog.Fatalf("failed to create incident grouper,err:%s", err.Error())
+		}
+		if opts.Incidents.Alerts {
+			grouper.SetNotifier(notifier)
+		}
+		eventCollector.AddObserver(grouper)
+		group.Go(func() error {
+			grouper.Run(stopChan)
+			return nil
+		})
+		http.Handle("/incidents", grouper)
+	}
+	if cfg.RemoteWrite != nil {
+		sender, err := remotewrite.New(*cfg.RemoteWrite, prometheus.DefaultGatherer)
+		if err != nil {
+			klog.Fatalf("failed to create remote write sender,err:%s", err.Error())
+		}
+		group.Go(func() error {
+			sender.Run(stopChan)
+			return nil
+		})
+	}
+	if cfg.Pushgateway != nil {
+		pusher, err := pushgateway.New(*cfg.Pushgateway, prometheus.DefaultGatherer)
+		if err != nil {
+			klog.Fatalf("failed to create pushgateway pusher,err:%s", err.Error())
+		}
+		group.Go(func() error {
+			pusher.Run(stopChan)
+			return nil
+		})
+	}
 	factory.Start(stopChan)
 
 	group.Go(func() error {
@@ -77,7 +234,12 @@ func main() {
 		w.WriteHeader(http.StatusOK)
 	})
 	http.Handle("/metrics", promhttp.Handler())
-	if err := http.ListenAndServe(fmt.Sprintf(":%d", opts.Port), nil); err != nil {
+	server := &http.Server{Addr: fmt.Sprintf(":%d", opts.Port)}
+	group.Go(func() error {
+		<-stopChan
+		return server.Shutdown(context.Background())
+	})
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		klog.Fatal(err)
 	}
 
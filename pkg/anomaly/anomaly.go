@@ -0,0 +1,175 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/caicloud/event_exporter/pkg/eventutil"
+	"github.com/caicloud/event_exporter/pkg/notify"
+)
+
+// buckets is the number of buckets the sliding window is divided into. The
+// window slides, and the baseline is updated, once per bucket.
+const buckets = 5
+
+// idleBaseline is the baseline below which an idle series is forgotten.
+const idleBaseline = 0.01
+
+type seriesKey struct {
+	namespace string
+	reason    string
+}
+
+type series struct {
+	// counts holds the occurrences per bucket, counts[current] is the bucket
+	// that is being filled.
+	counts  [buckets]float64
+	current int
+	// mean and variance are the exponentially weighted baseline of the number
+	// of occurrences per window.
+	mean     float64
+	variance float64
+	samples  int
+	flagged  bool
+}
+
+func (s *series) sum() float64 {
+	sum := 0.0
+	for _, c := range s.counts {
+		sum += c
+	}
+	return sum
+}
+
+// Detector maintains sliding window event rates per (namespace, reason) and
+// scores them against an exponentially weighted baseline.
+//
+// The score is the number of standard deviations the occurrences within the
+// window are above the baseline. The deviation is never taken below the
+// square root of the baseline, as for a Poisson process, nor below one, so
+// that a handful of events in a quiet namespace doesn't count as a spike.
+type Detector struct {
+	window    time.Duration
+	alpha     float64
+	warmup    int
+	threshold float64
+	notifier  notify.Notifier
+	now       func() time.Time
+
+	locker sync.Mutex
+	series map[seriesKey]*series
+}
+
+// New returns a detector comparing the occurrences within window against a
+// baseline with the given time constant. Series scoring at least threshold
+// are flagged as anomalous.
+func New(window, baseline time.Duration, threshold float64) (*Detector, error) {
+	if window < buckets*time.Second {
+		return nil, fmt.Errorf("anomaly window must be at least %ds, got %s", buckets, window)
+	}
+	if baseline < window {
+		return nil, fmt.Errorf("anomaly baseline %s must not be shorter than the window %s", baseline, window)
+	}
+	interval := window / buckets
+	return &Detector{
+		window:    window,
+		alpha:     float64(interval) / float64(baseline),
+		warmup:    int(baseline / interval),
+		threshold: threshold,
+		now:       time.Now,
+		series:    make(map[seriesKey]*series),
+	}, nil
+}
+
+// SetNotifier makes the detector forward a synthesized event whenever a
+// series becomes anomalous.
+func (d *Detector) SetNotifier(n notify.Notifier) {
+	d.notifier = n
+}
+
+// Run slides the window and updates the scores until stopCh is closed.
+func (d *Detector) Run(stopCh <-chan struct{}) {
+	wait.Until(d.tick, d.window/buckets, stopCh)
+}
+
+// Observe implements collector.Observer.
+func (d *Detector) Observe(event *v1.Event, occurrences int32) {
+	if occurrences <= 0 || d.now().Sub(eventutil.LastTime(event)) > d.window {
+		return
+	}
+	key := seriesKey{namespace: event.Namespace, reason: event.Reason}
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	s, ok := d.series[key]
+	if !ok {
+		s = &series{}
+		d.series[key] = s
+	}
+	s.counts[s.current] += float64(occurrences)
+}
+
+func (d *Detector) tick() {
+	var anomalies []*v1.Event
+	d.locker.Lock()
+	for key, s := range d.series {
+		count := s.sum()
+		score := 0.0
+		if s.samples >= d.warmup {
+			deviation := math.Max(math.Sqrt(s.variance), math.Max(math.Sqrt(s.mean), 1))
+			score = (count - s.mean) / deviation
+		}
+		anomalyScore.With(seriesLabels(key)).Set(score)
+		if score >= d.threshold && !s.flagged {
+			anomalies = append(anomalies, d.anomalyEvent(key, count, s.mean, score))
+		}
+		s.flagged = score >= d.threshold
+
+		diff := count - s.mean
+		s.mean += d.alpha * diff
+		s.variance = (1 - d.alpha) * (s.variance + d.alpha*diff*diff)
+		s.samples++
+		s.current = (s.current + 1) % buckets
+		s.counts[s.current] = 0
+
+		if count == 0 && s.mean < idleBaseline {
+			delete(d.series, key)
+			anomalyScore.Delete(seriesLabels(key))
+		}
+	}
+	d.locker.Unlock()
+
+	if d.notifier == nil {
+		return
+	}
+	for _, event := range anomalies {
+		d.notifier.Notify(event)
+	}
+}
+
+func (d *Detector) anomalyEvent(key seriesKey, count, baseline, score float64) *v1.Event {
+	object := v1.ObjectReference{Kind: "Namespace", Name: key.namespace}
+	message := fmt.Sprintf("%.0f %s events in the last %s, baseline is %.1f (anomaly score %.1f)",
+		count, key.reason, d.window, baseline, score)
+	return notify.NewEvent(object, v1.EventTypeWarning, "EventRateAnomaly", message)
+}
@@ -0,0 +1,77 @@
+package anomaly
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type recorder struct {
+	events []*v1.Event
+}
+
+func (r *recorder) Notify(event *v1.Event) {
+	r.events = append(r.events, event)
+}
+
+func TestDetector(t *testing.T) {
+	now := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+	d, err := New(5*time.Minute, 50*time.Minute, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.now = func() time.Time { return now }
+	notifications := &recorder{}
+	d.SetNotifier(notifications)
+	event := &v1.Event{
+		ObjectMeta:    meta_v1.ObjectMeta{Namespace: "team-a"},
+		Reason:        "FailedMount",
+		LastTimestamp: meta_v1.NewTime(now),
+	}
+	score := anomalyScore.WithLabelValues("team-a", "FailedMount")
+
+	for i := 0; i < 60; i++ {
+		d.Observe(event, 2)
+		d.tick()
+	}
+	if got := testutil.ToFloat64(score); got > 1 || got < -1 {
+		t.Errorf("steady rate scored %v, want about 0", got)
+	}
+	if len(notifications.events) != 0 {
+		t.Fatalf("expected no anomaly for a steady rate, got %d", len(notifications.events))
+	}
+
+	d.Observe(event, 100)
+	d.tick()
+	if got := testutil.ToFloat64(score); got < 3 {
+		t.Errorf("spike scored %v, want at least 3", got)
+	}
+	d.Observe(event, 2)
+	d.tick()
+	if len(notifications.events) != 1 {
+		t.Fatalf("expected one anomaly notification, got %d", len(notifications.events))
+	}
+	if got := notifications.events[0]; got.Reason != "EventRateAnomaly" || got.InvolvedObject.Name != "team-a" {
+		t.Errorf("unexpected anomaly event %s on %s", got.Reason, got.InvolvedObject.Name)
+	}
+
+	// Events older than the window are replays and don't count.
+	stale := event.DeepCopy()
+	stale.LastTimestamp = meta_v1.NewTime(now.Add(-time.Hour))
+	d.Observe(stale, 100)
+	if got := d.series[seriesKey{namespace: "team-a", reason: "FailedMount"}].counts; got[0]+got[1]+got[2]+got[3]+got[4] > 110 {
+		t.Errorf("stale event was counted, window holds %v", got)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	if _, err := New(time.Second, time.Hour, 3); err == nil {
+		t.Error("expected error for a window shorter than the buckets")
+	}
+	if _, err := New(time.Hour, time.Minute, 3); err == nil {
+		t.Error("expected error for a baseline shorter than the window")
+	}
+}
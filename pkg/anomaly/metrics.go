@@ -0,0 +1,38 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package anomaly
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	anomalyScore = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "kube_event",
+		Subsystem: "",
+		Name:      "anomaly_score",
+		Help:      "Number of standard deviations the event rate of a reason in a namespace is above its baseline",
+	}, []string{"namespace", "reason"})
+)
+
+func seriesLabels(key seriesKey) prometheus.Labels {
+	return prometheus.Labels{
+		"namespace": key.namespace,
+		"reason":    key.reason,
+	}
+}
@@ -32,19 +32,53 @@ import (
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog/v2"
 
+	"github.com/caicloud/event_exporter/pkg/eventutil"
 	"github.com/caicloud/event_exporter/pkg/filters"
 	"github.com/caicloud/event_exporter/pkg/options"
+	"github.com/caicloud/event_exporter/pkg/redact"
+	"github.com/caicloud/event_exporter/pkg/severity"
+	"github.com/caicloud/event_exporter/pkg/sinks"
 )
 
+// Observer is notified of every event the collector sees, before the event
+// type filters are applied, so that it can also match on Normal events.
+type Observer interface {
+	// Observe is called whenever an event is added or updated. occurrences is
+	// the number of times the event happened since it was last observed.
+	Observe(event *v1api.Event, occurrences int32)
+}
+
+// Absorber is an observer that forwards its own summary of some events to the
+// sinks, in place of the events themselves.
+type Absorber interface {
+	Observer
+	// Absorbs returns whether the event is summarized by the observer, and
+	// mustn't be forwarded to the sinks.
+	Absorbs(event *v1api.Event) bool
+}
+
 type EventCollector struct {
 	kc                kubernetes.Interface
 	factory           informers.SharedInformerFactory
 	eventLister       coreV1.EventLister
 	eventListerSynced cache.InformerSynced
+	synced            []cache.InformerSynced
 	queue             workqueue.RateLimitingInterface
 	filters           []filters.EventFilter
 	cache             map[string]v1api.Event
+	counts            map[string]int32
+	observers         []Observer
+	absorbers         []Absorber
+	sinks             *sinks.Manager
+	classifier        *severity.Classifier
+	redactor          *redact.Redactor
 	locker            sync.Mutex
+	// started is the time Run was called. Events created before were
+	// observed by the exporter before a restart, or not at all.
+	started time.Time
+	// replayed are the names of the cached events that weren't forwarded
+	// because they last happened before the collector started.
+	replayed map[string]bool
 }
 
 func NewEventCollector(kc kubernetes.Interface, factory informers.SharedInformerFactory, o *options.Options) *EventCollector {
@@ -56,6 +90,10 @@ func NewEventCollector(kc kubernetes.Interface, factory informers.SharedInformer
 		eventListerSynced: event.Informer().HasSynced,
 		queue:             workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
 		cache:             make(map[string]v1api.Event),
+		replayed:          make(map[string]bool),
+		counts:            make(map[string]int32),
+		classifier:        severity.Default(),
+		redactor:          redact.Default(),
 		locker:            sync.Mutex{},
 	}
 	eventCollector.addFilter(o)
@@ -78,12 +116,19 @@ func NewEventCollector(kc kubernetes.Interface, factory informers.SharedInformer
 	return eventCollector
 }
 
+// WaitFor makes Run wait for other informers the observers or sinks depend on
+// to sync before any event is processed. It must be called before Run.
+func (ec *EventCollector) WaitFor(synced ...cache.InformerSynced) {
+	ec.synced = append(ec.synced, synced...)
+}
+
 func (ec *EventCollector) Run(stopCh <-chan struct{}) error {
 	defer runtime.HandleCrash()
 	defer ec.queue.ShutDown()
 
 	klog.Info("starting eventCollector")
-	if ok := cache.WaitForCacheSync(stopCh, ec.eventListerSynced); !ok {
+	ec.started = time.Now()
+	if ok := cache.WaitForCacheSync(stopCh, append([]cache.InformerSynced{ec.eventListerSynced}, ec.synced...)...); !ok {
 		return fmt.Errorf("failed to wait for caches to sync")
 	}
 	klog.Info("started")
@@ -136,19 +181,41 @@ func (ec *EventCollector) syncEvent(key string) error {
 	if err != nil {
 		if errors.IsNotFound(err) {
 			klog.Infof("event %s has been deleted", key)
+			ec.redactor.Forget(key)
 			ec.locker.Lock()
-			defer ec.locker.Unlock()
-			if deletedEvent, ok := ec.cache[name]; ok {
-				DeleteMetric(&deletedEvent)
-				delete(ec.cache, name)
+			delete(ec.counts, key)
+			deletedEvent, ok := ec.cache[name]
+			replayed := ec.replayed[name]
+			delete(ec.cache, name)
+			delete(ec.replayed, name)
+			ec.locker.Unlock()
+			if ok {
+				DeleteMetric(&deletedEvent, ec.classifier.Classify(&deletedEvent))
+				if !replayed {
+					ec.forward(sinks.Deleted, &deletedEvent, 0)
+				}
 			}
 			return nil
 		}
 		return err
 	}
+	// Observers, metrics and sinks only see the redacted event.
+	event = ec.redactor.Redact(event)
+	occurrences := ec.observe(key, event)
 	if ec.eventFilter(event) {
 		ec.locker.Lock()
+		previous, seen := ec.cache[event.ObjectMeta.Name]
 		ec.cache[event.ObjectMeta.Name] = *event
+		// The informer replays the events after a restart, which the sinks
+		// were sent before. They are only forwarded, as added, once they are
+		// updated.
+		wasReplayed := ec.replayed[event.ObjectMeta.Name]
+		replayed := !seen && eventutil.LastTime(event).Before(ec.started)
+		if replayed {
+			ec.replayed[event.ObjectMeta.Name] = true
+		} else {
+			delete(ec.replayed, event.ObjectMeta.Name)
+		}
 		ec.locker.Unlock()
 		klog.Infof(
 			"event name: %s,count: %d,involvedObject_namespace: %s,involvedObject_kind: %s,involvedObject_name: %s,reason: %s,type: %s",
@@ -160,11 +227,87 @@ func (ec *EventCollector) syncEvent(key string) error {
 			event.Reason,
 			event.Type,
 		)
-		EventHandler(event)
+		level := ec.classifier.Classify(event)
+		// A rule matching the message may classify an update differently.
+		if seen {
+			if previousLevel := ec.classifier.Classify(&previous); previousLevel != level {
+				DeleteMetric(&previous, previousLevel)
+			}
+		}
+		EventHandler(event, level)
+		switch {
+		case replayed:
+		case seen && !wasReplayed:
+			ec.forward(sinks.Updated, event, occurrences)
+		default:
+			ec.forward(sinks.Added, event, occurrences)
+		}
 	}
 	return nil
 }
 
+// AddObserver registers an observer. It must be called before Run.
+func (ec *EventCollector) AddObserver(o Observer) {
+	ec.observers = append(ec.observers, o)
+	if a, ok := o.(Absorber); ok {
+		ec.absorbers = append(ec.absorbers, a)
+	}
+}
+
+// SetSinks makes the collector forward the events that pass the filters to
+// the sinks. It must be called before Run.
+func (ec *EventCollector) SetSinks(m *sinks.Manager) {
+	ec.sinks = m
+}
+
+// SetClassifier replaces the built-in classifier of the severity the metrics
+// are labelled with. It must be called before Run.
+func (ec *EventCollector) SetClassifier(c *severity.Classifier) {
+	ec.classifier = c
+}
+
+// SetRedactor replaces the built-in redactor of the secrets in events. It must
+// be called before Run.
+func (ec *EventCollector) SetRedactor(r *redact.Redactor) {
+	ec.redactor = r
+}
+
+// forward sends the event to the sinks, unless an absorber summarizes it.
+func (ec *EventCollector) forward(kind sinks.Kind, event *v1api.Event, occurrences int32) {
+	if ec.sinks == nil {
+		return
+	}
+	for _, a := range ec.absorbers {
+		if a.Absorbs(event) {
+			return
+		}
+	}
+	ec.sinks.Send(&sinks.Record{Kind: kind, Event: event, Occurrences: occurrences})
+}
+
+// observe notifies the observers of the event, and returns the number of times
+// it happened since it was last observed.
+func (ec *EventCollector) observe(key string, event *v1api.Event) int32 {
+	count := eventutil.Count(event)
+	ec.locker.Lock()
+	last, seen := ec.counts[key]
+	if !seen && eventutil.FirstTime(event).Before(ec.started) {
+		// The count of an event created before the collector started, e.g.
+		// replayed by the informer after a restart, is only a baseline.
+		last = count
+	}
+	occurrences := count - last
+	ec.counts[key] = count
+	ec.locker.Unlock()
+	if occurrences < 0 {
+		occurrences = 0
+	}
+	for _, o := range ec.observers {
+		o.Observe(event, occurrences)
+	}
+	return occurrences
+}
+
 func (ec *EventCollector) addFilter(o *options.Options) {
 	typeFilter := filters.NewEventTypeFilter(o.EventType)
 	ec.filters = append(ec.filters, typeFilter)
@@ -0,0 +1,160 @@
+package collector
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	coreV1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/caicloud/event_exporter/pkg/redact"
+	"github.com/caicloud/event_exporter/pkg/severity"
+	"github.com/caicloud/event_exporter/pkg/sinks"
+)
+
+// sentRecords are the records sent to the sinks of type collector.
+var sentRecords struct {
+	sync.Mutex
+	records []sinks.Record
+}
+
+type collectorSink struct{}
+
+func (collectorSink) Start(_ <-chan struct{}) error { return nil }
+func (collectorSink) Close() error                  { return nil }
+
+func (collectorSink) Send(record *sinks.Record) error {
+	sentRecords.Lock()
+	defer sentRecords.Unlock()
+	sentRecords.records = append(sentRecords.records, *record)
+	return nil
+}
+
+func init() {
+	sinks.Register("collector", func(_ string, _ json.RawMessage) (sinks.Sink, error) {
+		return collectorSink{}, nil
+	})
+}
+
+type observation struct {
+	name        string
+	occurrences int32
+}
+
+type recorder []observation
+
+func (r *recorder) Observe(event *v1.Event, occurrences int32) {
+	*r = append(*r, observation{event.Name, occurrences})
+}
+
+func TestObserveBaseline(t *testing.T) {
+	started := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	observations := &recorder{}
+	ec := &EventCollector{counts: make(map[string]int32), started: started}
+	ec.AddObserver(observations)
+	newEvent := func(name string, created time.Time, count int32) *v1.Event {
+		return &v1.Event{
+			ObjectMeta:     meta_v1.ObjectMeta{Namespace: "default", Name: name},
+			FirstTimestamp: meta_v1.NewTime(created),
+			Count:          count,
+		}
+	}
+
+	// The replayed event only counts its occurrences since the start.
+	ec.observe("default/old", newEvent("old", started.Add(-time.Hour), 40))
+	ec.observe("default/old", newEvent("old", started.Add(-time.Hour), 42))
+	ec.observe("default/new", newEvent("new", started.Add(time.Minute), 3))
+	want := recorder{{"old", 0}, {"old", 2}, {"new", 3}}
+	if len(*observations) != len(want) {
+		t.Fatalf("got observations %v, want %v", *observations, want)
+	}
+	for i, o := range *observations {
+		if o != want[i] {
+			t.Errorf("observation %d: got %v, want %v", i, o, want[i])
+		}
+	}
+}
+
+func TestSyncEventReplayed(t *testing.T) {
+	started := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	m, err := sinks.NewManager([]sinks.Config{{Name: "test", Type: "collector"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopCh := make(chan struct{})
+	if err := m.Start(stopCh); err != nil {
+		t.Fatal(err)
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	ec := &EventCollector{
+		eventLister: coreV1.NewEventLister(indexer),
+		cache:       make(map[string]v1.Event),
+		counts:      make(map[string]int32),
+		replayed:    make(map[string]bool),
+		sinks:       m,
+		classifier:  severity.Default(),
+		redactor:    redact.Default(),
+		started:     started,
+	}
+	defer func() {
+		// The metrics are shared with the other tests.
+		for _, event := range ec.cache {
+			event := event
+			DeleteMetric(&event, ec.classifier.Classify(&event))
+		}
+	}()
+	newEvent := func(name string, last time.Time, count int32) *v1.Event {
+		return &v1.Event{
+			ObjectMeta:     meta_v1.ObjectMeta{Namespace: "default", Name: name},
+			InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "nginx"},
+			Type:           v1.EventTypeWarning,
+			FirstTimestamp: meta_v1.NewTime(started.Add(-time.Hour)),
+			LastTimestamp:  meta_v1.NewTime(last),
+			Count:          count,
+		}
+	}
+	sync := func(event *v1.Event) {
+		if err := indexer.Update(event); err != nil {
+			t.Fatal(err)
+		}
+		if err := ec.syncEvent("default/" + event.Name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Replayed events are only forwarded once they are updated, and the
+	// deletion of the others is dropped.
+	sync(newEvent("updated", started.Add(-time.Minute), 3))
+	sync(newEvent("deleted", started.Add(-time.Minute), 1))
+	sync(newEvent("recent", started.Add(time.Second), 2))
+	sync(newEvent("updated", started.Add(time.Minute), 5))
+	sync(newEvent("updated", started.Add(2*time.Minute), 6))
+	if err := indexer.Delete(newEvent("deleted", started, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.syncEvent("default/deleted"); err != nil {
+		t.Fatal(err)
+	}
+	close(stopCh)
+	m.Run(stopCh)
+
+	type sent struct {
+		kind        sinks.Kind
+		name        string
+		occurrences int32
+	}
+	want := []sent{{sinks.Added, "recent", 0}, {sinks.Added, "updated", 2}, {sinks.Updated, "updated", 1}}
+	sentRecords.Lock()
+	defer sentRecords.Unlock()
+	if len(sentRecords.records) != len(want) {
+		t.Fatalf("got %d records, want %v", len(sentRecords.records), want)
+	}
+	for i, record := range sentRecords.records {
+		if got := (sent{record.Kind, record.Event.Name, record.Occurrences}); got != want[i] {
+			t.Errorf("record %d: got %v, want %v", i, got, want[i])
+		}
+	}
+}
@@ -31,16 +31,16 @@ var (
 		Subsystem: "",
 		Name:      "count",
 		Help:      "Number of kubernetes event happened",
-	}, []string{"name", "involved_object_namespace", "namespace", "involved_object_name", "involved_object_kind", "reason", "type", "source"})
+	}, []string{"name", "involved_object_namespace", "namespace", "involved_object_name", "involved_object_kind", "reason", "type", "source", "severity"})
 	eventTotal = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "kube_event",
 		Subsystem: "",
 		Name:      "unique_events_total",
 		Help:      "Total number of kubernetes unique event happened",
-	}, []string{"name", "involved_object_namespace", "namespace", "involved_object_name", "involved_object_kind", "reason", "type", "source"})
+	}, []string{"name", "involved_object_namespace", "namespace", "involved_object_name", "involved_object_kind", "reason", "type", "source", "severity"})
 )
 
-func increaseUniqueEventTotal(event *v1.Event) {
+func increaseUniqueEventTotal(event *v1.Event, severity string) {
 	eventTotal.With(prometheus.Labels{
 		"name":                      event.ObjectMeta.Name,
 		"namespace":                 event.Namespace,
@@ -50,10 +50,11 @@ func increaseUniqueEventTotal(event *v1.Event) {
 		"reason":                    event.Reason,
 		"type":                      event.Type,
 		"source":                    fmt.Sprintf("%s/%s", event.Source.Host, event.Source.Component),
+		"severity":                  severity,
 	}).Inc()
 }
 
-func updateEventCount(event *v1.Event) {
+func updateEventCount(event *v1.Event, severity string) {
 	eventCount.With(prometheus.Labels{
 		"name":                      event.ObjectMeta.Name,
 		"namespace":                 event.Namespace,
@@ -63,10 +64,11 @@ func updateEventCount(event *v1.Event) {
 		"reason":                    event.Reason,
 		"type":                      event.Type,
 		"source":                    fmt.Sprintf("%s/%s", event.Source.Host, event.Source.Component),
+		"severity":                  severity,
 	}).Set(float64(event.Count))
 }
 
-func delEventCountMetric(event *v1.Event) {
+func delEventCountMetric(event *v1.Event, severity string) {
 	ret := eventCount.Delete(prometheus.Labels{
 		"name":                      event.ObjectMeta.Name,
 		"namespace":                 event.Namespace,
@@ -76,13 +78,14 @@ func delEventCountMetric(event *v1.Event) {
 		"reason":                    event.Reason,
 		"type":                      event.Type,
 		"source":                    fmt.Sprintf("%s/%s", event.Source.Host, event.Source.Component),
+		"severity":                  severity,
 	})
 	if ret {
 		klog.Infof("event %s has been removed from Prometheus", event.ObjectMeta.Name)
 	}
 }
 
-func delEventTotalMetric(event *v1.Event) {
+func delEventTotalMetric(event *v1.Event, severity string) {
 	ret := eventTotal.Delete(prometheus.Labels{
 		"name":                      event.ObjectMeta.Name,
 		"namespace":                 event.Namespace,
@@ -92,18 +95,22 @@ func delEventTotalMetric(event *v1.Event) {
 		"reason":                    event.Reason,
 		"type":                      event.Type,
 		"source":                    fmt.Sprintf("%s/%s", event.Source.Host, event.Source.Component),
+		"severity":                  severity,
 	})
 	if ret {
 		klog.Infof("event %s has been removed from Prometheus", event.ObjectMeta.Name)
 	}
 }
 
-func EventHandler(event *v1.Event) {
-	increaseUniqueEventTotal(event)
-	updateEventCount(event)
+// EventHandler updates the metrics of the event, labelled with its severity.
+func EventHandler(event *v1.Event, severity string) {
+	increaseUniqueEventTotal(event, severity)
+	updateEventCount(event, severity)
 }
 
-func DeleteMetric(event *v1.Event) {
-	delEventCountMetric(event)
-	delEventTotalMetric(event)
+// DeleteMetric deletes the metrics of the event. The severity must be the one
+// it was handled with.
+func DeleteMetric(event *v1.Event, severity string) {
+	delEventCountMetric(event, severity)
+	delEventTotalMetric(event, severity)
 }
@@ -6,6 +6,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"github.com/caicloud/event_exporter/pkg/severity"
 	"github.com/caicloud/event_exporter/pkg/utils"
 )
 
@@ -28,14 +29,14 @@ func TestEventHandler(t *testing.T) {
 		Count: 120,
 		Type:  "Warning",
 	}
-	EventHandler(event)
+	EventHandler(event, severity.Warning)
 	var testcases utils.MetricsTestCases = map[string]utils.MetricsTestCase{
 		"EventCount": {
 			Target: eventCount,
 			Want: `
 # HELP kube_event_count Number of kubernetes event happened
 # TYPE kube_event_count gauge
-kube_event_count{involved_object_kind="PersistentVolumeClaim",involved_object_name="prometheus-data-prometheus-0",involved_object_namespace="kube-system",name="prometheus-data-prometheus-0.163ff24070ae83e5",namespace="kube-system",reason="ProvisioningFailed",source="/persistentvolume-controller",type="Warning"} 120
+kube_event_count{involved_object_kind="PersistentVolumeClaim",involved_object_name="prometheus-data-prometheus-0",involved_object_namespace="kube-system",name="prometheus-data-prometheus-0.163ff24070ae83e5",namespace="kube-system",reason="ProvisioningFailed",severity="warning",source="/persistentvolume-controller",type="Warning"} 120
 `,
 		},
 		"EventTotal": {
@@ -43,7 +44,7 @@ kube_event_count{involved_object_kind="PersistentVolumeClaim",involved_object_na
 			Want: `
 # HELP kube_event_unique_events_total Total number of kubernetes unique event happened
 # TYPE kube_event_unique_events_total counter
-kube_event_unique_events_total{involved_object_kind="PersistentVolumeClaim",involved_object_name="prometheus-data-prometheus-0",involved_object_namespace="kube-system",name="prometheus-data-prometheus-0.163ff24070ae83e5",namespace="kube-system",reason="ProvisioningFailed",source="/persistentvolume-controller",type="Warning"} 1
+kube_event_unique_events_total{involved_object_kind="PersistentVolumeClaim",involved_object_name="prometheus-data-prometheus-0",involved_object_namespace="kube-system",name="prometheus-data-prometheus-0.163ff24070ae83e5",namespace="kube-system",reason="ProvisioningFailed",severity="warning",source="/persistentvolume-controller",type="Warning"} 1
 `,
 		},
 	}
@@ -69,8 +70,8 @@ func TestDeleteMetric(t *testing.T) {
 		Count: 120,
 		Type:  "Warning",
 	}
-	EventHandler(event)
-	DeleteMetric(event)
+	EventHandler(event, severity.Warning)
+	DeleteMetric(event, severity.Warning)
 	var testcases utils.MetricsTestCases = map[string]utils.MetricsTestCase{
 		"EventCount": {
 			Target: eventCount,
@@ -121,16 +122,16 @@ func TestMultiMetric(t *testing.T) {
 		Count: 140,
 		Type:  "Warning",
 	}
-	EventHandler(eventBefore)
-	DeleteMetric(eventBefore)
-	EventHandler(eventAfter)
+	EventHandler(eventBefore, severity.Warning)
+	DeleteMetric(eventBefore, severity.Warning)
+	EventHandler(eventAfter, severity.Warning)
 	var testcases utils.MetricsTestCases = map[string]utils.MetricsTestCase{
 		"EventCount": {
 			Target: eventCount,
 			Want: `
 		# HELP kube_event_count Number of kubernetes event happened
 		# TYPE kube_event_count gauge
-		kube_event_count{involved_object_kind="PersistentVolumeClaim",involved_object_name="prometheus-data-prometheus-0",involved_object_namespace="kube-system",name="prometheus-data-prometheus-0.163ff24070ae83e5",namespace="kube-system",reason="ProvisioningFailed",source="/persistentvolume-controller",type="Warning"} 140
+		kube_event_count{involved_object_kind="PersistentVolumeClaim",involved_object_name="prometheus-data-prometheus-0",involved_object_namespace="kube-system",name="prometheus-data-prometheus-0.163ff24070ae83e5",namespace="kube-system",reason="ProvisioningFailed",severity="warning",source="/persistentvolume-controller",type="Warning"} 140
 `,
 		},
 		"EventTotal": {
@@ -138,7 +139,7 @@ func TestMultiMetric(t *testing.T) {
 			Want: `
         # HELP kube_event_unique_events_total Total number of kubernetes unique event happened
         # TYPE kube_event_unique_events_total counter
-        kube_event_unique_events_total{involved_object_kind="PersistentVolumeClaim",involved_object_name="prometheus-data-prometheus-0",involved_object_namespace="kube-system",name="prometheus-data-prometheus-0.163ff24070ae83e5",namespace="kube-system",reason="ProvisioningFailed",source="/persistentvolume-controller",type="Warning"} 1
+        kube_event_unique_events_total{involved_object_kind="PersistentVolumeClaim",involved_object_name="prometheus-data-prometheus-0",involved_object_namespace="kube-system",name="prometheus-data-prometheus-0.163ff24070ae83e5",namespace="kube-system",reason="ProvisioningFailed",severity="warning",source="/persistentvolume-controller",type="Warning"} 1
 `,
 		},
 	}
@@ -0,0 +1,77 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"github.com/caicloud/event_exporter/pkg/pushgateway"
+	"github.com/caicloud/event_exporter/pkg/redact"
+	"github.com/caicloud/event_exporter/pkg/remotewrite"
+	"github.com/caicloud/event_exporter/pkg/severity"
+	"github.com/caicloud/event_exporter/pkg/sinks"
+)
+
+// Config is the configuration file of the exporter, for settings that don't
+// fit on the command line.
+type Config struct {
+	// Sinks the events that pass the filters are forwarded to.
+	Sinks []sinks.Config `json:"sinks,omitempty"`
+	// Route routes the events to the sinks. Every sink receives every event
+	// if it isn't set.
+	Route *sinks.RouteConfig `json:"route,omitempty"`
+	// Severity classifies the events into the severities the metrics and
+	// records are labelled with.
+	Severity severity.Config `json:"severity,omitempty"`
+	// Redaction removes secrets from the events before they are observed,
+	// counted in the metrics or forwarded to the sinks.
+	Redaction redact.Config `json:"redaction,omitempty"`
+	// RemoteWrite sends the metrics to a remote write endpoint if set, for
+	// clusters that can't be scraped.
+	RemoteWrite *remotewrite.Config `json:"remoteWrite,omitempty"`
+	// Pushgateway pushes the metrics to a Pushgateway if set, for clusters
+	// torn down before they are scraped.
+	Pushgateway *pushgateway.Config `json:"pushgateway,omitempty"`
+}
+
+// Load reads a YAML configuration file. Unknown fields are rejected so that
+// typos don't go unnoticed.
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read configuration file")
+	}
+	return Parse(data)
+}
+
+// Parse parses a YAML configuration.
+func Parse(data []byte) (*Config, error) {
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config, disallowUnknownFields); err != nil {
+		return nil, errors.Wrap(err, "failed to parse configuration")
+	}
+	return config, nil
+}
+
+func disallowUnknownFields(d *json.Decoder) *json.Decoder {
+	d.DisallowUnknownFields()
+	return d
+}
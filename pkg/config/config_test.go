@@ -0,0 +1,107 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/event_exporter/pkg/pushgateway"
+	"github.com/caicloud/event_exporter/pkg/redact"
+	"github.com/caicloud/event_exporter/pkg/remotewrite"
+	"github.com/caicloud/event_exporter/pkg/severity"
+	"github.com/caicloud/event_exporter/pkg/sinks"
+	"github.com/caicloud/event_exporter/pkg/sinks/httpclient"
+)
+
+func TestParse(t *testing.T) {
+	config, err := Parse([]byte(`
+sinks:
+- name: warnings
+  type: log
+  filter:
+    types: [Warning]
+    namespaces: [kube-system]
+route:
+  receiver: warnings
+  groupBy: [namespace]
+  groupWait: 1m
+  routes:
+  - matchers: ['reason=~"BackOff|Failed"']
+    continue: true
+severity:
+  rules:
+  - reasons: [BackOff]
+    kinds: [Pod]
+    severity: info
+redaction:
+  disableDefaults: true
+  rules:
+  - name: internal-host
+    pattern: '[a-z0-9-]+\.corp\.example\.com'
+remoteWrite:
+  url: http://prometheus:9090/api/v1/write
+  externalLabels:
+    cluster: edge-1
+  bearerToken: token
+pushgateway:
+  url: http://pushgateway:9091
+  grouping:
+    cluster: ci-7
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []sinks.Config{{
+		Name: "warnings",
+		Type: "log",
+		Filter: sinks.FilterConfig{
+			Types:      []string{"Warning"},
+			Namespaces: []string{"kube-system"},
+		},
+	}}
+	if !reflect.DeepEqual(config.Sinks, want) {
+		t.Errorf("Parse() sinks = %+v, want %+v", config.Sinks, want)
+	}
+	wantRoute := &sinks.RouteConfig{
+		Receiver:  "warnings",
+		GroupBy:   []string{"namespace"},
+		GroupWait: meta_v1.Duration{Duration: time.Minute},
+		Routes:    []sinks.RouteConfig{{Matchers: []string{`reason=~"BackOff|Failed"`}, Continue: true}},
+	}
+	if !reflect.DeepEqual(config.Route, wantRoute) {
+		t.Errorf("Parse() route = %+v, want %+v", config.Route, wantRoute)
+	}
+
+	wantSeverity := severity.Config{Rules: []severity.Rule{{Reasons: []string{"BackOff"}, Kinds: []string{"Pod"}, Severity: "info"}}}
+	if !reflect.DeepEqual(config.Severity, wantSeverity) {
+		t.Errorf("Parse() severity = %+v, want %+v", config.Severity, wantSeverity)
+	}
+
+	wantRedaction := redact.Config{
+		Rules:           []redact.Rule{{Name: "internal-host", Pattern: `[a-z0-9-]+\.corp\.example\.com`}},
+		DisableDefaults: true,
+	}
+	if !reflect.DeepEqual(config.Redaction, wantRedaction) {
+		t.Errorf("Parse() redaction = %+v, want %+v", config.Redaction, wantRedaction)
+	}
+
+	wantRemoteWrite := &remotewrite.Config{
+		URL:            "http://prometheus:9090/api/v1/write",
+		ExternalLabels: map[string]string{"cluster": "edge-1"},
+		Config:         httpclient.Config{BearerToken: "token"},
+	}
+	if !reflect.DeepEqual(config.RemoteWrite, wantRemoteWrite) {
+		t.Errorf("Parse() remote write = %+v, want %+v", config.RemoteWrite, wantRemoteWrite)
+	}
+
+	wantPushgateway := &pushgateway.Config{URL: "http://pushgateway:9091", Grouping: map[string]string{"cluster": "ci-7"}}
+	if !reflect.DeepEqual(config.Pushgateway, wantPushgateway) {
+		t.Errorf("Parse() pushgateway = %+v, want %+v", config.Pushgateway, wantPushgateway)
+	}
+
+	if _, err := Parse([]byte("sink: []")); err == nil {
+		t.Error("expected error for unknown field")
+	}
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package detectors
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/caicloud/event_exporter/pkg/eventutil"
+	"github.com/caicloud/event_exporter/pkg/notify"
+	"github.com/caicloud/event_exporter/pkg/workload"
+)
+
+// Names of the built-in detectors.
+const (
+	CrashLoop  = "crashloop"
+	OOMKill    = "oomkill"
+	Eviction   = "eviction"
+	Preemption = "preemption"
+)
+
+type detector struct {
+	name    string
+	reasons []string
+	// message, if set, must match the event message as well.
+	message *regexp.Regexp
+	// alertReason is the reason of the synthesized alert events.
+	alertReason string
+	total       *prometheus.CounterVec
+}
+
+func (d *detector) matches(event *v1.Event) bool {
+	for _, reason := range d.reasons {
+		if event.Reason == reason {
+			return d.message == nil || d.message.MatchString(event.Message)
+		}
+	}
+	return false
+}
+
+var builtin = map[string]*detector{
+	CrashLoop: {
+		name:        CrashLoop,
+		reasons:     []string{"BackOff"},
+		message:     regexp.MustCompile(`^Back-off restarting failed container`),
+		alertReason: "CrashLoopDetected",
+		total:       crashLoopTotal,
+	},
+	OOMKill: {
+		name:        OOMKill,
+		reasons:     []string{"OOMKilling", "SystemOOM"},
+		alertReason: "OOMKillDetected",
+		total:       oomKillTotal,
+	},
+	Eviction: {
+		name:        Eviction,
+		reasons:     []string{"Evicted", "EvictionThresholdMet", "TaintManagerEviction"},
+		alertReason: "EvictionDetected",
+		total:       evictionTotal,
+	},
+	Preemption: {
+		name:        Preemption,
+		reasons:     []string{"Preempted"},
+		alertReason: "PreemptionDetected",
+		total:       preemptionTotal,
+	},
+}
+
+type crashLoop struct {
+	workload workload.Reference
+	lastSeen time.Time
+}
+
+// Detectors recognises crash loops, OOM kills, evictions and preemptions from
+// events and exports them per workload.
+type Detectors struct {
+	detectors []*detector
+	resolver  *workload.Resolver
+	window    time.Duration
+	notifier  notify.Notifier
+	now       func() time.Time
+
+	locker sync.Mutex
+	// crashLooping tracks the pods that backed off within the window.
+	crashLooping map[v1.ObjectReference]*crashLoop
+}
+
+// New returns the named built-in detectors. A pod is considered crash looping
+// until it has not backed off for window, and events older than window don't
+// raise alerts.
+func New(names []string, resolver *workload.Resolver, window time.Duration) (*Detectors, error) {
+	if window <= 0 {
+		return nil, fmt.Errorf("detector window must be positive, got %s", window)
+	}
+	d := &Detectors{
+		resolver:     resolver,
+		window:       window,
+		now:          time.Now,
+		crashLooping: make(map[v1.ObjectReference]*crashLoop),
+	}
+	for _, name := range names {
+		det, ok := builtin[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown detector %q", name)
+		}
+		d.detectors = append(d.detectors, det)
+	}
+	return d, nil
+}
+
+// SetNotifier makes the detectors forward a synthesized alert event whenever
+// they detect a problem.
+func (d *Detectors) SetNotifier(n notify.Notifier) {
+	d.notifier = n
+}
+
+// Run periodically expires crash loops until stopCh is closed.
+func (d *Detectors) Run(stopCh <-chan struct{}) {
+	wait.Until(d.expire, d.window/10, stopCh)
+}
+
+// Observe implements collector.Observer.
+func (d *Detectors) Observe(event *v1.Event, occurrences int32) {
+	for _, det := range d.detectors {
+		if !det.matches(event) {
+			continue
+		}
+		ref := d.resolver.Resolve(event.InvolvedObject)
+		if occurrences > 0 {
+			det.total.With(workloadLabels(ref)).Add(float64(occurrences))
+		}
+		recent := d.now().Sub(eventutil.LastTime(event)) <= d.window
+		if det.name == CrashLoop {
+			if recent && d.startCrashLoop(event, ref) {
+				d.alert(det, event, ref)
+			}
+			continue
+		}
+		if recent && occurrences > 0 {
+			d.alert(det, event, ref)
+		}
+	}
+}
+
+// startCrashLoop records that the pod backed off and returns whether the pod
+// just started crash looping.
+func (d *Detectors) startCrashLoop(event *v1.Event, ref workload.Reference) bool {
+	key := v1.ObjectReference{
+		Kind:      event.InvolvedObject.Kind,
+		Namespace: event.InvolvedObject.Namespace,
+		Name:      event.InvolvedObject.Name,
+	}
+	lastSeen := eventutil.LastTime(event)
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	if loop, ok := d.crashLooping[key]; ok {
+		if lastSeen.After(loop.lastSeen) {
+			loop.lastSeen = lastSeen
+		}
+		return false
+	}
+	d.crashLooping[key] = &crashLoop{workload: ref, lastSeen: lastSeen}
+	crashLoopingPods.With(workloadLabels(ref)).Inc()
+	return true
+}
+
+func (d *Detectors) expire() {
+	now := d.now()
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	for key, loop := range d.crashLooping {
+		if now.Sub(loop.lastSeen) <= d.window {
+			continue
+		}
+		delete(d.crashLooping, key)
+		labels := workloadLabels(loop.workload)
+		crashLoopingPods.With(labels).Dec()
+		if d.podsCrashLooping(loop.workload) == 0 {
+			crashLoopingPods.Delete(labels)
+		}
+	}
+}
+
+func (d *Detectors) podsCrashLooping(ref workload.Reference) int {
+	n := 0
+	for _, loop := range d.crashLooping {
+		if loop.workload == ref {
+			n++
+		}
+	}
+	return n
+}
+
+func (d *Detectors) alert(det *detector, event *v1.Event, ref workload.Reference) {
+	if d.notifier == nil {
+		return
+	}
+	object := v1.ObjectReference{Kind: ref.Kind, Namespace: ref.Namespace, Name: ref.Name}
+	message := fmt.Sprintf("%s %s detected on %s %s: %s",
+		det.name, event.Reason, event.InvolvedObject.Kind, objectName(event.InvolvedObject), event.Message)
+	d.notifier.Notify(notify.NewEvent(object, v1.EventTypeWarning, det.alertReason, message))
+}
+
+func objectName(object v1.ObjectReference) string {
+	if object.Namespace == "" {
+		return object.Name
+	}
+	return object.Namespace + "/" + object.Name
+}
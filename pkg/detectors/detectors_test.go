@@ -0,0 +1,111 @@
+package detectors
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+
+	"github.com/caicloud/event_exporter/pkg/utils"
+	"github.com/caicloud/event_exporter/pkg/workload"
+)
+
+type recorder struct {
+	events []*v1.Event
+}
+
+func (r *recorder) Notify(event *v1.Event) {
+	r.events = append(r.events, event)
+}
+
+func newEvent(reason, message string, last time.Time) *v1.Event {
+	return &v1.Event{
+		InvolvedObject: v1.ObjectReference{
+			Kind:      "Pod",
+			Namespace: "default",
+			Name:      "nginx-0",
+		},
+		Reason:        reason,
+		Message:       message,
+		Type:          v1.EventTypeWarning,
+		LastTimestamp: meta_v1.NewTime(last),
+	}
+}
+
+func TestDetectors(t *testing.T) {
+	now := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+	resolver := workload.NewResolver(informers.NewSharedInformerFactory(nil, 0))
+	d, err := New([]string{CrashLoop, Eviction}, resolver, 10*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.now = func() time.Time { return now }
+	notifications := &recorder{}
+	d.SetNotifier(notifications)
+
+	d.Observe(newEvent("BackOff", "Back-off restarting failed container", now), 1)
+	d.Observe(newEvent("BackOff", "Back-off restarting failed container", now.Add(time.Second)), 2)
+	d.Observe(newEvent("BackOff", "Back-off pulling image \"nginx\"", now), 1)
+	d.Observe(newEvent("Evicted", "The node was low on resource: memory.", now), 1)
+	d.Observe(newEvent("Evicted", "The node was low on resource: memory.", now.Add(-time.Hour)), 1)
+	d.Observe(newEvent("Preempted", "by default/critical on node node-1", now), 1)
+
+	if len(notifications.events) != 2 {
+		t.Fatalf("expected 2 alerts, got %d", len(notifications.events))
+	}
+	for i, reason := range []string{"CrashLoopDetected", "EvictionDetected"} {
+		if got := notifications.events[i].Reason; got != reason {
+			t.Errorf("alert %d reason = %s, want %s", i, got, reason)
+		}
+	}
+
+	var testcases utils.MetricsTestCases = map[string]utils.MetricsTestCase{
+		"CrashLoopingPods": {
+			Target: crashLoopingPods,
+			Want: `
+# HELP kube_event_crash_looping_pods Number of pods of a workload that are backing off restarting a failed container
+# TYPE kube_event_crash_looping_pods gauge
+kube_event_crash_looping_pods{namespace="default",workload_kind="Pod",workload_name="nginx-0"} 1
+`,
+		},
+		"CrashLoopTotal": {
+			Target: crashLoopTotal,
+			Want: `
+# HELP kube_event_crash_loops_total Total number of back-offs restarting a failed container of a workload
+# TYPE kube_event_crash_loops_total counter
+kube_event_crash_loops_total{namespace="default",workload_kind="Pod",workload_name="nginx-0"} 3
+`,
+		},
+		"EvictionTotal": {
+			Target: evictionTotal,
+			Want: `
+# HELP kube_event_evictions_total Total number of evictions of a workload, or eviction thresholds met on a node
+# TYPE kube_event_evictions_total counter
+kube_event_evictions_total{namespace="default",workload_kind="Pod",workload_name="nginx-0"} 2
+`,
+		},
+		"PreemptionTotal": {
+			Target: preemptionTotal,
+			Want:   "",
+		},
+	}
+	testcases.Test(t)
+
+	d.now = func() time.Time { return now.Add(time.Hour) }
+	d.expire()
+	testcases = map[string]utils.MetricsTestCase{
+		"CrashLoopingPods": {
+			Target: crashLoopingPods,
+			Want:   "",
+		},
+	}
+	testcases.Test(t)
+}
+
+func TestNewUnknownDetector(t *testing.T) {
+	if _, err := New([]string{"unknown"}, nil, time.Minute); err == nil {
+		t.Error("expected error for unknown detector")
+	}
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package detectors
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/caicloud/event_exporter/pkg/workload"
+)
+
+var (
+	workloadLabelNames = []string{"namespace", "workload_kind", "workload_name"}
+
+	crashLoopingPods = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "kube_event",
+		Subsystem: "",
+		Name:      "crash_looping_pods",
+		Help:      "Number of pods of a workload that are backing off restarting a failed container",
+	}, workloadLabelNames)
+	crashLoopTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "kube_event",
+		Subsystem: "",
+		Name:      "crash_loops_total",
+		Help:      "Total number of back-offs restarting a failed container of a workload",
+	}, workloadLabelNames)
+	oomKillTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "kube_event",
+		Subsystem: "",
+		Name:      "oom_kills_total",
+		Help:      "Total number of OOM kills reported for a workload or node",
+	}, workloadLabelNames)
+	evictionTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "kube_event",
+		Subsystem: "",
+		Name:      "evictions_total",
+		Help:      "Total number of evictions of a workload, or eviction thresholds met on a node",
+	}, workloadLabelNames)
+	preemptionTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "kube_event",
+		Subsystem: "",
+		Name:      "preemptions_total",
+		Help:      "Total number of pods of a workload preempted by the scheduler",
+	}, workloadLabelNames)
+)
+
+func workloadLabels(ref workload.Reference) prometheus.Labels {
+	return prometheus.Labels{
+		"namespace":     ref.Namespace,
+		"workload_kind": ref.Kind,
+		"workload_name": ref.Name,
+	}
+}
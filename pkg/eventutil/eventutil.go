@@ -0,0 +1,65 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventutil
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// LastTime returns the time the event was last observed. Events recorded by
+// the legacy API only set LastTimestamp, while events.k8s.io style events set
+// EventTime and Series, so every field is tried in turn.
+func LastTime(event *v1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case event.Series != nil && !event.Series.LastObservedTime.IsZero():
+		return event.Series.LastObservedTime.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	case !event.FirstTimestamp.IsZero():
+		return event.FirstTimestamp.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// FirstTime returns the time the event was first observed.
+func FirstTime(event *v1.Event) time.Time {
+	switch {
+	case !event.FirstTimestamp.IsZero():
+		return event.FirstTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	case !event.CreationTimestamp.IsZero():
+		return event.CreationTimestamp.Time
+	}
+	return LastTime(event)
+}
+
+// Count returns the number of times the event happened. Events created
+// without a count are treated as a single occurrence.
+func Count(event *v1.Event) int32 {
+	if event.Series != nil && event.Series.Count > event.Count {
+		return event.Series.Count
+	}
+	if event.Count < 1 {
+		return 1
+	}
+	return event.Count
+}
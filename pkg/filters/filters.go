@@ -44,3 +44,60 @@ func (e *EventTypeFilter) Filter(event *v1.Event) bool {
 	}
 	return false
 }
+
+type EventReasonFilter struct {
+	AllowedReasons []string
+}
+
+func NewEventReasonFilter(allowedReasons []string) *EventReasonFilter {
+	return &EventReasonFilter{
+		AllowedReasons: allowedReasons,
+	}
+}
+
+func (e *EventReasonFilter) Filter(event *v1.Event) bool {
+	for _, allowedReason := range e.AllowedReasons {
+		if event.Reason == allowedReason {
+			return true
+		}
+	}
+	return false
+}
+
+type NamespaceFilter struct {
+	AllowedNamespaces []string
+}
+
+func NewNamespaceFilter(allowedNamespaces []string) *NamespaceFilter {
+	return &NamespaceFilter{
+		AllowedNamespaces: allowedNamespaces,
+	}
+}
+
+func (n *NamespaceFilter) Filter(event *v1.Event) bool {
+	for _, allowedNamespace := range n.AllowedNamespaces {
+		if event.InvolvedObject.Namespace == allowedNamespace || event.Namespace == allowedNamespace {
+			return true
+		}
+	}
+	return false
+}
+
+type InvolvedObjectKindFilter struct {
+	AllowedKinds []string
+}
+
+func NewInvolvedObjectKindFilter(allowedKinds []string) *InvolvedObjectKindFilter {
+	return &InvolvedObjectKindFilter{
+		AllowedKinds: allowedKinds,
+	}
+}
+
+func (i *InvolvedObjectKindFilter) Filter(event *v1.Event) bool {
+	for _, allowedKind := range i.AllowedKinds {
+		if strings.EqualFold(event.InvolvedObject.Kind, allowedKind) {
+			return true
+		}
+	}
+	return false
+}
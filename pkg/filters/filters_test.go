@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestEventTypeFilter_Filter(t *testing.T) {
@@ -83,3 +84,60 @@ func TestEventTypeFilter_Filter(t *testing.T) {
 		})
 	}
 }
+
+func TestEventReasonFilter_Filter(t *testing.T) {
+	e := NewEventReasonFilter([]string{"BackOff", "Evicted"})
+	if !e.Filter(&v1.Event{Reason: "BackOff"}) {
+		t.Errorf("Filter() = false for allowed reason")
+	}
+	if e.Filter(&v1.Event{Reason: "Pulled"}) {
+		t.Errorf("Filter() = true for reason that is not allowed")
+	}
+}
+
+func TestNamespaceFilter_Filter(t *testing.T) {
+	n := NewNamespaceFilter([]string{"kube-system"})
+	tests := []struct {
+		name  string
+		event *v1.Event
+		want  bool
+	}{
+		{
+			name:  "involved object in namespace",
+			event: &v1.Event{InvolvedObject: v1.ObjectReference{Namespace: "kube-system"}},
+			want:  true,
+		},
+		{
+			name: "cluster scoped object, event in namespace",
+			event: &v1.Event{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system"},
+			},
+			want: true,
+		},
+		{
+			name: "other namespace",
+			event: &v1.Event{
+				ObjectMeta:     metav1.ObjectMeta{Namespace: "default"},
+				InvolvedObject: v1.ObjectReference{Namespace: "default"},
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := n.Filter(tt.event); got != tt.want {
+				t.Errorf("Filter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvolvedObjectKindFilter_Filter(t *testing.T) {
+	i := NewInvolvedObjectKindFilter([]string{"pod"})
+	if !i.Filter(&v1.Event{InvolvedObject: v1.ObjectReference{Kind: "Pod"}}) {
+		t.Errorf("Filter() = false for allowed kind")
+	}
+	if i.Filter(&v1.Event{InvolvedObject: v1.ObjectReference{Kind: "Node"}}) {
+		t.Errorf("Filter() = true for kind that is not allowed")
+	}
+}
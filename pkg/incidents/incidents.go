@@ -0,0 +1,356 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package incidents
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/caicloud/event_exporter/pkg/eventutil"
+	"github.com/caicloud/event_exporter/pkg/filters"
+	"github.com/caicloud/event_exporter/pkg/notify"
+	"github.com/caicloud/event_exporter/pkg/workload"
+)
+
+// maxClosed is the number of closed incidents kept for the HTTP API.
+const maxClosed = 100
+
+// Incident is a group of related events, that share an involved object, the
+// workload owning it or the node it runs on.
+type Incident struct {
+	ID        string           `json:"id"`
+	Open      bool             `json:"open"`
+	FirstSeen time.Time        `json:"firstSeen"`
+	LastSeen  time.Time        `json:"lastSeen"`
+	Count     int32            `json:"count"`
+	Reasons   map[string]int32 `json:"reasons"`
+	Objects   []string         `json:"objects"`
+	Workloads []string         `json:"workloads,omitempty"`
+	Nodes     []string         `json:"nodes,omitempty"`
+}
+
+type incident struct {
+	id        string
+	firstSeen time.Time
+	lastSeen  time.Time
+	count     int32
+	reasons   map[string]int32
+	objects   sets.String
+	workloads sets.String
+	nodes     sets.String
+	// first is the involved object of the first event, synthesized events
+	// about the incident refer to it.
+	first    v1.ObjectReference
+	notified bool
+}
+
+// keys returns the keys events are grouped into the incident by.
+func (i *incident) keys() []string {
+	var keys []string
+	for _, object := range i.objects.UnsortedList() {
+		keys = append(keys, "object:"+object)
+	}
+	for _, w := range i.workloads.UnsortedList() {
+		keys = append(keys, "workload:"+w)
+	}
+	for _, node := range i.nodes.UnsortedList() {
+		keys = append(keys, "node:"+node)
+	}
+	return keys
+}
+
+func (i *incident) snapshot(open bool) Incident {
+	reasons := make(map[string]int32, len(i.reasons))
+	for reason, count := range i.reasons {
+		reasons[reason] = count
+	}
+	return Incident{
+		ID:        i.id,
+		Open:      open,
+		FirstSeen: i.firstSeen,
+		LastSeen:  i.lastSeen,
+		Count:     i.count,
+		Reasons:   reasons,
+		Objects:   i.objects.List(),
+		Workloads: i.workloads.List(),
+		Nodes:     i.nodes.List(),
+	}
+}
+
+// Grouper groups related events into incidents. An incident stays open until
+// none of its events recurred for the window.
+type Grouper struct {
+	filter    filters.EventFilter
+	resolver  *workload.Resolver
+	window    time.Duration
+	groupWait time.Duration
+	notifier  notify.Notifier
+	now       func() time.Time
+
+	locker sync.Mutex
+	open   map[string]*incident
+	// index maps every key of the open incidents to the incident.
+	index  map[string]*incident
+	closed []Incident
+	seq    int64
+}
+
+// New returns a grouper of the events passing filter. The resolver, if not
+// nil, is used to group events by workload and node.
+func New(filter filters.EventFilter, resolver *workload.Resolver, window, groupWait time.Duration) (*Grouper, error) {
+	if window <= 0 {
+		return nil, fmt.Errorf("incident window must be positive, got %s", window)
+	}
+	return &Grouper{
+		filter:    filter,
+		resolver:  resolver,
+		window:    window,
+		groupWait: groupWait,
+		now:       time.Now,
+		open:      make(map[string]*incident),
+		index:     make(map[string]*incident),
+	}, nil
+}
+
+// SetNotifier makes the grouper forward a synthesized event summarizing an
+// incident once it has been open for the group wait, and once it is closed.
+func (g *Grouper) SetNotifier(n notify.Notifier) {
+	g.notifier = n
+}
+
+// Absorbs implements collector.Absorber. Once the grouper forwards the
+// incidents, the events it groups aren't forwarded one by one.
+func (g *Grouper) Absorbs(event *v1.Event) bool {
+	return g.notifier != nil && g.filter.Filter(event)
+}
+
+// Run notifies and closes incidents until stopCh is closed.
+func (g *Grouper) Run(stopCh <-chan struct{}) {
+	wait.Until(g.tick, time.Second, stopCh)
+}
+
+// Observe implements collector.Observer.
+func (g *Grouper) Observe(event *v1.Event, occurrences int32) {
+	if occurrences <= 0 || !g.filter.Filter(event) {
+		return
+	}
+	last := eventutil.LastTime(event)
+	if g.now().Sub(last) > g.window {
+		return
+	}
+	object := objectKey(event.InvolvedObject)
+	var w, node string
+	if g.resolver != nil {
+		ref := g.resolver.Resolve(event.InvolvedObject)
+		if ref.Kind != event.InvolvedObject.Kind || ref.Name != event.InvolvedObject.Name {
+			w = ref.Kind + "/" + ref.Namespace + "/" + ref.Name
+		}
+		node = g.resolver.NodeName(event.InvolvedObject)
+	}
+	if node == "" && event.InvolvedObject.Kind == "Node" {
+		node = event.InvolvedObject.Name
+		if node == "" {
+			node = event.Source.Host
+		}
+	}
+
+	g.locker.Lock()
+	defer g.locker.Unlock()
+	keys := []string{"object:" + object}
+	if w != "" {
+		keys = append(keys, "workload:"+w)
+	}
+	if node != "" {
+		keys = append(keys, "node:"+node)
+	}
+	i := g.lookup(keys)
+	if i == nil {
+		g.seq++
+		i = &incident{
+			id:        strconv.FormatInt(last.Unix(), 36) + "-" + strconv.FormatInt(g.seq, 36),
+			firstSeen: last,
+			reasons:   make(map[string]int32),
+			objects:   sets.NewString(),
+			workloads: sets.NewString(),
+			nodes:     sets.NewString(),
+			first:     event.InvolvedObject,
+		}
+		g.open[i.id] = i
+	}
+	if last.After(i.lastSeen) {
+		i.lastSeen = last
+	}
+	i.count += occurrences
+	i.reasons[event.Reason] += occurrences
+	i.objects.Insert(object)
+	if w != "" {
+		i.workloads.Insert(w)
+	}
+	if node != "" {
+		i.nodes.Insert(node)
+	}
+	for _, key := range keys {
+		g.index[key] = i
+	}
+}
+
+// lookup returns the open incident any of the keys belongs to, merging the
+// incidents if the keys belong to several of them.
+func (g *Grouper) lookup(keys []string) *incident {
+	var found *incident
+	for _, key := range keys {
+		i, ok := g.index[key]
+		if !ok || i == found {
+			continue
+		}
+		if found == nil {
+			found = i
+			continue
+		}
+		if i.firstSeen.Before(found.firstSeen) {
+			found, i = i, found
+		}
+		g.merge(found, i)
+	}
+	return found
+}
+
+func (g *Grouper) merge(into, from *incident) {
+	if from.firstSeen.Before(into.firstSeen) {
+		into.firstSeen = from.firstSeen
+	}
+	if from.lastSeen.After(into.lastSeen) {
+		into.lastSeen = from.lastSeen
+	}
+	into.count += from.count
+	for reason, count := range from.reasons {
+		into.reasons[reason] += count
+	}
+	into.objects = into.objects.Union(from.objects)
+	into.workloads = into.workloads.Union(from.workloads)
+	into.nodes = into.nodes.Union(from.nodes)
+	into.notified = into.notified || from.notified
+	for _, key := range from.keys() {
+		g.index[key] = into
+	}
+	delete(g.open, from.id)
+}
+
+func (g *Grouper) tick() {
+	now := g.now()
+	var events []*v1.Event
+	g.locker.Lock()
+	for id, i := range g.open {
+		if now.Sub(i.lastSeen) > g.window {
+			delete(g.open, id)
+			for _, key := range i.keys() {
+				if g.index[key] == i {
+					delete(g.index, key)
+				}
+			}
+			g.closed = append(g.closed, i.snapshot(false))
+			if len(g.closed) > maxClosed {
+				g.closed = g.closed[len(g.closed)-maxClosed:]
+			}
+			if i.notified {
+				events = append(events, incidentEvent(i, "IncidentResolved", v1.EventTypeNormal))
+			}
+			continue
+		}
+		if !i.notified && now.Sub(i.firstSeen) >= g.groupWait {
+			i.notified = true
+			events = append(events, incidentEvent(i, "IncidentOpened", v1.EventTypeWarning))
+		}
+	}
+	g.locker.Unlock()
+
+	if g.notifier == nil {
+		return
+	}
+	for _, event := range events {
+		g.notifier.Notify(event)
+	}
+}
+
+// Incidents returns the open incidents followed by the most recently closed
+// ones, both ordered by the time they were first seen.
+func (g *Grouper) Incidents() []Incident {
+	g.locker.Lock()
+	defer g.locker.Unlock()
+	incidents := make([]Incident, 0, len(g.open)+len(g.closed))
+	for _, i := range g.open {
+		incidents = append(incidents, i.snapshot(true))
+	}
+	sort.Slice(incidents, func(a, b int) bool {
+		if !incidents[a].FirstSeen.Equal(incidents[b].FirstSeen) {
+			return incidents[a].FirstSeen.Before(incidents[b].FirstSeen)
+		}
+		return incidents[a].ID < incidents[b].ID
+	})
+	return append(incidents, g.closed...)
+}
+
+// ServeHTTP serves the incidents as JSON. The state query parameter narrows
+// them down to the open or closed ones.
+func (g *Grouper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	state := r.URL.Query().Get("state")
+	if state != "" && state != "open" && state != "closed" {
+		http.Error(w, fmt.Sprintf("invalid state %q", state), http.StatusBadRequest)
+		return
+	}
+	incidents := []Incident{}
+	for _, i := range g.Incidents() {
+		if state == "" || i.Open == (state == "open") {
+			incidents = append(incidents, i)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(incidents); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func incidentEvent(i *incident, reason, eventType string) *v1.Event {
+	reasons := make([]string, 0, len(i.reasons))
+	for reason, count := range i.reasons {
+		reasons = append(reasons, fmt.Sprintf("%s x%d", reason, count))
+	}
+	sort.Strings(reasons)
+	message := fmt.Sprintf("incident %s: %d events on %d objects since %s (%s)",
+		i.id, i.count, i.objects.Len(), i.firstSeen.Format(time.RFC3339), strings.Join(reasons, ", "))
+	if i.nodes.Len() > 0 {
+		message += ", nodes: " + strings.Join(i.nodes.List(), ", ")
+	}
+	return notify.NewEvent(i.first, eventType, reason, message)
+}
+
+func objectKey(object v1.ObjectReference) string {
+	if object.Namespace == "" {
+		return object.Kind + "/" + object.Name
+	}
+	return object.Kind + "/" + object.Namespace + "/" + object.Name
+}
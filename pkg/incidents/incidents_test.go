@@ -0,0 +1,154 @@
+package incidents
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/caicloud/event_exporter/pkg/filters"
+	"github.com/caicloud/event_exporter/pkg/workload"
+)
+
+type recorder struct {
+	events []*v1.Event
+}
+
+func (r *recorder) Notify(event *v1.Event) {
+	r.events = append(r.events, event)
+}
+
+// newResolver returns a resolver and the indexer of the pods it resolves.
+func newResolver() (*workload.Resolver, cache.Indexer) {
+	factory := informers.NewSharedInformerFactory(nil, 0)
+	resolver := workload.NewResolver(factory)
+	return resolver, factory.Core().V1().Pods().Informer().GetIndexer()
+}
+
+func newPod(name, node string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{Namespace: "default", Name: name},
+		Spec:       v1.PodSpec{NodeName: node},
+	}
+}
+
+func TestGrouper(t *testing.T) {
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	resolver, pods := newResolver()
+	g, err := New(filters.NewEventTypeFilter([]string{"Warning"}), resolver, 5*time.Minute, 30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.now = func() time.Time { return now }
+	normal := &v1.Event{Type: v1.EventTypeNormal}
+	if g.Absorbs(normal) {
+		t.Error("expected events to be forwarded without notifier")
+	}
+	notifications := &recorder{}
+	g.SetNotifier(notifications)
+	if g.Absorbs(normal) || !g.Absorbs(&v1.Event{Type: v1.EventTypeWarning}) {
+		t.Error("expected the grouped events to be absorbed")
+	}
+
+	g.Observe(&v1.Event{
+		InvolvedObject: v1.ObjectReference{Kind: "Node", Name: "node-1"},
+		Reason:         "NodeNotReady",
+		Type:           v1.EventTypeWarning,
+		LastTimestamp:  meta_v1.NewTime(now),
+	}, 1)
+	for i := 0; i < 40; i++ {
+		_ = pods.Add(newPod(fmt.Sprintf("nginx-%d", i), "node-1"))
+		g.Observe(&v1.Event{
+			InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: fmt.Sprintf("nginx-%d", i)},
+			Reason:         "Evicted",
+			Type:           v1.EventTypeWarning,
+			Source:         v1.EventSource{Component: "kubelet", Host: "node-1"},
+			LastTimestamp:  meta_v1.NewTime(now.Add(time.Second)),
+		}, 1)
+	}
+	g.Observe(&v1.Event{
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "unrelated"},
+		Reason:         "BackOff",
+		Type:           v1.EventTypeWarning,
+		Source:         v1.EventSource{Component: "kubelet", Host: "node-2"},
+		LastTimestamp:  meta_v1.NewTime(now),
+	}, 1)
+	g.Observe(&v1.Event{
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "normal"},
+		Reason:         "Pulled",
+		Type:           v1.EventTypeNormal,
+		LastTimestamp:  meta_v1.NewTime(now),
+	}, 1)
+
+	incidents := g.Incidents()
+	if len(incidents) != 2 {
+		t.Fatalf("expected 2 incidents, got %d", len(incidents))
+	}
+	nodeIncident := incidents[0]
+	if nodeIncident.Count != 41 || len(nodeIncident.Objects) != 41 || nodeIncident.Reasons["Evicted"] != 40 {
+		t.Errorf("unexpected node incident %+v", nodeIncident)
+	}
+
+	g.now = func() time.Time { return now.Add(time.Minute) }
+	g.tick()
+	if len(notifications.events) != 2 {
+		t.Fatalf("expected an opened notification per incident, got %d", len(notifications.events))
+	}
+
+	g.now = func() time.Time { return now.Add(10 * time.Minute) }
+	g.tick()
+	if len(notifications.events) != 4 || notifications.events[3].Reason != "IncidentResolved" {
+		t.Fatalf("expected a resolved notification per incident, got %d", len(notifications.events))
+	}
+
+	recorder := httptest.NewRecorder()
+	g.ServeHTTP(recorder, httptest.NewRequest("GET", "/incidents?state=closed", nil))
+	var served []Incident
+	if err := json.NewDecoder(recorder.Body).Decode(&served); err != nil {
+		t.Fatal(err)
+	}
+	if len(served) != 2 || served[0].Open {
+		t.Errorf("expected 2 closed incidents, got %+v", served)
+	}
+}
+
+func TestGrouperMerge(t *testing.T) {
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	resolver, pods := newResolver()
+	g, err := New(filters.NewEventTypeFilter([]string{"Warning"}), resolver, 5*time.Minute, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.now = func() time.Time { return now }
+	pod := func(name string) *v1.Event {
+		return &v1.Event{
+			InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: name},
+			Reason:         "Unhealthy",
+			Type:           v1.EventTypeWarning,
+			Source:         v1.EventSource{Host: "node-1"},
+			LastTimestamp:  meta_v1.NewTime(now),
+		}
+	}
+	_ = pods.Add(newPod("a", "node-1"))
+	_ = pods.Add(newPod("b", "node-2"))
+	g.Observe(pod("a"), 1)
+	g.Observe(pod("b"), 1)
+	// Pods that can't be resolved aren't grouped by the host reporting them.
+	g.Observe(pod("c"), 1)
+	if got := len(g.Incidents()); got != 3 {
+		t.Fatalf("expected 3 incidents, got %d", got)
+	}
+	// Pod a moved to node-2, which links both incidents.
+	_ = pods.Update(newPod("a", "node-2"))
+	g.Observe(pod("a"), 1)
+	incidents := g.Incidents()
+	if len(incidents) != 2 || incidents[0].Count != 3 || len(incidents[0].Nodes) != 2 {
+		t.Errorf("expected incidents to be merged, got %+v", incidents)
+	}
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package exportutil holds the helpers shared by the exporters that encode
+// the collected events and metrics as protocol buffers by hand.
+package exportutil
+
+import (
+	"math"
+	"regexp"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// LabelNamePattern matches the valid Prometheus label names.
+var LabelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// HasPrefix reports whether the metric name has one of the prefixes. Every
+// name matches an empty list.
+func HasPrefix(name string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// AppendMessage appends an embedded message field, even if it is empty.
+func AppendMessage(b []byte, num protowire.Number, message []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, message)
+}
+
+// AppendString appends a string field, omitting empty values like proto3
+// serializers do.
+func AppendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// AppendVarint appends a varint field, omitting zero values.
+func AppendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// AppendFixed64 appends a fixed64 field, zero values included.
+func AppendFixed64(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, v)
+}
+
+// AppendDouble appends a double field, zero values included.
+func AppendDouble(b []byte, num protowire.Number, v float64) []byte {
+	return AppendFixed64(b, num, math.Float64bits(v))
+}
@@ -0,0 +1,35 @@
+package exportutil
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHasPrefix(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		prefixes []string
+		want     bool
+	}{
+		{"kube_event_count", nil, true},
+		{"kube_event_count", []string{"go_", "kube_event_"}, true},
+		{"go_goroutines", []string{"kube_event_"}, false},
+	} {
+		if got := HasPrefix(c.name, c.prefixes); got != c.want {
+			t.Errorf("HasPrefix(%q, %q): got %v, want %v", c.name, c.prefixes, got, c.want)
+		}
+	}
+}
+
+func TestAppend(t *testing.T) {
+	var b []byte
+	b = AppendString(b, 1, "")
+	b = AppendVarint(b, 2, 0)
+	b = AppendMessage(b, 3, nil)
+	b = AppendString(b, 4, "a")
+	b = AppendVarint(b, 5, 1)
+	want := []byte{3<<3 | 2, 0, 4<<3 | 2, 1, 'a', 5 << 3, 1}
+	if !bytes.Equal(b, want) {
+		t.Errorf("got %v, want %v", b, want)
+	}
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// Component is the source component of events synthesized by the exporter.
+const Component = "event-exporter"
+
+// Notifier forwards events that the exporter synthesizes itself, such as
+// alerts raised by the detectors.
+type Notifier interface {
+	Notify(event *v1.Event)
+}
+
+// LogNotifier writes synthesized events to the log.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(event *v1.Event) {
+	klog.Infof(
+		"synthesized event reason: %s,type: %s,involvedObject_namespace: %s,involvedObject_kind: %s,involvedObject_name: %s,message: %s",
+		event.Reason,
+		event.Type,
+		event.InvolvedObject.Namespace,
+		event.InvolvedObject.Kind,
+		event.InvolvedObject.Name,
+		event.Message,
+	)
+}
+
+// NewEvent builds a synthesized event about the given object. Like events
+// recorded by Kubernetes components, its name is derived from the object name
+// and the current time.
+func NewEvent(object v1.ObjectReference, eventType, reason, message string) *v1.Event {
+	now := meta_v1.NewTime(time.Now())
+	namespace := object.Namespace
+	if namespace == "" {
+		namespace = meta_v1.NamespaceDefault
+	}
+	return &v1.Event{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      fmt.Sprintf("%v.%x", object.Name, now.UnixNano()),
+			Namespace: namespace,
+		},
+		InvolvedObject: object,
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Count:          1,
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Source:         v1.EventSource{Component: Component},
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/pflag"
 	"k8s.io/klog/v2"
@@ -28,12 +29,39 @@ import (
 type Options struct {
 	KubeMasterURL  string
 	KubeConfigPath string
+	ConfigPath     string
 	EventType      []string
 	Port           int
 	Version        bool
+	ProblemPairs   []string
+	ProblemTimeout time.Duration
+	Detectors      []string
+	DetectorWindow time.Duration
+	DetectorAlerts bool
+	Anomaly        AnomalyOptions
+	TopN           int
+	TopWindows     []time.Duration
+	Incidents      IncidentOptions
 	flag           *pflag.FlagSet
 }
 
+// AnomalyOptions configures the event rate anomaly detection.
+type AnomalyOptions struct {
+	Enabled   bool
+	Window    time.Duration
+	Baseline  time.Duration
+	Threshold float64
+	Alerts    bool
+}
+
+// IncidentOptions configures the grouping of related events into incidents.
+type IncidentOptions struct {
+	Enabled   bool
+	Window    time.Duration
+	GroupWait time.Duration
+	Alerts    bool
+}
+
 func NewOptions() *Options {
 	return &Options{}
 }
@@ -47,9 +75,26 @@ func (o *Options) AddFlags() {
 
 	o.flag.StringVar(&o.KubeMasterURL, "kubeMasterURL", "", "The URL of kubernetes apiserver to use as a master")
 	o.flag.StringVar(&o.KubeConfigPath, "kubeConfigPath", "", "The path of kubernetes configuration file")
+	o.flag.StringVar(&o.ConfigPath, "config", "", "The path of the exporter configuration file, that configures the sinks events are forwarded to")
 	o.flag.StringArrayVar(&o.EventType, "eventType", []string{"Warning"}, "List of allowed event types. Default to warning type.")
 	o.flag.IntVar(&o.Port, "port", 9102, "Port to expose event metrics on")
 	o.flag.BoolVar(&o.Version, "version", false, "event exporter version information")
+	o.flag.StringArrayVar(&o.ProblemPairs, "problemPair", nil, "List of <problem>:<resolution> event reason pairs to track time to recover for. An empty resolution resolves the problem once it stops recurring.")
+	o.flag.DurationVar(&o.ProblemTimeout, "problemTimeout", time.Hour, "How long an open problem may go without recurring before it is dropped, or resolved if its pair has no resolution")
+	o.flag.StringArrayVar(&o.Detectors, "detector", nil, "List of built-in detectors to enable: crashloop, oomkill, eviction and preemption")
+	o.flag.DurationVar(&o.DetectorWindow, "detectorWindow", 10*time.Minute, "How long a pod is considered crash looping after its last back-off. Older events don't raise detector alerts.")
+	o.flag.BoolVar(&o.DetectorAlerts, "detectorAlerts", false, "Forward a synthesized alert event whenever a detector fires")
+	o.flag.BoolVar(&o.Anomaly.Enabled, "anomalyDetection", false, "Score the event rate of every reason in every namespace against its baseline")
+	o.flag.DurationVar(&o.Anomaly.Window, "anomalyWindow", 5*time.Minute, "Sliding window the event rate is measured over")
+	o.flag.DurationVar(&o.Anomaly.Baseline, "anomalyBaseline", time.Hour, "Time constant of the exponentially weighted baseline event rate")
+	o.flag.Float64Var(&o.Anomaly.Threshold, "anomalyThreshold", 3, "Anomaly score from which an event rate is considered a spike")
+	o.flag.BoolVar(&o.Anomaly.Alerts, "anomalyAlerts", false, "Forward a synthesized event whenever an event rate spikes")
+	o.flag.IntVar(&o.TopN, "topN", 0, "Number of top involved objects, reasons and source components by event volume to serve on /top and export. Zero disables the report.")
+	o.flag.DurationSliceVar(&o.TopWindows, "topWindow", []time.Duration{5 * time.Minute, time.Hour, 24 * time.Hour}, "List of windows to rank event volume over")
+	o.flag.BoolVar(&o.Incidents.Enabled, "incidents", false, "Group allowed events sharing an involved object, workload or node into incidents served on /incidents")
+	o.flag.DurationVar(&o.Incidents.Window, "incidentWindow", 5*time.Minute, "How long an incident stays open after its last event")
+	o.flag.DurationVar(&o.Incidents.GroupWait, "incidentGroupWait", 30*time.Second, "How long to wait for related events before forwarding a new incident")
+	o.flag.BoolVar(&o.Incidents.Alerts, "incidentAlerts", false, "Forward a synthesized event when an incident is opened and when it is resolved, instead of one per event")
 
 	o.flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
@@ -4,6 +4,7 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestOptionsParse(t *testing.T) {
@@ -12,6 +13,18 @@ func TestOptionsParse(t *testing.T) {
 	defaultVersion := false
 	defaultKubeMasterURL := ""
 	defaultKubeConfigPath := ""
+	defaultProblemTimeout := time.Hour
+	defaultDetectorWindow := 10 * time.Minute
+	defaultTopWindows := []time.Duration{5 * time.Minute, time.Hour, 24 * time.Hour}
+	defaultIncidents := IncidentOptions{
+		Window:    5 * time.Minute,
+		GroupWait: 30 * time.Second,
+	}
+	defaultAnomaly := AnomalyOptions{
+		Window:    5 * time.Minute,
+		Baseline:  time.Hour,
+		Threshold: 3,
+	}
 
 	tests := []struct {
 		Name     string
@@ -27,6 +40,11 @@ func TestOptionsParse(t *testing.T) {
 				KubeConfigPath: defaultKubeConfigPath,
 				KubeMasterURL:  defaultKubeMasterURL,
 				Version:        true,
+				ProblemTimeout: defaultProblemTimeout,
+				DetectorWindow: defaultDetectorWindow,
+				Anomaly:        defaultAnomaly,
+				TopWindows:     defaultTopWindows,
+				Incidents:      defaultIncidents,
 			},
 		},
 		{
@@ -41,6 +59,11 @@ func TestOptionsParse(t *testing.T) {
 				EventType:      defaultEventTypes,
 				Port:           8090,
 				Version:        defaultVersion,
+				ProblemTimeout: defaultProblemTimeout,
+				DetectorWindow: defaultDetectorWindow,
+				Anomaly:        defaultAnomaly,
+				TopWindows:     defaultTopWindows,
+				Incidents:      defaultIncidents,
 			},
 		},
 		{
@@ -55,6 +78,147 @@ func TestOptionsParse(t *testing.T) {
 				EventType:      []string{"Normal", "Warning"},
 				Port:           defaultPort,
 				Version:        defaultVersion,
+				ProblemTimeout: defaultProblemTimeout,
+				DetectorWindow: defaultDetectorWindow,
+				Anomaly:        defaultAnomaly,
+				TopWindows:     defaultTopWindows,
+				Incidents:      defaultIncidents,
+			},
+		},
+		{
+			Name: "exporter problem pairs",
+			Args: []string{"./event_exporter",
+				"--problemPair=FailedScheduling:Scheduled",
+				"--problemPair=Unhealthy:",
+				"--problemTimeout=30m",
+			},
+			Expected: &Options{
+				KubeMasterURL:  defaultKubeMasterURL,
+				KubeConfigPath: defaultKubeConfigPath,
+				EventType:      defaultEventTypes,
+				Port:           defaultPort,
+				Version:        defaultVersion,
+				ProblemPairs:   []string{"FailedScheduling:Scheduled", "Unhealthy:"},
+				ProblemTimeout: 30 * time.Minute,
+				DetectorWindow: defaultDetectorWindow,
+				Anomaly:        defaultAnomaly,
+				TopWindows:     defaultTopWindows,
+				Incidents:      defaultIncidents,
+			},
+		},
+		{
+			Name: "exporter detectors",
+			Args: []string{"./event_exporter",
+				"--detector=crashloop",
+				"--detector=oomkill",
+				"--detectorWindow=5m",
+				"--detectorAlerts",
+			},
+			Expected: &Options{
+				KubeMasterURL:  defaultKubeMasterURL,
+				KubeConfigPath: defaultKubeConfigPath,
+				EventType:      defaultEventTypes,
+				Port:           defaultPort,
+				Version:        defaultVersion,
+				ProblemTimeout: defaultProblemTimeout,
+				Detectors:      []string{"crashloop", "oomkill"},
+				DetectorWindow: 5 * time.Minute,
+				DetectorAlerts: true,
+				Anomaly:        defaultAnomaly,
+				TopWindows:     defaultTopWindows,
+				Incidents:      defaultIncidents,
+			},
+		},
+		{
+			Name: "exporter anomaly detection",
+			Args: []string{"./event_exporter",
+				"--anomalyDetection",
+				"--anomalyWindow=10m",
+				"--anomalyThreshold=4.5",
+				"--anomalyAlerts",
+			},
+			Expected: &Options{
+				KubeMasterURL:  defaultKubeMasterURL,
+				KubeConfigPath: defaultKubeConfigPath,
+				EventType:      defaultEventTypes,
+				Port:           defaultPort,
+				Version:        defaultVersion,
+				ProblemTimeout: defaultProblemTimeout,
+				DetectorWindow: defaultDetectorWindow,
+				Anomaly: AnomalyOptions{
+					Enabled:   true,
+					Window:    10 * time.Minute,
+					Baseline:  time.Hour,
+					Threshold: 4.5,
+					Alerts:    true,
+				},
+				TopWindows: defaultTopWindows,
+				Incidents:  defaultIncidents,
+			},
+		},
+		{
+			Name: "exporter top n",
+			Args: []string{"./event_exporter",
+				"--topN=20",
+				"--topWindow=10m,6h",
+			},
+			Expected: &Options{
+				KubeMasterURL:  defaultKubeMasterURL,
+				KubeConfigPath: defaultKubeConfigPath,
+				EventType:      defaultEventTypes,
+				Port:           defaultPort,
+				Version:        defaultVersion,
+				ProblemTimeout: defaultProblemTimeout,
+				DetectorWindow: defaultDetectorWindow,
+				Anomaly:        defaultAnomaly,
+				TopN:           20,
+				TopWindows:     []time.Duration{10 * time.Minute, 6 * time.Hour},
+				Incidents:      defaultIncidents,
+			},
+		},
+		{
+			Name: "exporter incidents",
+			Args: []string{"./event_exporter",
+				"--incidents",
+				"--incidentWindow=10m",
+				"--incidentGroupWait=1m",
+				"--incidentAlerts",
+			},
+			Expected: &Options{
+				KubeMasterURL:  defaultKubeMasterURL,
+				KubeConfigPath: defaultKubeConfigPath,
+				EventType:      defaultEventTypes,
+				Port:           defaultPort,
+				Version:        defaultVersion,
+				ProblemTimeout: defaultProblemTimeout,
+				DetectorWindow: defaultDetectorWindow,
+				Anomaly:        defaultAnomaly,
+				TopWindows:     defaultTopWindows,
+				Incidents: IncidentOptions{
+					Enabled:   true,
+					Window:    10 * time.Minute,
+					GroupWait: time.Minute,
+					Alerts:    true,
+				},
+			},
+		},
+		{
+			Name: "exporter configuration file",
+			Args: []string{"./event_exporter",
+				"--config=/etc/event_exporter/config.yaml",
+			},
+			Expected: &Options{
+				KubeMasterURL:  defaultKubeMasterURL,
+				KubeConfigPath: defaultKubeConfigPath,
+				ConfigPath:     "/etc/event_exporter/config.yaml",
+				EventType:      defaultEventTypes,
+				Port:           defaultPort,
+				Version:        defaultVersion,
+				ProblemTimeout: defaultProblemTimeout,
+				DetectorWindow: defaultDetectorWindow,
+				Anomaly:        defaultAnomaly,
+				TopWindows:     defaultTopWindows,
+				Incidents:      defaultIncidents,
 			},
 		},
 		{
@@ -66,6 +230,11 @@ func TestOptionsParse(t *testing.T) {
 				EventType:      defaultEventTypes,
 				Port:           defaultPort,
 				Version:        defaultVersion,
+				ProblemTimeout: defaultProblemTimeout,
+				DetectorWindow: defaultDetectorWindow,
+				Anomaly:        defaultAnomaly,
+				TopWindows:     defaultTopWindows,
+				Incidents:      defaultIncidents,
 			},
 		},
 	}
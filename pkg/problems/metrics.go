@@ -0,0 +1,55 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package problems
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	openProblems = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "kube_event",
+		Subsystem: "",
+		Name:      "open_problems",
+		Help:      "Number of problems that were opened by a problem event and are not resolved yet",
+	}, []string{"involved_object_namespace", "involved_object_kind", "problem", "resolution"})
+	resolveDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "kube_event",
+		Subsystem: "",
+		Name:      "problem_resolve_duration_seconds",
+		Help:      "Time from a problem event until its resolution event",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 9),
+	}, []string{"involved_object_kind", "problem", "resolution"})
+)
+
+func problemLabels(key problemKey) prometheus.Labels {
+	return prometheus.Labels{
+		"involved_object_namespace": key.object.namespace,
+		"involved_object_kind":      key.object.kind,
+		"problem":                   key.pair.Problem,
+		"resolution":                key.pair.Resolution,
+	}
+}
+
+func resolveLabels(key problemKey) prometheus.Labels {
+	return prometheus.Labels{
+		"involved_object_kind": key.object.kind,
+		"problem":              key.pair.Problem,
+		"resolution":           key.pair.Resolution,
+	}
+}
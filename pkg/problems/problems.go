@@ -0,0 +1,181 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package problems
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"github.com/caicloud/event_exporter/pkg/eventutil"
+)
+
+// Pair links the event reason that opens a problem on an object to the reason
+// that resolves it. A pair without a resolution reason is considered resolved
+// once the problem event stops recurring for the tracker's timeout.
+type Pair struct {
+	Problem    string
+	Resolution string
+}
+
+// ParsePairs parses pairs formatted as <problem>:<resolution>.
+func ParsePairs(values []string) ([]Pair, error) {
+	pairs := make([]Pair, 0, len(values))
+	for _, value := range values {
+		parts := strings.SplitN(value, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid problem pair %q, expected <problem>:<resolution>", value)
+		}
+		pairs = append(pairs, Pair{Problem: parts[0], Resolution: parts[1]})
+	}
+	return pairs, nil
+}
+
+type objectKey struct {
+	namespace string
+	kind      string
+	name      string
+}
+
+type problemKey struct {
+	object objectKey
+	pair   Pair
+}
+
+type problem struct {
+	opened   time.Time
+	lastSeen time.Time
+}
+
+// Tracker pairs problem events with their resolution events per involved
+// object, and exports the open problems and the time they took to resolve.
+type Tracker struct {
+	pairs   []Pair
+	timeout time.Duration
+	now     func() time.Time
+
+	locker sync.Mutex
+	open   map[problemKey]*problem
+	// resolved remembers when a problem was last resolved, so that stale
+	// problem events replayed by the informer don't reopen it.
+	resolved map[problemKey]time.Time
+}
+
+// NewTracker returns a tracker for the given pairs. Open problems that have
+// not recurred within timeout are dropped, or resolved if their pair has no
+// resolution reason.
+func NewTracker(pairs []Pair, timeout time.Duration) *Tracker {
+	return &Tracker{
+		pairs:    pairs,
+		timeout:  timeout,
+		now:      time.Now,
+		open:     make(map[problemKey]*problem),
+		resolved: make(map[problemKey]time.Time),
+	}
+}
+
+// Run periodically expires open problems until stopCh is closed.
+func (t *Tracker) Run(stopCh <-chan struct{}) {
+	wait.Until(t.expire, time.Minute, stopCh)
+}
+
+// Observe implements collector.Observer.
+func (t *Tracker) Observe(event *v1.Event, _ int32) {
+	object := objectKey{
+		namespace: event.InvolvedObject.Namespace,
+		kind:      event.InvolvedObject.Kind,
+		name:      event.InvolvedObject.Name,
+	}
+	t.locker.Lock()
+	defer t.locker.Unlock()
+	for _, pair := range t.pairs {
+		key := problemKey{object: object, pair: pair}
+		switch event.Reason {
+		case pair.Problem:
+			t.openProblem(key, eventutil.FirstTime(event), eventutil.LastTime(event))
+		case pair.Resolution:
+			if pair.Resolution != "" {
+				t.resolveProblem(key, eventutil.LastTime(event))
+			}
+		}
+	}
+}
+
+func (t *Tracker) openProblem(key problemKey, first, last time.Time) {
+	resolvedAt, resolved := t.resolved[key]
+	if resolved && !last.After(resolvedAt) {
+		return
+	}
+	if p, ok := t.open[key]; ok {
+		if last.After(p.lastSeen) {
+			p.lastSeen = last
+		}
+		return
+	}
+	if resolved && !first.After(resolvedAt) {
+		first = last
+	}
+	t.open[key] = &problem{opened: first, lastSeen: last}
+	openProblems.With(problemLabels(key)).Inc()
+}
+
+func (t *Tracker) resolveProblem(key problemKey, at time.Time) {
+	if resolvedAt, ok := t.resolved[key]; !ok || at.After(resolvedAt) {
+		t.resolved[key] = at
+	}
+	p, ok := t.open[key]
+	if !ok || !at.After(p.opened) {
+		return
+	}
+	t.closeProblem(key, p, at)
+}
+
+func (t *Tracker) closeProblem(key problemKey, p *problem, at time.Time) {
+	delete(t.open, key)
+	openProblems.With(problemLabels(key)).Dec()
+	resolveDuration.With(resolveLabels(key)).Observe(at.Sub(p.opened).Seconds())
+	klog.V(4).Infof("problem %s on %s %s/%s resolved after %s",
+		key.pair.Problem, key.object.kind, key.object.namespace, key.object.name, at.Sub(p.opened))
+}
+
+func (t *Tracker) expire() {
+	now := t.now()
+	t.locker.Lock()
+	defer t.locker.Unlock()
+	for key, p := range t.open {
+		if now.Sub(p.lastSeen) <= t.timeout {
+			continue
+		}
+		if key.pair.Resolution == "" {
+			t.resolved[key] = p.lastSeen
+			t.closeProblem(key, p, p.lastSeen)
+			continue
+		}
+		delete(t.open, key)
+		openProblems.With(problemLabels(key)).Dec()
+	}
+	for key, at := range t.resolved {
+		if now.Sub(at) > t.timeout {
+			delete(t.resolved, key)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package problems
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/event_exporter/pkg/utils"
+)
+
+func newEvent(reason string, first, last time.Time) *v1.Event {
+	return &v1.Event{
+		InvolvedObject: v1.ObjectReference{
+			Kind:      "Pod",
+			Namespace: "default",
+			Name:      "nginx-0",
+		},
+		Reason:         reason,
+		FirstTimestamp: meta_v1.NewTime(first),
+		LastTimestamp:  meta_v1.NewTime(last),
+	}
+}
+
+func TestParsePairs(t *testing.T) {
+	pairs, err := ParsePairs([]string{"FailedScheduling:Scheduled", "Unhealthy:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Pair{{Problem: "FailedScheduling", Resolution: "Scheduled"}, {Problem: "Unhealthy"}}
+	if !reflect.DeepEqual(pairs, want) {
+		t.Errorf("ParsePairs() = %v, want %v", pairs, want)
+	}
+	for _, invalid := range []string{"FailedScheduling", ":Scheduled"} {
+		if _, err := ParsePairs([]string{invalid}); err == nil {
+			t.Errorf("ParsePairs(%q) expected error", invalid)
+		}
+	}
+}
+
+func TestTracker(t *testing.T) {
+	start := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+	tracker := NewTracker([]Pair{{Problem: "FailedScheduling", Resolution: "Scheduled"}}, time.Hour)
+
+	tracker.Observe(newEvent("FailedScheduling", start, start.Add(time.Minute)), 1)
+	tracker.Observe(newEvent("FailedScheduling", start, start.Add(2*time.Minute)), 1)
+	if len(tracker.open) != 1 {
+		t.Fatalf("expected 1 open problem, got %d", len(tracker.open))
+	}
+	var testcases utils.MetricsTestCases = map[string]utils.MetricsTestCase{
+		"OpenProblems": {
+			Target: openProblems,
+			Want: `
+# HELP kube_event_open_problems Number of problems that were opened by a problem event and are not resolved yet
+# TYPE kube_event_open_problems gauge
+kube_event_open_problems{involved_object_kind="Pod",involved_object_namespace="default",problem="FailedScheduling",resolution="Scheduled"} 1
+`,
+		},
+	}
+	testcases.Test(t)
+
+	tracker.Observe(newEvent("Scheduled", start.Add(3*time.Minute), start.Add(3*time.Minute)), 1)
+	if len(tracker.open) != 0 {
+		t.Fatalf("expected problem to be resolved, got %d open", len(tracker.open))
+	}
+	// A stale problem event replayed after the resolution must not reopen it.
+	tracker.Observe(newEvent("FailedScheduling", start, start.Add(2*time.Minute)), 0)
+	if len(tracker.open) != 0 {
+		t.Fatalf("expected stale problem event to be ignored, got %d open", len(tracker.open))
+	}
+	testcases = map[string]utils.MetricsTestCase{
+		"OpenProblems": {
+			Target: openProblems,
+			Want: `
+# HELP kube_event_open_problems Number of problems that were opened by a problem event and are not resolved yet
+# TYPE kube_event_open_problems gauge
+kube_event_open_problems{involved_object_kind="Pod",involved_object_namespace="default",problem="FailedScheduling",resolution="Scheduled"} 0
+`,
+		},
+		"ResolveDuration": {
+			Target:  resolveDuration,
+			Metrics: []string{"kube_event_problem_resolve_duration_seconds"},
+			Want: `
+# HELP kube_event_problem_resolve_duration_seconds Time from a problem event until its resolution event
+# TYPE kube_event_problem_resolve_duration_seconds histogram
+kube_event_problem_resolve_duration_seconds_bucket{involved_object_kind="Pod",problem="FailedScheduling",resolution="Scheduled",le="1"} 0
+kube_event_problem_resolve_duration_seconds_bucket{involved_object_kind="Pod",problem="FailedScheduling",resolution="Scheduled",le="4"} 0
+kube_event_problem_resolve_duration_seconds_bucket{involved_object_kind="Pod",problem="FailedScheduling",resolution="Scheduled",le="16"} 0
+kube_event_problem_resolve_duration_seconds_bucket{involved_object_kind="Pod",problem="FailedScheduling",resolution="Scheduled",le="64"} 0
+kube_event_problem_resolve_duration_seconds_bucket{involved_object_kind="Pod",problem="FailedScheduling",resolution="Scheduled",le="256"} 1
+kube_event_problem_resolve_duration_seconds_bucket{involved_object_kind="Pod",problem="FailedScheduling",resolution="Scheduled",le="1024"} 1
+kube_event_problem_resolve_duration_seconds_bucket{involved_object_kind="Pod",problem="FailedScheduling",resolution="Scheduled",le="4096"} 1
+kube_event_problem_resolve_duration_seconds_bucket{involved_object_kind="Pod",problem="FailedScheduling",resolution="Scheduled",le="16384"} 1
+kube_event_problem_resolve_duration_seconds_bucket{involved_object_kind="Pod",problem="FailedScheduling",resolution="Scheduled",le="65536"} 1
+kube_event_problem_resolve_duration_seconds_bucket{involved_object_kind="Pod",problem="FailedScheduling",resolution="Scheduled",le="+Inf"} 1
+kube_event_problem_resolve_duration_seconds_sum{involved_object_kind="Pod",problem="FailedScheduling",resolution="Scheduled"} 180
+kube_event_problem_resolve_duration_seconds_count{involved_object_kind="Pod",problem="FailedScheduling",resolution="Scheduled"} 1
+`,
+		},
+	}
+	testcases.Test(t)
+}
+
+func TestTrackerExpire(t *testing.T) {
+	start := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+	tracker := NewTracker([]Pair{
+		{Problem: "Unhealthy"},
+		{Problem: "NodeNotReady", Resolution: "NodeReady"},
+	}, time.Hour)
+	tracker.now = func() time.Time { return start.Add(2 * time.Hour) }
+
+	tracker.Observe(newEvent("Unhealthy", start, start.Add(time.Minute)), 1)
+	tracker.Observe(newEvent("NodeNotReady", start, start), 1)
+	tracker.expire()
+	if len(tracker.open) != 0 {
+		t.Errorf("expected all problems to expire, got %d open", len(tracker.open))
+	}
+	unhealthy := problemKey{object: objectKey{namespace: "default", kind: "Pod", name: "nginx-0"}, pair: Pair{Problem: "Unhealthy"}}
+	if _, ok := tracker.resolved[unhealthy]; ok {
+		t.Errorf("expected resolution of expired problem to be pruned")
+	}
+}
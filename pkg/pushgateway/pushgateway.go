@@ -0,0 +1,163 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package pushgateway pushes the metrics of the exporter to a Pushgateway, for
+// clusters that don't live long enough to be scraped.
+package pushgateway
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"github.com/caicloud/event_exporter/pkg/internal/exportutil"
+	"github.com/caicloud/event_exporter/pkg/sinks/httpclient"
+)
+
+const (
+	defaultJob      = "event_exporter"
+	defaultInterval = time.Minute
+)
+
+// Config configures the Pushgateway the metrics are pushed to.
+type Config struct {
+	// URL of the Pushgateway, e.g. http://pushgateway:9091.
+	URL string `json:"url"`
+	// Job is the job label of the group of the metrics (default
+	// event_exporter).
+	Job string `json:"job,omitempty"`
+	// Grouping are the other labels of the group, e.g. the cluster and
+	// instance.
+	Grouping map[string]string `json:"grouping,omitempty"`
+	// Interval between two pushes (default 1m).
+	Interval meta_v1.Duration `json:"interval,omitempty"`
+	// Prefixes of the names of the pushed metrics. Every metric is pushed if
+	// none is set.
+	Prefixes []string `json:"prefixes,omitempty"`
+	httpclient.Config
+}
+
+// Pusher pushes the gathered metrics every interval, replacing the metrics of
+// its group.
+type Pusher struct {
+	config   Config
+	url      string
+	client   *httpclient.Client
+	header   http.Header
+	gatherer prometheus.Gatherer
+	stopCh   <-chan struct{}
+}
+
+// New creates a pusher of the metrics of gatherer.
+func New(config Config, gatherer prometheus.Gatherer) (*Pusher, error) {
+	endpoint, err := url.Parse(config.URL)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid url %q", config.URL)
+	}
+	if config.Job == "" {
+		config.Job = defaultJob
+	}
+	if config.Interval.Duration <= 0 {
+		config.Interval.Duration = defaultInterval
+	}
+	names := make([]string, 0, len(config.Grouping))
+	for name := range config.Grouping {
+		if !exportutil.LabelNamePattern.MatchString(name) || strings.HasPrefix(name, "__") || name == "job" {
+			return nil, fmt.Errorf("invalid grouping label name %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	path := "/metrics/job/" + encodeLabelValue(config.Job)
+	for _, name := range names {
+		path += "/" + name + "/" + encodeLabelValue(config.Grouping[name])
+	}
+
+	client, err := httpclient.New(config.Config)
+	if err != nil {
+		return nil, err
+	}
+	header := make(http.Header)
+	header.Set("Content-Type", string(expfmt.FmtProtoDelim))
+	return &Pusher{
+		config:   config,
+		url:      strings.TrimSuffix(config.URL, "/") + path,
+		client:   client,
+		header:   header,
+		gatherer: gatherer,
+	}, nil
+}
+
+// encodeLabelValue encodes a label value of the path of a group. Values that
+// can't be part of a path segment are base64 encoded.
+func encodeLabelValue(value string) string {
+	if value == "" {
+		return "@base64/="
+	}
+	if strings.Contains(value, "/") {
+		return "@base64/" + base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+	return url.PathEscape(value)
+}
+
+// Run pushes the metrics every interval until stopCh is closed, and a last
+// time afterwards so that the Pushgateway keeps their final value.
+func (p *Pusher) Run(stopCh <-chan struct{}) {
+	p.stopCh = stopCh
+	klog.Infof("pushing metrics to %s every %v", p.url, p.config.Interval.Duration)
+	wait.Until(func() {
+		if err := p.push(); err != nil {
+			runtime.HandleError(err)
+		}
+	}, p.config.Interval.Duration, stopCh)
+	if err := p.push(); err != nil {
+		runtime.HandleError(err)
+	}
+}
+
+// push replaces the metrics of the group by the current ones.
+func (p *Pusher) push() error {
+	families, err := p.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather metrics: %v", err)
+	}
+	var buf bytes.Buffer
+	encoder := expfmt.NewEncoder(&buf, expfmt.FmtProtoDelim)
+	for _, family := range families {
+		if !exportutil.HasPrefix(family.GetName(), p.config.Prefixes) {
+			continue
+		}
+		if err := encoder.Encode(family); err != nil {
+			return fmt.Errorf("failed to encode metric %s: %v", family.GetName(), err)
+		}
+	}
+	if _, err := p.client.Do(p.stopCh, http.MethodPut, p.url, buf.Bytes(), p.header); err != nil {
+		return fmt.Errorf("failed to push metrics to %s: %v", p.url, err)
+	}
+	return nil
+}
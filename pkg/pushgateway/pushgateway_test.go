@@ -0,0 +1,148 @@
+package pushgateway
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/event_exporter/pkg/sinks/httpclient"
+)
+
+type push struct {
+	method   string
+	path     string
+	families map[string]*dto.MetricFamily
+}
+
+// gateway records the pushes, answering with the next of its statuses, then
+// with 200.
+type gateway struct {
+	t        *testing.T
+	locker   sync.Mutex
+	statuses []int
+	pushes   []push
+}
+
+func (g *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := push{method: r.Method, path: r.URL.EscapedPath(), families: make(map[string]*dto.MetricFamily)}
+	decoder := expfmt.NewDecoder(r.Body, expfmt.ResponseFormat(r.Header))
+	for {
+		family := &dto.MetricFamily{}
+		if err := decoder.Decode(family); err != nil {
+			if err != io.EOF {
+				g.t.Errorf("failed to decode metrics: %v", err)
+			}
+			break
+		}
+		p.families[family.GetName()] = family
+	}
+	g.locker.Lock()
+	defer g.locker.Unlock()
+	g.pushes = append(g.pushes, p)
+	status := http.StatusOK
+	if len(g.statuses) > 0 {
+		status, g.statuses = g.statuses[0], g.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (g *gateway) count() int {
+	g.locker.Lock()
+	defer g.locker.Unlock()
+	return len(g.pushes)
+}
+
+func TestRun(t *testing.T) {
+	g := &gateway{t: t, statuses: []int{http.StatusBadGateway}}
+	server := httptest.NewServer(g)
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	events := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kube_event_unique_events_total",
+		Help: "Total number of kubernetes unique event happened",
+	}, []string{"reason"})
+	events.WithLabelValues("BackOff").Inc()
+	registry.MustRegister(events, prometheus.NewGauge(prometheus.GaugeOpts{Name: "go_goroutines", Help: "Number of goroutines"}))
+
+	p, err := New(Config{
+		URL:      server.URL + "/",
+		Grouping: map[string]string{"instance": "ci/run-42", "cluster": "ci-7"},
+		Interval: meta_v1.Duration{Duration: time.Hour},
+		Prefixes: []string{"kube_event_"},
+		Config: httpclient.Config{
+			Retry: httpclient.RetryConfig{InitialBackoff: meta_v1.Duration{Duration: time.Millisecond}},
+		},
+	}, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		p.Run(stopCh)
+		close(done)
+	}()
+	// The first push fails and is retried.
+	for i := 0; g.count() < 2; i++ {
+		if i == 100 {
+			t.Fatal("metrics were not pushed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	events.WithLabelValues("BackOff").Inc()
+	close(stopCh)
+	<-done
+
+	if got := g.count(); got != 3 {
+		t.Fatalf("got %d pushes, want 3", got)
+	}
+	for _, p := range g.pushes {
+		if p.method != http.MethodPut || p.path != "/metrics/job/event_exporter/cluster/ci-7/instance/@base64/Y2kvcnVuLTQy" {
+			t.Errorf("got push %s %s", p.method, p.path)
+		}
+	}
+	// The final value is pushed on shutdown.
+	last := g.pushes[2].families
+	if len(last) != 1 {
+		t.Errorf("got metrics %v, want kube_event_unique_events_total only", reflect.ValueOf(last).MapKeys())
+	}
+	if family := last["kube_event_unique_events_total"]; family == nil || family.Metric[0].GetCounter().GetValue() != 2 {
+		t.Errorf("got family %v", family)
+	}
+}
+
+func TestEncodeLabelValue(t *testing.T) {
+	for value, want := range map[string]string{
+		"ci-7":      "ci-7",
+		"":          "@base64/=",
+		"ci/run-42": "@base64/Y2kvcnVuLTQy",
+		"a b":       "a%20b",
+	} {
+		if got := encodeLabelValue(value); got != want {
+			t.Errorf("encodeLabelValue(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	for _, config := range []Config{
+		{},
+		{URL: "pushgateway:9091"},
+		{URL: "http://pushgateway:9091", Grouping: map[string]string{"job": "ci"}},
+		{URL: "http://pushgateway:9091", Grouping: map[string]string{"cluster-name": "ci"}},
+	} {
+		if _, err := New(config, prometheus.NewRegistry()); err == nil {
+			t.Errorf("expected error for config %+v", config)
+		}
+	}
+}
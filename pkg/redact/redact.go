@@ -0,0 +1,204 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package redact removes secrets from events before they are exported.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const defaultReplacement = "[REDACTED]"
+
+// DefaultRules are the built-in rules, applied after the configured ones.
+var DefaultRules = []Rule{
+	{
+		Name:        "bearer-token",
+		Pattern:     `(?i)(\bbearer\s+)[a-z0-9._~+/-]+=*`,
+		Replacement: "${1}" + defaultReplacement,
+	},
+	{
+		// The password of credentials in URLs, e.g. in image pull errors.
+		Name:        "url-password",
+		Pattern:     `(\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s/:@]+:)[^\s/@]+@`,
+		Replacement: "${1}" + defaultReplacement + "@",
+	},
+	{
+		Name:    "aws-access-key",
+		Pattern: `\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,
+	},
+	{
+		Name:        "aws-secret-key",
+		Pattern:     `(?i)(aws_?secret_?access_?key["']?\s*[=:]\s*["']?)[a-z0-9/+=]{40}`,
+		Replacement: "${1}" + defaultReplacement,
+	},
+	{
+		// Secrets passed as arguments or environment variables.
+		Name:        "password-assignment",
+		Pattern:     `(?i)(\b[a-z_-]*(?:password|passwd|secret|token)["']?\s*[=:]\s*["']?)[^\s"',;)\]}]+`,
+		Replacement: "${1}" + defaultReplacement,
+	},
+}
+
+// Config configures the redactor.
+type Config struct {
+	// Rules are applied in order, before DefaultRules.
+	Rules []Rule `json:"rules,omitempty"`
+	// DisableDefaults stops applying DefaultRules.
+	DisableDefaults bool `json:"disableDefaults,omitempty"`
+}
+
+// Rule replaces the matches of a regular expression.
+type Rule struct {
+	// Name identifies the rule in the metrics.
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+	// Replacement of the matches, in which $1 or ${name} is replaced by the
+	// submatches (default [REDACTED]).
+	Replacement string `json:"replacement,omitempty"`
+}
+
+type rule struct {
+	name        string
+	re          *regexp.Regexp
+	replacement string
+}
+
+// Redactor replaces the secrets matched by its rules in the message, reason,
+// action and the values of the labels and annotations of events.
+type Redactor struct {
+	rules []rule
+
+	lock sync.Mutex
+	// counted maps the namespace/name keys of the events whose secrets were
+	// counted in the metrics to their UID, so that the updates of an event
+	// are not counted again.
+	counted map[string]types.UID
+}
+
+// New creates a redactor from its configuration.
+func New(config Config) (*Redactor, error) {
+	rules := config.Rules
+	if !config.DisableDefaults {
+		rules = append(rules[:len(rules):len(rules)], DefaultRules...)
+	}
+	r := &Redactor{counted: make(map[string]types.UID)}
+	names := make(map[string]bool)
+	for i, c := range rules {
+		if c.Name == "" || c.Pattern == "" {
+			return nil, fmt.Errorf("rule %d has no name or pattern", i)
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("duplicate rule name %q", c.Name)
+		}
+		names[c.Name] = true
+		re, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern of rule %s: %v", c.Name, err)
+		}
+		if c.Replacement == "" {
+			c.Replacement = defaultReplacement
+		}
+		r.rules = append(r.rules, rule{name: c.Name, re: re, replacement: c.Replacement})
+	}
+	return r, nil
+}
+
+// Default returns a redactor with the built-in rules only.
+func Default() *Redactor {
+	r, err := New(Config{})
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Redact returns a copy of the event with the secrets replaced, or the event
+// itself if it contains none. The event is never modified, as it is usually
+// shared with an informer cache. The secrets of an event are only counted the
+// first time it is redacted, until it is forgotten.
+func (r *Redactor) Redact(event *v1.Event) *v1.Event {
+	matches := make(map[string]int)
+	var redacted *v1.Event
+	copied := func() *v1.Event {
+		if redacted == nil {
+			redacted = event.DeepCopy()
+		}
+		return redacted
+	}
+	if s, ok := r.redactString(matches, event.Message); ok {
+		copied().Message = s
+	}
+	if s, ok := r.redactString(matches, event.Reason); ok {
+		copied().Reason = s
+	}
+	if s, ok := r.redactString(matches, event.Action); ok {
+		copied().Action = s
+	}
+	for key, value := range event.Labels {
+		if s, ok := r.redactString(matches, value); ok {
+			copied().Labels[key] = s
+		}
+	}
+	for key, value := range event.Annotations {
+		if s, ok := r.redactString(matches, value); ok {
+			copied().Annotations[key] = s
+		}
+	}
+	if redacted == nil {
+		return event
+	}
+	key := event.Namespace + "/" + event.Name
+	r.lock.Lock()
+	uid, counted := r.counted[key]
+	r.counted[key] = event.UID
+	r.lock.Unlock()
+	if !counted || uid != event.UID {
+		for name, n := range matches {
+			redactions.WithLabelValues(name).Add(float64(n))
+		}
+	}
+	return redacted
+}
+
+// Forget stops remembering that the secrets of the event with the
+// namespace/name key were counted, once it is deleted.
+func (r *Redactor) Forget(key string) {
+	r.lock.Lock()
+	delete(r.counted, key)
+	r.lock.Unlock()
+}
+
+// redactString applies the rules to s, adding the number of matches of every
+// rule to matches and reporting whether any of them matched.
+func (r *Redactor) redactString(matches map[string]int, s string) (string, bool) {
+	matched := false
+	for _, rule := range r.rules {
+		n := len(rule.re.FindAllStringIndex(s, -1))
+		if n == 0 {
+			continue
+		}
+		matches[rule.name] += n
+		s = rule.re.ReplaceAllString(s, rule.replacement)
+		matched = true
+	}
+	return s, matched
+}
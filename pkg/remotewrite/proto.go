@@ -0,0 +1,154 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remotewrite
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/caicloud/event_exporter/pkg/internal/exportutil"
+)
+
+// Metric types of MetricMetadata.
+const (
+	typeUnknown   = 0
+	typeCounter   = 1
+	typeGauge     = 2
+	typeHistogram = 3
+	typeSummary   = 5
+)
+
+var metricTypes = map[dto.MetricType]uint64{
+	dto.MetricType_COUNTER:   typeCounter,
+	dto.MetricType_GAUGE:     typeGauge,
+	dto.MetricType_UNTYPED:   typeUnknown,
+	dto.MetricType_HISTOGRAM: typeHistogram,
+	dto.MetricType_SUMMARY:   typeSummary,
+}
+
+type label struct {
+	name, value string
+}
+
+// encodeWriteRequest encodes the metric families whose name has one of the
+// prefixes as a WriteRequest, with a sample per series at now unless the
+// metric has its own timestamp. Histograms and summaries are split into the
+// series of the text format, e.g. _bucket, _sum and _count:
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; repeated MetricMetadata metadata = 3; }
+//	message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label { string name = 1; string value = 2; }
+//	message Sample { double value = 1; int64 timestamp = 2; }
+//	message MetricMetadata { MetricType type = 1; string metric_family_name = 2; string help = 4; }
+func encodeWriteRequest(families []*dto.MetricFamily, prefixes []string, external map[string]string, now time.Time) []byte {
+	var request, metadata []byte
+	for _, family := range families {
+		name := family.GetName()
+		if !exportutil.HasPrefix(name, prefixes) {
+			continue
+		}
+		for _, m := range family.Metric {
+			timestamp := now.UnixNano() / int64(time.Millisecond)
+			if m.TimestampMs != nil {
+				timestamp = m.GetTimestampMs()
+			}
+			series := func(suffix string, value float64, extra ...label) {
+				labels := seriesLabels(name+suffix, m.Label, extra, external)
+				request = exportutil.AppendMessage(request, 1, encodeTimeSeries(labels, value, timestamp))
+			}
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				series("", m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				series("", m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				series("", m.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				infinite := false
+				for _, b := range h.Bucket {
+					series("_bucket", float64(b.GetCumulativeCount()), label{"le", formatFloat(b.GetUpperBound())})
+					infinite = infinite || math.IsInf(b.GetUpperBound(), 1)
+				}
+				if !infinite {
+					series("_bucket", float64(h.GetSampleCount()), label{"le", "+Inf"})
+				}
+				series("_sum", h.GetSampleSum())
+				series("_count", float64(h.GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.Quantile {
+					series("", q.GetValue(), label{"quantile", formatFloat(q.GetQuantile())})
+				}
+				series("_sum", s.GetSampleSum())
+				series("_count", float64(s.GetSampleCount()))
+			}
+		}
+		var md []byte
+		md = exportutil.AppendVarint(md, 1, metricTypes[family.GetType()])
+		md = exportutil.AppendString(md, 2, name)
+		md = exportutil.AppendString(md, 4, family.GetHelp())
+		metadata = exportutil.AppendMessage(metadata, 3, md)
+	}
+	return append(request, metadata...)
+}
+
+// seriesLabels returns the labels of a series sorted by name, as required by
+// the protocol, the external labels overriding the others.
+func seriesLabels(name string, pairs []*dto.LabelPair, extra []label, external map[string]string) []label {
+	values := make(map[string]string, len(pairs)+len(extra)+len(external)+1)
+	for _, pair := range pairs {
+		values[pair.GetName()] = pair.GetValue()
+	}
+	for _, l := range extra {
+		values[l.name] = l.value
+	}
+	for key, value := range external {
+		values[key] = value
+	}
+	values["__name__"] = name
+	labels := make([]label, 0, len(values))
+	for key, value := range values {
+		if value != "" {
+			labels = append(labels, label{key, value})
+		}
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+	return labels
+}
+
+func encodeTimeSeries(labels []label, value float64, timestamp int64) []byte {
+	var b []byte
+	for _, l := range labels {
+		var lb []byte
+		lb = exportutil.AppendString(lb, 1, l.name)
+		lb = exportutil.AppendString(lb, 2, l.value)
+		b = exportutil.AppendMessage(b, 1, lb)
+	}
+	var sample []byte
+	sample = exportutil.AppendDouble(sample, 1, value)
+	sample = exportutil.AppendVarint(sample, 2, uint64(timestamp))
+	return exportutil.AppendMessage(b, 2, sample)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package remotewrite sends the metrics of the exporter to a Prometheus
+// remote write endpoint, for clusters that can't be scraped.
+package remotewrite
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"github.com/caicloud/event_exporter/pkg/internal/exportutil"
+	"github.com/caicloud/event_exporter/pkg/sinks/httpclient"
+	"github.com/caicloud/event_exporter/pkg/version"
+)
+
+const defaultInterval = 30 * time.Second
+
+// Config configures the remote write endpoint the metrics are sent to.
+type Config struct {
+	// URL of the endpoint, e.g. http://prometheus:9090/api/v1/write.
+	URL string `json:"url"`
+	// Interval between two sends (default 30s).
+	Interval meta_v1.Duration `json:"interval,omitempty"`
+	// Prefixes of the names of the sent metrics. Every metric is sent if
+	// none is set.
+	Prefixes []string `json:"prefixes,omitempty"`
+	// ExternalLabels are added to every series, e.g. the cluster the exporter
+	// runs in. They override the labels of the series with the same name.
+	ExternalLabels map[string]string `json:"externalLabels,omitempty"`
+	httpclient.Config
+}
+
+// Sender sends the samples of the gathered metrics every interval.
+type Sender struct {
+	config   Config
+	client   *httpclient.Client
+	header   http.Header
+	gatherer prometheus.Gatherer
+	now      func() time.Time
+	stopCh   <-chan struct{}
+}
+
+// New creates a sender of the metrics of gatherer.
+func New(config Config, gatherer prometheus.Gatherer) (*Sender, error) {
+	endpoint, err := url.Parse(config.URL)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid url %q", config.URL)
+	}
+	if config.Interval.Duration <= 0 {
+		config.Interval.Duration = defaultInterval
+	}
+	for name := range config.ExternalLabels {
+		if !exportutil.LabelNamePattern.MatchString(name) || strings.HasPrefix(name, "__") {
+			return nil, fmt.Errorf("invalid external label name %q", name)
+		}
+	}
+	client, err := httpclient.New(config.Config)
+	if err != nil {
+		return nil, err
+	}
+	header := make(http.Header)
+	header.Set("Content-Type", "application/x-protobuf")
+	header.Set("Content-Encoding", "snappy")
+	header.Set("User-Agent", "event_exporter/"+version.Version)
+	header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	return &Sender{
+		config:   config,
+		client:   client,
+		header:   header,
+		gatherer: gatherer,
+		now:      time.Now,
+	}, nil
+}
+
+// Run sends the metrics every interval until stopCh is closed, and a last
+// time afterwards.
+func (s *Sender) Run(stopCh <-chan struct{}) {
+	s.stopCh = stopCh
+	klog.Infof("sending metrics to %s every %v", s.config.URL, s.config.Interval.Duration)
+	wait.Until(func() {
+		if err := s.send(); err != nil {
+			runtime.HandleError(err)
+		}
+	}, s.config.Interval.Duration, stopCh)
+	if err := s.send(); err != nil {
+		runtime.HandleError(err)
+	}
+}
+
+// send sends the current value of the metrics. Failed requests are retried,
+// except on client errors other than 429, as the samples would be rejected
+// again.
+func (s *Sender) send() error {
+	families, err := s.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather metrics: %v", err)
+	}
+	request := encodeWriteRequest(families, s.config.Prefixes, s.config.ExternalLabels, s.now())
+	if _, err := s.client.Do(s.stopCh, http.MethodPost, s.config.URL, snappy.Encode(nil, request), s.header); err != nil {
+		return fmt.Errorf("failed to send metrics to %s: %v", s.config.URL, err)
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package remotewrite
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protowire"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/event_exporter/pkg/sinks/httpclient"
+)
+
+var now = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// receiver is a remote write endpoint answering with the next of its
+// statuses, then with 204.
+type receiver struct {
+	t        *testing.T
+	locker   sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	data, _ := ioutil.ReadAll(req.Body)
+	body, err := snappy.Decode(nil, data)
+	if err != nil {
+		r.t.Errorf("failed to decode body: %v", err)
+	}
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	status := http.StatusNoContent
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func newRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	events := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kube_event_unique_events_total",
+		Help: "Total number of kubernetes unique event happened",
+	}, []string{"reason", "cluster"})
+	events.WithLabelValues("BackOff", "").Add(3)
+	duration := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "kube_event_problem_resolve_duration_seconds",
+		Help:    "Time from a problem event until its resolution event",
+		Buckets: []float64{1, 10},
+	})
+	duration.Observe(5)
+	duration.Observe(20)
+	other := prometheus.NewGauge(prometheus.GaugeOpts{Name: "go_goroutines", Help: "Number of goroutines"})
+	registry.MustRegister(events, duration, other)
+	return registry
+}
+
+func newSender(t *testing.T, config Config) *Sender {
+	config.Retry = httpclient.RetryConfig{InitialBackoff: meta_v1.Duration{Duration: time.Millisecond}}
+	s, err := New(config, newRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return now }
+	return s
+}
+
+type series struct {
+	labels    string
+	value     float64
+	timestamp int64
+}
+
+func TestSend(t *testing.T) {
+	r := &receiver{t: t, statuses: []int{http.StatusServiceUnavailable}}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	s := newSender(t, Config{
+		URL:            server.URL + "/api/v1/write",
+		Prefixes:       []string{"kube_event_"},
+		ExternalLabels: map[string]string{"cluster": "edge-1"},
+		Config:         httpclient.Config{BasicAuth: &httpclient.BasicAuth{Username: "edge", Password: "secret"}},
+	})
+	s.stopCh = make(chan struct{})
+	if err := s.send(); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.requests) != 2 {
+		t.Fatalf("got %d requests, want 2", len(r.requests))
+	}
+	req := r.requests[1]
+	if req.URL.Path != "/api/v1/write" {
+		t.Errorf("got path %s", req.URL.Path)
+	}
+	if user, password, ok := req.BasicAuth(); !ok || user != "edge" || password != "secret" {
+		t.Errorf("got basic auth %s:%s", user, password)
+	}
+	for key, value := range map[string]string{
+		"Content-Type":                      "application/x-protobuf",
+		"Content-Encoding":                  "snappy",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+	} {
+		if got := req.Header.Get(key); got != value {
+			t.Errorf("got header %s %q, want %q", key, got, value)
+		}
+	}
+
+	request := fields(t, r.bodies[1])
+	var got []series
+	for _, ts := range request[1] {
+		got = append(got, decodeSeries(t, ts))
+	}
+	millis := now.UnixNano() / int64(time.Millisecond)
+	want := []series{
+		{`__name__="kube_event_problem_resolve_duration_seconds_bucket",cluster="edge-1",le="1"`, 0, millis},
+		{`__name__="kube_event_problem_resolve_duration_seconds_bucket",cluster="edge-1",le="10"`, 1, millis},
+		{`__name__="kube_event_problem_resolve_duration_seconds_bucket",cluster="edge-1",le="+Inf"`, 2, millis},
+		{`__name__="kube_event_problem_resolve_duration_seconds_sum",cluster="edge-1"`, 25, millis},
+		{`__name__="kube_event_problem_resolve_duration_seconds_count",cluster="edge-1"`, 2, millis},
+		{`__name__="kube_event_unique_events_total",cluster="edge-1",reason="BackOff"`, 3, millis},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got series\n%v\nwant\n%v", got, want)
+	}
+
+	var metadata []string
+	for _, md := range request[3] {
+		m := fields(t, md)
+		typ, _ := protowire.ConsumeVarint(m[1][0])
+		metadata = append(metadata, string(m[2][0])+" "+string(rune('0'+typ))+" "+string(m[4][0]))
+	}
+	wantMetadata := []string{
+		"kube_event_problem_resolve_duration_seconds 3 Time from a problem event until its resolution event",
+		"kube_event_unique_events_total 1 Total number of kubernetes unique event happened",
+	}
+	if !reflect.DeepEqual(metadata, wantMetadata) {
+		t.Errorf("got metadata %v, want %v", metadata, wantMetadata)
+	}
+}
+
+func TestRun(t *testing.T) {
+	r := &receiver{t: t}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	s := newSender(t, Config{URL: server.URL, Interval: meta_v1.Duration{Duration: time.Hour}})
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		s.Run(stopCh)
+		close(done)
+	}()
+	for i := 0; ; i++ {
+		r.locker.Lock()
+		n := len(r.requests)
+		r.locker.Unlock()
+		if n == 1 {
+			break
+		}
+		if i == 100 {
+			t.Fatal("metrics were not sent")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// The metrics are sent a last time once stopped.
+	close(stopCh)
+	<-done
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	if len(r.requests) != 2 {
+		t.Errorf("got %d requests, want 2", len(r.requests))
+	}
+}
+
+func TestSendClientError(t *testing.T) {
+	r := &receiver{t: t, statuses: []int{http.StatusBadRequest}}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	s := newSender(t, Config{URL: server.URL})
+	s.stopCh = make(chan struct{})
+	if err := s.send(); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("got error %v, want status 400", err)
+	}
+	if len(r.requests) != 1 {
+		t.Errorf("got %d requests, want 1", len(r.requests))
+	}
+	// Without prefixes, every metric is sent.
+	if err := s.send(); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(fields(t, r.bodies[1])[1]); got != 7 {
+		t.Errorf("got %d series, want 7", got)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	for _, config := range []Config{
+		{},
+		{URL: "localhost:9090/api/v1/write"},
+		{URL: "http://localhost:9090", ExternalLabels: map[string]string{"__name__": "up"}},
+		{URL: "http://localhost:9090", ExternalLabels: map[string]string{"cluster-name": "edge"}},
+	} {
+		if _, err := New(config, prometheus.NewRegistry()); err == nil {
+			t.Errorf("expected error for config %+v", config)
+		}
+	}
+}
+
+func decodeSeries(t *testing.T, data []byte) series {
+	ts := fields(t, data)
+	var labels []string
+	for _, l := range ts[1] {
+		pair := fields(t, l)
+		labels = append(labels, string(pair[1][0])+`="`+string(pair[2][0])+`"`)
+	}
+	sample := fields(t, ts[2][0])
+	var value float64
+	if len(sample[1]) > 0 {
+		value = math.Float64frombits(binary.LittleEndian.Uint64(sample[1][0]))
+	}
+	timestamp, _ := protowire.ConsumeVarint(sample[2][0])
+	return series{labels: strings.Join(labels, ","), value: value, timestamp: int64(timestamp)}
+}
+
+// fields decodes a protobuf message into the raw values of its fields. Varint
+// values are returned in their encoded form.
+func fields(t *testing.T, message []byte) map[protowire.Number][][]byte {
+	result := make(map[protowire.Number][][]byte)
+	for len(message) > 0 {
+		num, typ, n := protowire.ConsumeTag(message)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		message = message[n:]
+		var value []byte
+		switch typ {
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(message)
+		case protowire.VarintType:
+			_, n = protowire.ConsumeVarint(message)
+			if n >= 0 {
+				value = message[:n]
+			}
+		case protowire.Fixed64Type:
+			_, n = protowire.ConsumeFixed64(message)
+			if n >= 0 {
+				value = message[:n]
+			}
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		result[num] = append(result[num], value)
+		message = message[n:]
+	}
+	return result
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package severity classifies events into the severity levels exposed in the
+// metrics and the records sent to the sinks.
+package severity
+
+import (
+	"fmt"
+	"regexp"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/caicloud/event_exporter/pkg/filters"
+)
+
+// Severity levels.
+const (
+	Info     = "info"
+	Warning  = "warning"
+	Critical = "critical"
+)
+
+var levels = map[string]bool{Info: true, Warning: true, Critical: true}
+
+// DefaultRules are the built-in rules, matched after the configured ones.
+var DefaultRules = []Rule{
+	{
+		// Node problems, mostly reported by the node problem detector.
+		Reasons: []string{
+			"NodeNotReady", "OOMKilling", "SystemOOM", "KernelOops", "TaskHung", "KernelDeadlock",
+			"FilesystemIsReadOnly", "CorruptDockerOverlay2", "ContainerRuntimeIsDown", "KubeletIsDown",
+		},
+		Severity: Critical,
+	},
+	{
+		// Node conditions reported as Normal events by the kubelet.
+		Reasons:  []string{"NodeHasDiskPressure", "NodeHasInsufficientMemory", "NodeHasInsufficientPID", "Rebooted"},
+		Severity: Warning,
+	},
+	{
+		Types:    []string{v1.EventTypeWarning},
+		Message:  `(?i)out of memory|oom[- ]?kill`,
+		Severity: Critical,
+	},
+	{
+		Types:    []string{v1.EventTypeWarning},
+		Severity: Warning,
+	},
+}
+
+// Config configures the classifier.
+type Config struct {
+	// Rules are matched in order, the first matching rule setting the
+	// severity of an event.
+	Rules []Rule `json:"rules,omitempty"`
+	// DisableDefaults stops matching DefaultRules after Rules.
+	DisableDefaults bool `json:"disableDefaults,omitempty"`
+}
+
+// Rule matches the events with one of the types, reasons and kinds and a
+// message matching the regular expression. Empty fields match every event.
+type Rule struct {
+	Types   []string `json:"types,omitempty"`
+	Reasons []string `json:"reasons,omitempty"`
+	Kinds   []string `json:"kinds,omitempty"`
+	Message string   `json:"message,omitempty"`
+	// Severity is info, warning or critical.
+	Severity string `json:"severity"`
+}
+
+type rule struct {
+	filters  []filters.EventFilter
+	message  *regexp.Regexp
+	severity string
+}
+
+func (r *rule) matches(event *v1.Event) bool {
+	for _, filter := range r.filters {
+		if !filter.Filter(event) {
+			return false
+		}
+	}
+	return r.message == nil || r.message.MatchString(event.Message)
+}
+
+// Classifier sets the severity of events from the first rule they match,
+// events matching no rule being info.
+type Classifier struct {
+	rules []*rule
+}
+
+// New creates a classifier from its configuration.
+func New(config Config) (*Classifier, error) {
+	rules := config.Rules
+	if !config.DisableDefaults {
+		rules = append(rules[:len(rules):len(rules)], DefaultRules...)
+	}
+	c := &Classifier{}
+	for i, r := range rules {
+		if !levels[r.Severity] {
+			return nil, fmt.Errorf("unknown severity %q of rule %d", r.Severity, i)
+		}
+		compiled := &rule{severity: r.Severity}
+		if len(r.Types) > 0 {
+			compiled.filters = append(compiled.filters, filters.NewEventTypeFilter(r.Types))
+		}
+		if len(r.Reasons) > 0 {
+			compiled.filters = append(compiled.filters, filters.NewEventReasonFilter(r.Reasons))
+		}
+		if len(r.Kinds) > 0 {
+			compiled.filters = append(compiled.filters, filters.NewInvolvedObjectKindFilter(r.Kinds))
+		}
+		if r.Message != "" {
+			re, err := regexp.Compile(r.Message)
+			if err != nil {
+				return nil, fmt.Errorf("invalid message of rule %d: %v", i, err)
+			}
+			compiled.message = re
+		}
+		c.rules = append(c.rules, compiled)
+	}
+	return c, nil
+}
+
+// Default returns a classifier with the built-in rules only.
+func Default() *Classifier {
+	c, err := New(Config{})
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Classify returns the severity of the event.
+func (c *Classifier) Classify(event *v1.Event) string {
+	for _, r := range c.rules {
+		if r.matches(event) {
+			return r.severity
+		}
+	}
+	return Info
+}
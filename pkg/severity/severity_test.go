@@ -0,0 +1,70 @@
+package severity
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func newEvent(eventType, reason, kind, message string) *v1.Event {
+	return &v1.Event{
+		InvolvedObject: v1.ObjectReference{Kind: kind, Name: "test"},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+	}
+}
+
+func TestClassify(t *testing.T) {
+	c, err := New(Config{Rules: []Rule{
+		{Reasons: []string{"BackOff"}, Kinds: []string{"Job"}, Severity: Info},
+		{Types: []string{v1.EventTypeWarning}, Message: `quota exceeded`, Severity: Critical},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		event *v1.Event
+		want  string
+	}{
+		{name: "configured rule", event: newEvent(v1.EventTypeWarning, "BackOff", "Job", ""), want: Info},
+		{name: "configured rule of other kind", event: newEvent(v1.EventTypeWarning, "BackOff", "Pod", ""), want: Warning},
+		{name: "configured message", event: newEvent(v1.EventTypeWarning, "FailedCreate", "ReplicaSet", "pods is forbidden: quota exceeded"), want: Critical},
+		{name: "default reason of normal event", event: newEvent(v1.EventTypeNormal, "NodeNotReady", "Node", ""), want: Critical},
+		{name: "default reason", event: newEvent(v1.EventTypeWarning, "OOMKilling", "Node", ""), want: Critical},
+		{name: "default message", event: newEvent(v1.EventTypeWarning, "Failed", "Pod", "Container was OOMKilled"), want: Critical},
+		{name: "default node condition", event: newEvent(v1.EventTypeNormal, "NodeHasDiskPressure", "Node", ""), want: Warning},
+		{name: "warning", event: newEvent(v1.EventTypeWarning, "FailedScheduling", "Pod", ""), want: Warning},
+		{name: "normal", event: newEvent(v1.EventTypeNormal, "Pulled", "Pod", ""), want: Info},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Classify(tt.event); got != tt.want {
+				t.Errorf("Classify() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisableDefaults(t *testing.T) {
+	c, err := New(Config{DisableDefaults: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Classify(newEvent(v1.EventTypeWarning, "OOMKilling", "Node", "")); got != Info {
+		t.Errorf("Classify() = %s, want %s", got, Info)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	for _, rule := range []Rule{
+		{Reasons: []string{"BackOff"}},
+		{Reasons: []string{"BackOff"}, Severity: "error"},
+		{Message: "(", Severity: Info},
+	} {
+		if _, err := New(Config{Rules: []Rule{rule}}); err == nil {
+			t.Errorf("expected error for rule %+v", rule)
+		}
+	}
+}
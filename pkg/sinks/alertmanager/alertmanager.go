@@ -0,0 +1,191 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package alertmanager implements a sink posting events to the Alertmanager
+// v2 API as alerts.
+package alertmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/event_exporter/pkg/eventutil"
+	"github.com/caicloud/event_exporter/pkg/sinks"
+	"github.com/caicloud/event_exporter/pkg/sinks/httpclient"
+)
+
+const defaultTTL = time.Hour
+
+func init() {
+	sinks.Register("alertmanager", New)
+}
+
+// Config configures an alertmanager sink.
+type Config struct {
+	// URL is the base URL of the Alertmanager, e.g. http://alertmanager:9093.
+	URL string `json:"url"`
+	// TTL is how long an alert keeps firing after the last occurrence of its
+	// event (default 1h).
+	TTL meta_v1.Duration `json:"ttl,omitempty"`
+	// Labels are added to every alert.
+	Labels map[string]string `json:"labels,omitempty"`
+	// GeneratorURL links the alerts back to their source.
+	GeneratorURL string `json:"generatorURL,omitempty"`
+	httpclient.Config
+}
+
+// alert is an alert of the Alertmanager v2 API.
+type alert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+}
+
+// Sink posts records to an Alertmanager. An event is posted again whenever it
+// is updated, which keeps its alert firing until TTL after its last
+// occurrence.
+type Sink struct {
+	config Config
+	url    string
+	client *httpclient.Client
+	header http.Header
+	stopCh <-chan struct{}
+	now    func() time.Time
+}
+
+// New creates an alertmanager sink from its raw configuration.
+func New(_ string, raw json.RawMessage) (sinks.Sink, error) {
+	var config Config
+	if err := sinks.DecodeConfig(raw, &config); err != nil {
+		return nil, err
+	}
+	if config.URL == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	if config.TTL.Duration <= 0 {
+		config.TTL.Duration = defaultTTL
+	}
+	client, err := httpclient.New(config.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{
+		config: config,
+		url:    strings.TrimSuffix(config.URL, "/") + "/api/v2/alerts",
+		client: client,
+		header: http.Header{"Content-Type": []string{"application/json"}},
+		now:    time.Now,
+	}, nil
+}
+
+// NeedsWorkload implements sinks.WorkloadSink, the workload being the owner
+// label of the alerts.
+func (s *Sink) NeedsWorkload() bool {
+	return true
+}
+
+// Start implements sinks.Sink.
+func (s *Sink) Start(stopCh <-chan struct{}) error {
+	s.stopCh = stopCh
+	return nil
+}
+
+// Send implements sinks.Sink. Deleted events are not posted, their alerts
+// resolve once they expire.
+func (s *Sink) Send(record *sinks.Record) error {
+	return s.post([]*sinks.Record{record})
+}
+
+// SendGroup implements sinks.GroupSink, posting the alerts of the group in a
+// single request.
+func (s *Sink) SendGroup(group *sinks.Record) error {
+	return s.post(group.Group)
+}
+
+// post posts the alerts of the records that weren't deleted nor expired.
+func (s *Sink) post(records []*sinks.Record) error {
+	now := s.now()
+	var alerts []alert
+	for _, record := range records {
+		if record.Kind == sinks.Deleted {
+			continue
+		}
+		if a := s.alert(record); a.EndsAt.After(now) {
+			alerts = append(alerts, a)
+		}
+	}
+	if len(alerts) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Do(s.stopCh, http.MethodPost, s.url, body, s.header)
+	return err
+}
+
+func (s *Sink) alert(record *sinks.Record) alert {
+	event := record.Event
+	labels := make(map[string]string, len(s.config.Labels)+8)
+	for key, value := range s.config.Labels {
+		labels[key] = value
+	}
+	labels["alertname"] = event.Reason
+	labels["reason"] = event.Reason
+	labels["type"] = event.Type
+	labels["namespace"] = event.InvolvedObject.Namespace
+	labels["kind"] = event.InvolvedObject.Kind
+	labels["name"] = event.InvolvedObject.Name
+	labels["severity"] = record.Severity
+	if record.Workload != nil {
+		labels["owner"] = record.Workload.Kind + "/" + record.Workload.Name
+	}
+	for key, value := range labels {
+		if value == "" {
+			delete(labels, key)
+		}
+	}
+	return alert{
+		Labels: labels,
+		Annotations: map[string]string{
+			"message": event.Message,
+			"count":   strconv.Itoa(int(eventutil.Count(event))),
+			"source":  event.Source.Component,
+		},
+		StartsAt:     eventutil.FirstTime(event),
+		EndsAt:       eventutil.LastTime(event).Add(s.config.TTL.Duration),
+		GeneratorURL: s.config.GeneratorURL,
+	}
+}
+
+// SendSync implements sinks.SyncSink, Send posting the alert right away.
+func (s *Sink) SendSync(record *sinks.Record) error {
+	return s.Send(record)
+}
+
+// Close implements sinks.Sink.
+func (s *Sink) Close() error {
+	return nil
+}
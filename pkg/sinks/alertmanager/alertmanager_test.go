@@ -0,0 +1,111 @@
+package alertmanager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/event_exporter/pkg/sinks"
+	"github.com/caicloud/event_exporter/pkg/workload"
+)
+
+func TestSink(t *testing.T) {
+	var posted [][]alert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/alerts" || r.Method != http.MethodPost {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		var alerts []alert
+		if err := json.NewDecoder(r.Body).Decode(&alerts); err != nil {
+			t.Error(err)
+		}
+		posted = append(posted, alerts)
+	}))
+	defer server.Close()
+
+	sink, err := New("test", json.RawMessage(`{"url": "`+server.URL+`/", "ttl": "10m", "labels": {"cluster": "prod"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	sink.(*Sink).now = func() time.Time { return now }
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	if err := sink.Start(stopCh); err != nil {
+		t.Fatal(err)
+	}
+	if !sink.(sinks.WorkloadSink).NeedsWorkload() {
+		t.Error("expected the sink to need workloads")
+	}
+
+	event := &v1.Event{
+		ObjectMeta:     meta_v1.ObjectMeta{Name: "nginx-1.1", Namespace: "default"},
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "nginx-1"},
+		Reason:         "BackOff",
+		Message:        "Back-off restarting failed container",
+		Type:           v1.EventTypeWarning,
+		Count:          3,
+		Source:         v1.EventSource{Component: "kubelet"},
+		FirstTimestamp: meta_v1.NewTime(now.Add(-time.Hour)),
+		LastTimestamp:  meta_v1.NewTime(now.Add(-time.Minute)),
+	}
+	stale := event.DeepCopy()
+	stale.LastTimestamp = meta_v1.NewTime(now.Add(-20 * time.Minute))
+	owner := &workload.Reference{Kind: "Deployment", Namespace: "default", Name: "nginx"}
+
+	records := []*sinks.Record{
+		{Kind: sinks.Updated, Event: event, Workload: owner, Severity: "warning"},
+		{Kind: sinks.Updated, Event: stale, Workload: owner},
+		{Kind: sinks.Deleted, Event: event, Workload: owner},
+	}
+	for _, record := range records {
+		if err := sink.Send(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A group is posted as a single request, with the same alerts.
+	if err := sink.(sinks.GroupSink).SendGroup(&sinks.Record{Kind: sinks.Deleted, Event: event, Group: records}); err != nil {
+		t.Fatal(err)
+	}
+
+	firing := []alert{{
+		Labels: map[string]string{
+			"alertname": "BackOff",
+			"reason":    "BackOff",
+			"type":      "Warning",
+			"namespace": "default",
+			"kind":      "Pod",
+			"name":      "nginx-1",
+			"owner":     "Deployment/nginx",
+			"severity":  "warning",
+			"cluster":   "prod",
+		},
+		Annotations: map[string]string{
+			"message": "Back-off restarting failed container",
+			"count":   "3",
+			"source":  "kubelet",
+		},
+		StartsAt: now.Add(-time.Hour),
+		EndsAt:   now.Add(9 * time.Minute),
+	}}
+	if want := [][]alert{firing, firing}; !reflect.DeepEqual(posted, want) {
+		t.Errorf("got alerts %+v, want %+v", posted, want)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	for _, config := range []string{
+		`{}`,
+		`{"url": "http://localhost", "ttl": "forever"}`,
+	} {
+		if _, err := New("test", json.RawMessage(config)); err == nil {
+			t.Errorf("expected error for config %s", config)
+		}
+	}
+}
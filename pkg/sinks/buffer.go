@@ -0,0 +1,254 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/caicloud/event_exporter/pkg/sinks/httpclient"
+	"github.com/caicloud/event_exporter/pkg/sinks/wal"
+)
+
+const (
+	defaultBufferMaxSize     = 256
+	defaultBufferSegmentSize = 16
+	defaultBufferBackoff     = time.Second
+	defaultBufferMaxBackoff  = 5 * time.Minute
+	deadLetterFile           = "dead-letter.jsonl"
+)
+
+// BufferConfig configures the write-ahead log buffering the records of a sink
+// on disk until the sink accepts them.
+type BufferConfig struct {
+	// Path is the directory of the log.
+	Path string `json:"path"`
+	// MaxSize is the disk usage of the log in megabytes (default 256). The
+	// oldest records are dropped when it is exceeded.
+	MaxSize int `json:"maxSize,omitempty"`
+	// SegmentSize is the size of the log files in megabytes (default 16).
+	SegmentSize int `json:"segmentSize,omitempty"`
+	// MaxRetries is the number of times a record the sink rejects with an
+	// error that isn't retryable, e.g. a 4xx status, is retried before it is
+	// written to the dead-letter file (default 0). Records failing with
+	// retryable errors, e.g. network errors or 5xx statuses, are retried
+	// until they are delivered.
+	MaxRetries int `json:"maxRetries,omitempty"`
+	// InitialBackoff and MaxBackoff bound the exponential backoff between
+	// retries (default 1s and 5m).
+	InitialBackoff meta_v1.Duration `json:"initialBackoff,omitempty"`
+	MaxBackoff     meta_v1.Duration `json:"maxBackoff,omitempty"`
+	// DeadLetterPath is the file the rejected records are appended to as JSON
+	// lines (default dead-letter.jsonl in the directory of the log).
+	DeadLetterPath string `json:"deadLetterPath,omitempty"`
+}
+
+// deadLetter is a line of the dead-letter file.
+type deadLetter struct {
+	Time   time.Time `json:"time"`
+	Error  string    `json:"error"`
+	Record *Record   `json:"record"`
+}
+
+// buffer delivers the records of a sink from its write-ahead log, retrying
+// the records the sink fails to send. Records left in the log when the
+// exporter stops are delivered once it restarts.
+type buffer struct {
+	name       string
+	wal        *wal.WAL
+	deadLetter string
+	maxRetries int
+	backoff    wait.Backoff
+	now        func() time.Time
+	// notify wakes up the delivery of records once one was added.
+	notify chan struct{}
+	done   chan struct{}
+}
+
+func newBuffer(name string, config BufferConfig) (*buffer, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("buffer path is required")
+	}
+	if config.MaxSize <= 0 {
+		config.MaxSize = defaultBufferMaxSize
+	}
+	if config.SegmentSize <= 0 {
+		config.SegmentSize = defaultBufferSegmentSize
+	}
+	if config.SegmentSize > config.MaxSize {
+		config.SegmentSize = config.MaxSize
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+	if config.InitialBackoff.Duration <= 0 {
+		config.InitialBackoff.Duration = defaultBufferBackoff
+	}
+	if config.MaxBackoff.Duration <= 0 {
+		config.MaxBackoff.Duration = defaultBufferMaxBackoff
+	}
+	if config.DeadLetterPath == "" {
+		config.DeadLetterPath = filepath.Join(config.Path, deadLetterFile)
+	}
+	log, err := wal.Open(config.Path, wal.Options{
+		SegmentSize: int64(config.SegmentSize) << 20,
+		MaxSize:     int64(config.MaxSize) << 20,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open buffer: %v", err)
+	}
+	return &buffer{
+		name:       name,
+		wal:        log,
+		deadLetter: config.DeadLetterPath,
+		maxRetries: config.MaxRetries,
+		backoff: wait.Backoff{
+			Duration: config.InitialBackoff.Duration,
+			Factor:   2,
+			Jitter:   0.1,
+			Steps:    math.MaxInt32,
+			Cap:      config.MaxBackoff.Duration,
+		},
+		now:    time.Now,
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// add appends the record to the log.
+func (b *buffer) add(record *Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	dropped, err := b.wal.Append(data, b.now())
+	if dropped > 0 {
+		sinkBufferDropped.WithLabelValues(b.name).Add(float64(dropped))
+	}
+	if err != nil {
+		return err
+	}
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// run delivers the records with send until stopCh is closed. Records are
+// retried as long as they fail with retryable errors, so that they are
+// delivered once the sink recovers from an outage, and written to the
+// dead-letter file after MaxRetries other errors.
+func (b *buffer) run(stopCh <-chan struct{}, send func(record *Record) error) {
+	defer close(b.done)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	backoff := b.backoff
+	attempts := 0
+	for {
+		data, appended, ok, err := b.wal.Peek()
+		b.updateMetrics(appended, ok)
+		if err != nil {
+			runtime.HandleError(fmt.Errorf("failed to read buffer of sink %s: %v", b.name, err))
+		}
+		if err != nil || !ok {
+			select {
+			case <-stopCh:
+				return
+			case <-b.notify:
+			case <-ticker.C:
+			}
+			continue
+		}
+
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+		var record Record
+		if err := json.Unmarshal(data, &record); err != nil {
+			runtime.HandleError(fmt.Errorf("failed to decode buffered record of sink %s: %v", b.name, err))
+		} else if err := send(&record); err != nil {
+			retryable := httpclient.Retryable(err)
+			if !retryable {
+				attempts++
+			}
+			if retryable || attempts <= b.maxRetries {
+				select {
+				case <-stopCh:
+					return
+				case <-time.After(backoff.Step()):
+				}
+				continue
+			}
+			b.writeDeadLetter(&record, err)
+		}
+		attempts = 0
+		backoff = b.backoff
+		if err := b.wal.Ack(); err != nil {
+			runtime.HandleError(fmt.Errorf("failed to update buffer of sink %s: %v", b.name, err))
+		}
+	}
+}
+
+func (b *buffer) updateMetrics(oldest time.Time, ok bool) {
+	sinkBufferBytes.WithLabelValues(b.name).Set(float64(b.wal.Size()))
+	sinkBufferRecords.WithLabelValues(b.name).Set(float64(b.wal.Len()))
+	age := 0.0
+	if ok {
+		age = b.now().Sub(oldest).Seconds()
+	}
+	sinkBufferOldestAge.WithLabelValues(b.name).Set(age)
+}
+
+func (b *buffer) writeDeadLetter(record *Record, sendErr error) {
+	sinkDeadLetters.WithLabelValues(b.name).Inc()
+	data, err := json.Marshal(deadLetter{Time: b.now().UTC(), Error: sendErr.Error(), Record: record})
+	if err == nil {
+		err = appendFile(b.deadLetter, append(data, '\n'))
+	}
+	if err != nil {
+		runtime.HandleError(fmt.Errorf("failed to write dead letter of sink %s: %v", b.name, err))
+	}
+}
+
+func appendFile(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// close waits for the delivery to stop and closes the log.
+func (b *buffer) close() error {
+	<-b.done
+	return b.wal.Close()
+}
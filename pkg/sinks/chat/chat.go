@@ -0,0 +1,313 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package chat implements a sink posting events to chat incoming webhooks,
+// such as the ones of Slack and Microsoft Teams.
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/caicloud/event_exporter/pkg/sinks"
+	"github.com/caicloud/event_exporter/pkg/sinks/httpclient"
+)
+
+const (
+	defaultBatchWait    = 10 * time.Second
+	defaultMaxBatchSize = 20
+)
+
+func init() {
+	sinks.Register("chat", New)
+}
+
+// Config configures a chat sink.
+type Config struct {
+	// Format is the payload format of the webhooks: slack, teams or generic
+	// (default slack).
+	Format string `json:"format,omitempty"`
+	// URL is the webhook events matching no route are posted to. They are
+	// dropped if it is empty.
+	URL string `json:"url,omitempty"`
+	// Routes are tried in order, the first matching route selects the
+	// webhook of an event.
+	Routes []Route `json:"routes,omitempty"`
+	// ObjectURL is a text/template rendering a link to the involved object
+	// from the event.
+	ObjectURL string `json:"objectURL,omitempty"`
+	// BatchWait is how long to wait for more events before posting a message
+	// (default 10s).
+	BatchWait meta_v1.Duration `json:"batchWait,omitempty"`
+	// MaxBatchSize is the maximum number of events in a message (default 20).
+	MaxBatchSize int `json:"maxBatchSize,omitempty"`
+	httpclient.Config
+}
+
+// Route sends the events matching all of its conditions to a webhook.
+type Route struct {
+	// Namespaces of the involved object, any namespace if empty.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Selector is a label selector matched against the labels of the event.
+	Selector string `json:"selector,omitempty"`
+	URL      string `json:"url"`
+}
+
+type route struct {
+	namespaces sets.String
+	selector   labels.Selector
+	url        string
+}
+
+func (r *route) matches(event *v1.Event) bool {
+	if r.namespaces.Len() > 0 && !r.namespaces.Has(event.InvolvedObject.Namespace) {
+		return false
+	}
+	return r.selector == nil || r.selector.Matches(labels.Set(event.Labels))
+}
+
+type batch struct {
+	created time.Time
+	records []*sinks.Record
+}
+
+// Sink posts records to chat webhooks. Events posted to the same webhook
+// within BatchWait are sent as a single message.
+type Sink struct {
+	config    Config
+	format    format
+	routes    []route
+	objectURL *template.Template
+	client    *httpclient.Client
+	header    http.Header
+	stopCh    <-chan struct{}
+	now       func() time.Time
+
+	locker  sync.Mutex
+	batches map[string]*batch
+}
+
+// New creates a chat sink from its raw configuration.
+func New(name string, raw json.RawMessage) (sinks.Sink, error) {
+	var config Config
+	if err := sinks.DecodeConfig(raw, &config); err != nil {
+		return nil, err
+	}
+	if config.Format == "" {
+		config.Format = "slack"
+	}
+	f, ok := formats[config.Format]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q", config.Format)
+	}
+	if config.URL == "" && len(config.Routes) == 0 {
+		return nil, fmt.Errorf("url or routes are required")
+	}
+	if config.BatchWait.Duration <= 0 {
+		config.BatchWait.Duration = defaultBatchWait
+	}
+	if config.MaxBatchSize <= 0 {
+		config.MaxBatchSize = defaultMaxBatchSize
+	}
+	client, err := httpclient.New(config.Config)
+	if err != nil {
+		return nil, err
+	}
+	s := &Sink{
+		config:  config,
+		format:  f,
+		client:  client,
+		header:  http.Header{"Content-Type": []string{"application/json"}},
+		now:     time.Now,
+		batches: make(map[string]*batch),
+	}
+	for i, r := range config.Routes {
+		if r.URL == "" {
+			return nil, fmt.Errorf("route %d has no url", i)
+		}
+		parsed := route{namespaces: sets.NewString(r.Namespaces...), url: r.URL}
+		if r.Selector != "" {
+			if parsed.selector, err = labels.Parse(r.Selector); err != nil {
+				return nil, fmt.Errorf("invalid selector of route %d: %v", i, err)
+			}
+		}
+		s.routes = append(s.routes, parsed)
+	}
+	if config.ObjectURL != "" {
+		funcs := template.FuncMap{"lower": strings.ToLower}
+		if s.objectURL, err = template.New(name).Funcs(funcs).Parse(config.ObjectURL); err != nil {
+			return nil, fmt.Errorf("failed to parse objectURL template: %v", err)
+		}
+	}
+	return s, nil
+}
+
+// Start implements sinks.Sink.
+func (s *Sink) Start(stopCh <-chan struct{}) error {
+	s.stopCh = stopCh
+	go wait.Until(func() { s.flush(false) }, time.Second, stopCh)
+	return nil
+}
+
+// Send implements sinks.Sink. Deleted events are not posted.
+func (s *Sink) Send(record *sinks.Record) error {
+	if record.Kind == sinks.Deleted {
+		return nil
+	}
+	url := s.route(record.Event)
+	if url == "" {
+		return nil
+	}
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	b, ok := s.batches[url]
+	if !ok {
+		b = &batch{created: s.now()}
+		s.batches[url] = b
+	}
+	b.records = append(b.records, record)
+	return nil
+}
+
+// SendSync implements sinks.SyncSink, posting the record as a message of its
+// own.
+func (s *Sink) SendSync(record *sinks.Record) error {
+	if record.Kind == sinks.Deleted {
+		return nil
+	}
+	url := s.route(record.Event)
+	if url == "" {
+		return nil
+	}
+	return s.post(url, []*sinks.Record{record})
+}
+
+// SendGroup implements sinks.GroupSink, posting the records of the group to
+// their webhooks as a single message each, up to MaxBatchSize events.
+func (s *Sink) SendGroup(group *sinks.Record) error {
+	var urls []string
+	records := make(map[string][]*sinks.Record)
+	for _, record := range group.Group {
+		if record.Kind == sinks.Deleted {
+			continue
+		}
+		url := s.route(record.Event)
+		if url == "" {
+			continue
+		}
+		if _, ok := records[url]; !ok {
+			urls = append(urls, url)
+		}
+		records[url] = append(records[url], record)
+	}
+	for _, url := range urls {
+		if err := s.postBatches(url, records[url]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Sink) route(event *v1.Event) string {
+	for i := range s.routes {
+		if s.routes[i].matches(event) {
+			return s.routes[i].url
+		}
+	}
+	return s.config.URL
+}
+
+// flush posts the batches that waited long enough or are full, or every batch
+// if force is set.
+func (s *Sink) flush(force bool) {
+	due := make(map[string]*batch)
+	s.locker.Lock()
+	for url, b := range s.batches {
+		if force || len(b.records) >= s.config.MaxBatchSize || s.now().Sub(b.created) >= s.config.BatchWait.Duration {
+			due[url] = b
+			delete(s.batches, url)
+		}
+	}
+	s.locker.Unlock()
+
+	for url, b := range due {
+		if err := s.postBatches(url, b.records); err != nil {
+			runtime.HandleError(err)
+		}
+	}
+}
+
+// postBatches posts the records in messages of up to MaxBatchSize events.
+func (s *Sink) postBatches(url string, records []*sinks.Record) error {
+	for start := 0; start < len(records); start += s.config.MaxBatchSize {
+		end := start + s.config.MaxBatchSize
+		if end > len(records) {
+			end = len(records)
+		}
+		if err := s.post(url, records[start:end]); err != nil {
+			return fmt.Errorf("failed to post %d events to chat: %w", end-start, err)
+		}
+	}
+	return nil
+}
+
+func (s *Sink) post(url string, records []*sinks.Record) error {
+	messages := make([]message, 0, len(records))
+	for _, record := range records {
+		messages = append(messages, s.message(record))
+	}
+	body, err := json.Marshal(s.format(messages))
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Do(s.stopCh, http.MethodPost, url, body, s.header)
+	return err
+}
+
+func (s *Sink) message(record *sinks.Record) message {
+	event := record.Event
+	m := newMessage(event)
+	m.suppressed = record.Suppressed
+	m.severity = record.Severity
+	if s.objectURL != nil {
+		var buf bytes.Buffer
+		if err := s.objectURL.Execute(&buf, event); err != nil {
+			runtime.HandleError(fmt.Errorf("failed to render object url: %v", err))
+		} else {
+			m.link = buf.String()
+		}
+	}
+	return m
+}
+
+// Close implements sinks.Sink, posting the pending batches.
+func (s *Sink) Close() error {
+	s.flush(true)
+	return nil
+}
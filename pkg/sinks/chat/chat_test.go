@@ -0,0 +1,193 @@
+package chat
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/event_exporter/pkg/sinks"
+)
+
+type receiver struct {
+	server *httptest.Server
+	locker sync.Mutex
+	bodies map[string][]string
+}
+
+func newReceiver() *receiver {
+	r := &receiver{bodies: make(map[string][]string)}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		data, _ := ioutil.ReadAll(req.Body)
+		r.locker.Lock()
+		defer r.locker.Unlock()
+		r.bodies[req.URL.Path] = append(r.bodies[req.URL.Path], string(data))
+	}))
+	return r
+}
+
+func newEvent(namespace, name, eventType string, labels map[string]string) *v1.Event {
+	return &v1.Event{
+		ObjectMeta:     meta_v1.ObjectMeta{Name: name + ".1", Namespace: namespace, Labels: labels},
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: namespace, Name: name},
+		Reason:         "BackOff",
+		Message:        "Back-off restarting failed container",
+		Type:           eventType,
+		Count:          2,
+		Source:         v1.EventSource{Component: "kubelet"},
+	}
+}
+
+func TestSinkRoutesAndBatches(t *testing.T) {
+	r := newReceiver()
+	defer r.server.Close()
+
+	config := `{
+		"format": "generic",
+		"url": "` + r.server.URL + `/default",
+		"routes": [
+			{"namespaces": ["payments"], "url": "` + r.server.URL + `/payments"},
+			{"selector": "team=search", "url": "` + r.server.URL + `/search"}
+		],
+		"objectURL": "https://dashboard/{{lower .InvolvedObject.Kind}}/{{.InvolvedObject.Namespace}}/{{.InvolvedObject.Name}}",
+		"batchWait": "1m",
+		"maxBatchSize": 2
+	}`
+	sink, err := New("test", json.RawMessage(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := sink.(*Sink)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	s.stopCh = make(chan struct{})
+
+	for _, record := range []*sinks.Record{
+		{Kind: sinks.Added, Event: newEvent("payments", "api", v1.EventTypeWarning, nil)},
+		{Kind: sinks.Updated, Event: newEvent("payments", "worker", v1.EventTypeWarning, nil)},
+		{Kind: sinks.Added, Event: newEvent("payments", "db", v1.EventTypeWarning, nil)},
+		{Kind: sinks.Added, Event: newEvent("default", "indexer", v1.EventTypeNormal, map[string]string{"team": "search"})},
+		{Kind: sinks.Added, Event: newEvent("default", "web", v1.EventTypeWarning, nil)},
+		{Kind: sinks.Deleted, Event: newEvent("default", "web", v1.EventTypeWarning, nil)},
+	} {
+		if err := s.Send(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.flush(false)
+	if got := len(r.bodies["/payments"]); got != 2 {
+		t.Errorf("got %d messages for a full batch, want 2", got)
+	}
+	if got := len(r.bodies["/default"]) + len(r.bodies["/search"]); got != 0 {
+		t.Errorf("got %d messages before the batch wait passed", got)
+	}
+
+	now = now.Add(time.Minute)
+	s.flush(false)
+	want := map[string][]string{
+		"/payments": {
+			`{"text":"Warning BackOff: Pod payments/api (x2): Back-off restarting failed container https://dashboard/pod/payments/api\n` +
+				`Warning BackOff: Pod payments/worker (x2): Back-off restarting failed container https://dashboard/pod/payments/worker"}`,
+			`{"text":"Warning BackOff: Pod payments/db (x2): Back-off restarting failed container https://dashboard/pod/payments/db"}`,
+		},
+		"/search": {
+			`{"text":"Normal BackOff: Pod default/indexer (x2): Back-off restarting failed container https://dashboard/pod/default/indexer"}`,
+		},
+		"/default": {
+			`{"text":"Warning BackOff: Pod default/web (x2): Back-off restarting failed container https://dashboard/pod/default/web"}`,
+		},
+	}
+	for path, bodies := range want {
+		if strings.Join(r.bodies[path], "\n") != strings.Join(bodies, "\n") {
+			t.Errorf("got messages %q for %s, want %q", r.bodies[path], path, bodies)
+		}
+	}
+
+	s.Send(&sinks.Record{Kind: sinks.Added, Event: newEvent("default", "web", v1.EventTypeWarning, nil)})
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(r.bodies["/default"]); got != 2 {
+		t.Errorf("got %d messages after close, want 2", got)
+	}
+
+	// The records of a group are posted right away, in a message per webhook.
+	group := []*sinks.Record{
+		{Kind: sinks.Added, Event: newEvent("payments", "api", v1.EventTypeWarning, nil)},
+		{Kind: sinks.Added, Event: newEvent("default", "web", v1.EventTypeWarning, nil)},
+		{Kind: sinks.Deleted, Event: newEvent("payments", "db", v1.EventTypeWarning, nil)},
+		{Kind: sinks.Updated, Event: newEvent("payments", "worker", v1.EventTypeWarning, nil)},
+	}
+	if err := s.SendGroup(&sinks.Record{Kind: sinks.Updated, Event: group[3].Event, Group: group}); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.bodies["/payments"]; len(got) != 3 || got[2] != want["/payments"][0] {
+		t.Errorf("got messages %q for the group, want %q last", got, want["/payments"][0])
+	}
+	if got := len(r.bodies["/default"]); got != 3 {
+		t.Errorf("got %d messages after the group, want 3", got)
+	}
+}
+
+func TestFormats(t *testing.T) {
+	warning := newMessage(newEvent("default", "web", v1.EventTypeWarning, nil))
+	warning.link = "https://dashboard/pod/default/web"
+	warning.severity = "critical"
+	normal := newMessage(newEvent("default", "api", v1.EventTypeNormal, nil))
+	normal.suppressed = 3
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "slack",
+			want: `{"text":"2 Kubernetes events","attachments":[` +
+				`{"fallback":"Normal BackOff: Pod default/api: Back-off restarting failed container","color":"#5cb85c","title":"Normal BackOff: Pod default/api","text":"Back-off restarting failed container","fields":[{"title":"Count","value":"2","short":true},{"title":"Source","value":"kubelet","short":true},{"title":"Suppressed","value":"3","short":true}]},` +
+				`{"fallback":"Warning BackOff: Pod default/web: Back-off restarting failed container","color":"#d9534f","title":"Warning BackOff: Pod default/web","title_link":"https://dashboard/pod/default/web","text":"Back-off restarting failed container","fields":[{"title":"Count","value":"2","short":true},{"title":"Severity","value":"critical","short":true},{"title":"Source","value":"kubelet","short":true}]}]}`,
+		},
+		{
+			format: "teams",
+			want: `{"@type":"MessageCard","@context":"https://schema.org/extensions","themeColor":"d9534f","summary":"2 Kubernetes events","title":"2 Kubernetes events","sections":[` +
+				`{"activityTitle":"Normal BackOff: Pod default/api","activitySubtitle":"Back-off restarting failed container","facts":[{"name":"Count","value":"2"},{"name":"Source","value":"kubelet"},{"name":"Suppressed","value":"3"}]},` +
+				`{"activityTitle":"[Warning BackOff: Pod default/web](https://dashboard/pod/default/web)","activitySubtitle":"Back-off restarting failed container","facts":[{"name":"Count","value":"2"},{"name":"Severity","value":"critical"},{"name":"Source","value":"kubelet"}]}]}`,
+		},
+		{
+			format: "generic",
+			want: `{"text":"Normal BackOff: Pod default/api (x2): Back-off restarting failed container (3 similar notifications suppressed)\n` +
+				`[critical] Warning BackOff: Pod default/web (x2): Back-off restarting failed container https://dashboard/pod/default/web"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			data, err := json.Marshal(formats[tt.format]([]message{normal, warning}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("got %s, want %s", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	for _, config := range []string{
+		`{}`,
+		`{"url": "http://localhost", "format": "irc"}`,
+		`{"routes": [{"namespaces": ["default"]}]}`,
+		`{"routes": [{"selector": "team in (", "url": "http://localhost"}]}`,
+		`{"url": "http://localhost", "objectURL": "{{"}`,
+	} {
+		if _, err := New("test", json.RawMessage(config)); err == nil {
+			t.Errorf("expected error for config %s", config)
+		}
+	}
+}
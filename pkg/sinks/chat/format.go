@@ -0,0 +1,206 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/caicloud/event_exporter/pkg/eventutil"
+)
+
+// message is the rendering of an event shared by the formats.
+type message struct {
+	title  string
+	text   string
+	color  string
+	count  int32
+	source string
+	// suppressed is the number of notifications of the object the throttle
+	// of the sink dropped since the previous one.
+	suppressed int32
+	severity   string
+	link       string
+	warning    bool
+}
+
+func newMessage(event *v1.Event) message {
+	object := event.InvolvedObject
+	name := object.Name
+	if object.Namespace != "" {
+		name = object.Namespace + "/" + name
+	}
+	m := message{
+		title:  fmt.Sprintf("%s %s: %s %s", event.Type, event.Reason, object.Kind, name),
+		text:   event.Message,
+		color:  "#777777",
+		count:  eventutil.Count(event),
+		source: event.Source.Component,
+	}
+	switch event.Type {
+	case v1.EventTypeWarning:
+		m.color = "#d9534f"
+		m.warning = true
+	case v1.EventTypeNormal:
+		m.color = "#5cb85c"
+	}
+	return m
+}
+
+// format builds the payload of a webhook from a batch of messages.
+type format func(messages []message) interface{}
+
+var formats = map[string]format{
+	"slack":   slack,
+	"teams":   teams,
+	"generic": generic,
+}
+
+func summary(messages []message) string {
+	if len(messages) == 1 {
+		return messages[0].title
+	}
+	return fmt.Sprintf("%d Kubernetes events", len(messages))
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+type slackAttachment struct {
+	Fallback  string       `json:"fallback"`
+	Color     string       `json:"color"`
+	Title     string       `json:"title"`
+	TitleLink string       `json:"title_link,omitempty"`
+	Text      string       `json:"text"`
+	Fields    []slackField `json:"fields"`
+}
+
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+// slack builds a message of attachments colored by event type.
+func slack(messages []message) interface{} {
+	payload := slackMessage{Text: summary(messages)}
+	for _, m := range messages {
+		fields := []slackField{{Title: "Count", Value: fmt.Sprint(m.count), Short: true}}
+		if m.severity != "" {
+			fields = append(fields, slackField{Title: "Severity", Value: m.severity, Short: true})
+		}
+		if m.source != "" {
+			fields = append(fields, slackField{Title: "Source", Value: m.source, Short: true})
+		}
+		if m.suppressed > 0 {
+			fields = append(fields, slackField{Title: "Suppressed", Value: fmt.Sprint(m.suppressed), Short: true})
+		}
+		payload.Attachments = append(payload.Attachments, slackAttachment{
+			Fallback:  m.title + ": " + m.text,
+			Color:     m.color,
+			Title:     m.title,
+			TitleLink: m.link,
+			Text:      m.text,
+			Fields:    fields,
+		})
+	}
+	return payload
+}
+
+type teamsFact struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type teamsSection struct {
+	ActivityTitle    string      `json:"activityTitle"`
+	ActivitySubtitle string      `json:"activitySubtitle"`
+	Facts            []teamsFact `json:"facts"`
+}
+
+type teamsCard struct {
+	Type       string         `json:"@type"`
+	Context    string         `json:"@context"`
+	ThemeColor string         `json:"themeColor"`
+	Summary    string         `json:"summary"`
+	Title      string         `json:"title"`
+	Sections   []teamsSection `json:"sections"`
+}
+
+// teams builds a message card with a section per event. A card has a single
+// color, the one of warnings if it contains any.
+func teams(messages []message) interface{} {
+	card := teamsCard{
+		Type:    "MessageCard",
+		Context: "https://schema.org/extensions",
+		Summary: summary(messages),
+		Title:   summary(messages),
+	}
+	for _, m := range messages {
+		if card.ThemeColor == "" || m.warning {
+			card.ThemeColor = strings.TrimPrefix(m.color, "#")
+		}
+		title := m.title
+		if m.link != "" {
+			title = fmt.Sprintf("[%s](%s)", m.title, m.link)
+		}
+		facts := []teamsFact{{Name: "Count", Value: fmt.Sprint(m.count)}}
+		if m.severity != "" {
+			facts = append(facts, teamsFact{Name: "Severity", Value: m.severity})
+		}
+		if m.source != "" {
+			facts = append(facts, teamsFact{Name: "Source", Value: m.source})
+		}
+		if m.suppressed > 0 {
+			facts = append(facts, teamsFact{Name: "Suppressed", Value: fmt.Sprint(m.suppressed)})
+		}
+		card.Sections = append(card.Sections, teamsSection{
+			ActivityTitle:    title,
+			ActivitySubtitle: m.text,
+			Facts:            facts,
+		})
+	}
+	return card
+}
+
+type genericMessage struct {
+	Text string `json:"text"`
+}
+
+// generic builds a plain text message with a line per event, as accepted by
+// most chat webhooks.
+func generic(messages []message) interface{} {
+	lines := make([]string, 0, len(messages))
+	for _, m := range messages {
+		line := fmt.Sprintf("%s (x%d): %s", m.title, m.count, m.text)
+		if m.severity != "" {
+			line = "[" + m.severity + "] " + line
+		}
+		if m.link != "" {
+			line += " " + m.link
+		}
+		if m.suppressed > 0 {
+			line += fmt.Sprintf(" (%d similar notifications suppressed)", m.suppressed)
+		}
+		lines = append(lines, line)
+	}
+	return genericMessage{Text: strings.Join(lines, "\n")}
+}
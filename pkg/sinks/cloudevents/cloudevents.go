@@ -0,0 +1,137 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cloudevents renders records as CloudEvents 1.0 for the sinks
+// supporting them.
+package cloudevents
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/caicloud/event_exporter/pkg/eventutil"
+	"github.com/caicloud/event_exporter/pkg/notify"
+	"github.com/caicloud/event_exporter/pkg/sinks"
+)
+
+const (
+	// Structured mode carries the attributes and the data in the message
+	// body.
+	Structured = "structured"
+	// Binary mode carries the attributes in headers and the data as the
+	// message body.
+	Binary = "binary"
+
+	// StructuredContentType is the content type of structured mode messages.
+	StructuredContentType = "application/cloudevents+json"
+	// SpecVersion is the version of the specification of the events.
+	SpecVersion = "1.0"
+)
+
+// ValidateMode checks that mode is empty, which disables CloudEvents, or one
+// of the supported modes.
+func ValidateMode(mode string) error {
+	switch mode {
+	case "", Structured, Binary:
+		return nil
+	}
+	return fmt.Errorf("unknown cloudevents mode %q", mode)
+}
+
+// Event is a CloudEvent carrying a record as its data.
+type Event struct {
+	ID              string
+	Source          string
+	Type            string
+	Subject         string
+	Time            time.Time
+	DataContentType string
+	Data            []byte
+}
+
+// NewEvent creates the CloudEvent of a record encoded as data. The type is
+// io.k8s.event.<type>.<reason>, the source is the component reporting the
+// event and the subject is the involved object.
+func NewEvent(record *sinks.Record, dataContentType string, data []byte) *Event {
+	event := record.Event
+	object := event.InvolvedObject
+
+	source := event.Source.Component
+	if source == "" {
+		source = event.ReportingController
+	}
+	if source == "" {
+		source = notify.Component
+	}
+	subject := object.Kind + "/" + object.Name
+	if object.Namespace != "" {
+		subject = object.Kind + "/" + object.Namespace + "/" + object.Name
+	}
+	// The id must be unique per source, and every occurrence of an event is
+	// a distinct CloudEvent.
+	id := string(event.UID)
+	if id == "" {
+		id = event.Namespace + "/" + event.Name
+	}
+	id = fmt.Sprintf("%s-%d", id, eventutil.Count(event))
+	if record.Kind == sinks.Deleted {
+		id += "-deleted"
+	}
+
+	return &Event{
+		ID:              id,
+		Source:          source,
+		Type:            "io.k8s.event." + strings.ToLower(event.Type) + "." + event.Reason,
+		Subject:         subject,
+		Time:            eventutil.LastTime(event).UTC(),
+		DataContentType: dataContentType,
+		Data:            data,
+	}
+}
+
+// Attributes returns the context attributes of the event, as carried by the
+// headers of binary mode messages.
+func (e *Event) Attributes() map[string]string {
+	attributes := map[string]string{
+		"specversion": SpecVersion,
+		"id":          e.ID,
+		"source":      e.Source,
+		"type":        e.Type,
+		"subject":     e.Subject,
+	}
+	if !e.Time.IsZero() {
+		attributes["time"] = e.Time.Format(time.RFC3339Nano)
+	}
+	return attributes
+}
+
+// MarshalJSON encodes the event in structured mode. JSON data is embedded as
+// is, other data is base64 encoded.
+func (e *Event) MarshalJSON() ([]byte, error) {
+	envelope := make(map[string]interface{}, 8)
+	for name, value := range e.Attributes() {
+		envelope[name] = value
+	}
+	envelope["datacontenttype"] = e.DataContentType
+	if strings.HasSuffix(e.DataContentType, "json") {
+		envelope["data"] = json.RawMessage(e.Data)
+	} else {
+		envelope["data_base64"] = e.Data
+	}
+	return json.Marshal(envelope)
+}
@@ -0,0 +1,106 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/event_exporter/pkg/sinks"
+)
+
+func TestNewEvent(t *testing.T) {
+	last := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	pod := &v1.Event{
+		ObjectMeta:     meta_v1.ObjectMeta{Name: "nginx.1", Namespace: "default", UID: "event-uid"},
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "nginx"},
+		Reason:         "BackOff",
+		Type:           v1.EventTypeWarning,
+		Count:          3,
+		Source:         v1.EventSource{Component: "kubelet"},
+		LastTimestamp:  meta_v1.NewTime(last),
+	}
+	node := &v1.Event{
+		ObjectMeta:          meta_v1.ObjectMeta{Name: "node-1.1", Namespace: "default"},
+		InvolvedObject:      v1.ObjectReference{Kind: "Node", Name: "node-1"},
+		Reason:              "NodeReady",
+		Type:                v1.EventTypeNormal,
+		ReportingController: "node-controller",
+		EventTime:           meta_v1.NewMicroTime(last),
+	}
+	tests := []struct {
+		name   string
+		record *sinks.Record
+		want   map[string]string
+	}{
+		{
+			name:   "pod",
+			record: &sinks.Record{Kind: sinks.Updated, Event: pod},
+			want: map[string]string{
+				"specversion": "1.0",
+				"id":          "event-uid-3",
+				"source":      "kubelet",
+				"type":        "io.k8s.event.warning.BackOff",
+				"subject":     "Pod/default/nginx",
+				"time":        "2020-06-01T12:00:00Z",
+			},
+		},
+		{
+			name:   "deleted node",
+			record: &sinks.Record{Kind: sinks.Deleted, Event: node},
+			want: map[string]string{
+				"specversion": "1.0",
+				"id":          "default/node-1.1-1-deleted",
+				"source":      "node-controller",
+				"type":        "io.k8s.event.normal.NodeReady",
+				"subject":     "Node/node-1",
+				"time":        "2020-06-01T12:00:00Z",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewEvent(tt.record, "application/json", nil).Attributes()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got attributes %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	e := &Event{
+		ID:              "1",
+		Source:          "kubelet",
+		Type:            "io.k8s.event.warning.BackOff",
+		Subject:         "Pod/default/nginx",
+		DataContentType: "application/json",
+		Data:            []byte(`{"kind":"Added"}`),
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"data":{"kind":"Added"},"datacontenttype":"application/json","id":"1","source":"kubelet","specversion":"1.0","subject":"Pod/default/nginx","type":"io.k8s.event.warning.BackOff"}`
+	if string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+
+	e.DataContentType = "application/protobuf"
+	e.Data = []byte{0x0a, 0x01}
+	data, err = json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `{"data_base64":"CgE=","datacontenttype":"application/protobuf","id":"1","source":"kubelet","specversion":"1.0","subject":"Pod/default/nginx","type":"io.k8s.event.warning.BackOff"}`
+	if string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+
+	if err := ValidateMode("batched"); err == nil {
+		t.Error("expected error for an unknown mode")
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/caicloud/event_exporter/pkg/filters"
+)
+
+// Config configures a sink.
+type Config struct {
+	// Name identifies the sink in logs and metrics.
+	Name string `json:"name"`
+	// Type is the registered type of the sink.
+	Type string `json:"type"`
+	// Filter narrows down the records forwarded to the sink. It can't be set
+	// along with a route, whose matchers select the records of the sinks.
+	Filter FilterConfig `json:"filter,omitempty"`
+	// Config is the configuration of the sink type.
+	Config json.RawMessage `json:"config,omitempty"`
+	// Throttle limits the records sent to the sink, if set.
+	Throttle *ThrottleConfig `json:"throttle,omitempty"`
+	// Queue configures the in-memory queue of the records of sinks without
+	// buffer.
+	Queue QueueConfig `json:"queue,omitempty"`
+	// Buffer buffers the records on disk until the sink accepts them, if
+	// set.
+	Buffer *BufferConfig `json:"buffer,omitempty"`
+}
+
+// FilterConfig selects records by their event. Empty lists allow everything.
+type FilterConfig struct {
+	Types      []string `json:"types,omitempty"`
+	Reasons    []string `json:"reasons,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+	Kinds      []string `json:"kinds,omitempty"`
+}
+
+func (c FilterConfig) filters() []filters.EventFilter {
+	var result []filters.EventFilter
+	if len(c.Types) > 0 {
+		result = append(result, filters.NewEventTypeFilter(c.Types))
+	}
+	if len(c.Reasons) > 0 {
+		result = append(result, filters.NewEventReasonFilter(c.Reasons))
+	}
+	if len(c.Namespaces) > 0 {
+		result = append(result, filters.NewNamespaceFilter(c.Namespaces))
+	}
+	if len(c.Kinds) > 0 {
+		result = append(result, filters.NewInvolvedObjectKindFilter(c.Kinds))
+	}
+	return result
+}
+
+// DecodeConfig decodes the raw configuration of a sink type into v, rejecting
+// unknown fields.
+func DecodeConfig(raw json.RawMessage, v interface{}) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
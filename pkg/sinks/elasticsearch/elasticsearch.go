@@ -0,0 +1,248 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package elasticsearch implements a sink indexing events into Elasticsearch
+// or OpenSearch with the bulk API.
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/caicloud/event_exporter/pkg/eventutil"
+	"github.com/caicloud/event_exporter/pkg/sinks"
+	"github.com/caicloud/event_exporter/pkg/sinks/httpclient"
+)
+
+const (
+	defaultIndex         = "k8s-events-{2006.01.02}"
+	defaultBatchSize     = 500
+	defaultFlushInterval = 5 * time.Second
+)
+
+// layoutPattern matches the time layouts in index names.
+var layoutPattern = regexp.MustCompile(`\{([^}]*)\}`)
+
+func init() {
+	sinks.Register("elasticsearch", New)
+}
+
+// Config configures an elasticsearch sink.
+type Config struct {
+	// URL is the base URL of the cluster, e.g. https://elasticsearch:9200.
+	URL string `json:"url"`
+	// Index is the name of the index of an event. Go time layouts in braces
+	// are replaced by the last time of the event in UTC (default
+	// k8s-events-{2006.01.02}).
+	Index string `json:"index,omitempty"`
+	// BatchSize is the number of events indexed by a bulk request (default
+	// 500).
+	BatchSize int `json:"batchSize,omitempty"`
+	// FlushInterval is the longest an event waits for its batch to fill up
+	// (default 5s).
+	FlushInterval meta_v1.Duration `json:"flushInterval,omitempty"`
+	httpclient.Config
+}
+
+// document is the indexed form of an event.
+type document struct {
+	Timestamp time.Time `json:"@timestamp"`
+	*v1.Event
+}
+
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+// Sink indexes records in batches. Every occurrence of an event is indexed
+// with the UID and count of the event as document ID, so that resending an
+// update overwrites its document.
+type Sink struct {
+	config Config
+	url    string
+	client *httpclient.Client
+	header http.Header
+	stopCh <-chan struct{}
+
+	locker sync.Mutex
+	buffer bytes.Buffer
+	size   int
+}
+
+// New creates an elasticsearch sink from its raw configuration.
+func New(_ string, raw json.RawMessage) (sinks.Sink, error) {
+	var config Config
+	if err := sinks.DecodeConfig(raw, &config); err != nil {
+		return nil, err
+	}
+	if config.URL == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	if config.Index == "" {
+		config.Index = defaultIndex
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.FlushInterval.Duration <= 0 {
+		config.FlushInterval.Duration = defaultFlushInterval
+	}
+	client, err := httpclient.New(config.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{
+		config: config,
+		url:    strings.TrimSuffix(config.URL, "/") + "/_bulk",
+		client: client,
+		header: http.Header{"Content-Type": []string{"application/x-ndjson"}},
+	}, nil
+}
+
+// Start implements sinks.Sink.
+func (s *Sink) Start(stopCh <-chan struct{}) error {
+	s.stopCh = stopCh
+	go wait.Until(func() {
+		if err := s.flush(); err != nil {
+			runtime.HandleError(err)
+		}
+	}, s.config.FlushInterval.Duration, stopCh)
+	return nil
+}
+
+// Send implements sinks.Sink, flushing the batch once it is full. Deleted
+// events are kept in the index.
+func (s *Sink) Send(record *sinks.Record) error {
+	if record.Kind == sinks.Deleted {
+		return nil
+	}
+	s.locker.Lock()
+	if err := s.encode(&s.buffer, record); err != nil {
+		s.locker.Unlock()
+		return err
+	}
+	s.size++
+	full := s.size >= s.config.BatchSize
+	s.locker.Unlock()
+
+	if full {
+		return s.flush()
+	}
+	return nil
+}
+
+// SendSync implements sinks.SyncSink, indexing the record on its own.
+func (s *Sink) SendSync(record *sinks.Record) error {
+	if record.Kind == sinks.Deleted {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := s.encode(&buf, record); err != nil {
+		return err
+	}
+	return s.bulk(buf.Bytes(), 1)
+}
+
+// encode appends the bulk action indexing the record to buf.
+func (s *Sink) encode(buf *bytes.Buffer, record *sinks.Record) error {
+	event := record.Event
+	timestamp := eventutil.LastTime(event).UTC()
+	action := map[string]string{"_index": s.index(timestamp)}
+	if event.UID != "" {
+		action["_id"] = fmt.Sprintf("%s-%d", event.UID, eventutil.Count(event))
+	}
+	encoder := json.NewEncoder(buf)
+	if err := encoder.Encode(map[string]interface{}{"index": action}); err != nil {
+		return err
+	}
+	return encoder.Encode(document{Timestamp: timestamp, Event: event})
+}
+
+func (s *Sink) index(t time.Time) string {
+	return layoutPattern.ReplaceAllStringFunc(s.config.Index, func(layout string) string {
+		return t.Format(layout[1 : len(layout)-1])
+	})
+}
+
+// flush sends the pending batch.
+func (s *Sink) flush() error {
+	s.locker.Lock()
+	if s.size == 0 {
+		s.locker.Unlock()
+		return nil
+	}
+	body := append([]byte(nil), s.buffer.Bytes()...)
+	size := s.size
+	s.buffer.Reset()
+	s.size = 0
+	s.locker.Unlock()
+	return s.bulk(body, size)
+}
+
+// bulk sends the bulk request indexing size events.
+func (s *Sink) bulk(body []byte, size int) error {
+	respBody, err := s.client.Do(s.stopCh, http.MethodPost, s.url, body, s.header)
+	if err != nil {
+		return fmt.Errorf("failed to index %d events: %w", size, err)
+	}
+	var resp bulkResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return fmt.Errorf("failed to decode bulk response: %v", err)
+	}
+	if !resp.Errors {
+		return nil
+	}
+	failed := 0
+	retryable := false
+	var reason json.RawMessage
+	for _, item := range resp.Items {
+		for _, result := range item {
+			if result.Status >= 300 {
+				failed++
+				if reason == nil {
+					reason = result.Error
+				}
+				retryable = retryable || result.Status == http.StatusTooManyRequests || result.Status >= 500
+			}
+		}
+	}
+	err = fmt.Errorf("failed to index %d of %d events: %s", failed, size, reason)
+	if !retryable {
+		// Documents rejected by the mappings are rejected again.
+		return httpclient.Permanent(err)
+	}
+	return err
+}
+
+// Close implements sinks.Sink, sending the pending batch.
+func (s *Sink) Close() error {
+	return s.flush()
+}
@@ -0,0 +1,118 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/caicloud/event_exporter/pkg/sinks"
+)
+
+func newEvent(uid string, count int32, last time.Time) *v1.Event {
+	return &v1.Event{
+		ObjectMeta:     meta_v1.ObjectMeta{Name: "nginx.1", Namespace: "default", UID: types.UID("uid-" + uid)},
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "nginx"},
+		Reason:         "BackOff",
+		Type:           v1.EventTypeWarning,
+		Count:          count,
+		LastTimestamp:  meta_v1.NewTime(last),
+	}
+}
+
+func TestSink(t *testing.T) {
+	var requests []string
+	response := `{"errors": false, "items": []}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("unexpected request %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, string(data))
+		w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	sink, err := New("test", json.RawMessage(`{"url": "`+server.URL+`", "index": "events-{2006}-{01.02}", "batchSize": 2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := sink.(*Sink)
+	s.stopCh = make(chan struct{})
+
+	day := time.Date(2020, 6, 1, 23, 30, 0, 0, time.FixedZone("UTC-1", -3600))
+	for _, record := range []*sinks.Record{
+		{Kind: sinks.Added, Event: newEvent("a", 1, day)},
+		{Kind: sinks.Deleted, Event: newEvent("a", 1, day)},
+		{Kind: sinks.Updated, Event: newEvent("a", 2, day.Add(-time.Hour))},
+		{Kind: sinks.Added, Event: newEvent("b", 0, day)},
+	} {
+		if err := s.Send(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(requests) != 1 {
+		t.Fatalf("got %d bulk requests for a full batch, want 1", len(requests))
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("got %d bulk requests after close, want 2", len(requests))
+	}
+
+	lines := strings.Split(strings.TrimSuffix(strings.Join(requests, ""), "\n"), "\n")
+	wantActions := []string{
+		`{"index":{"_id":"uid-a-1","_index":"events-2020-06.02"}}`,
+		`{"index":{"_id":"uid-a-2","_index":"events-2020-06.01"}}`,
+		`{"index":{"_id":"uid-b-1","_index":"events-2020-06.02"}}`,
+	}
+	if len(lines) != 2*len(wantActions) {
+		t.Fatalf("got %d lines, want %d", len(lines), 2*len(wantActions))
+	}
+	for i, want := range wantActions {
+		if lines[2*i] != want {
+			t.Errorf("got action %s, want %s", lines[2*i], want)
+		}
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["@timestamp"] != "2020-06-02T00:30:00Z" || doc["reason"] != "BackOff" {
+		t.Errorf("unexpected document %s", lines[1])
+	}
+
+	response = `{"errors": true, "items": [{"index": {"status": 201}}, {"index": {"status": 400, "error": {"type": "mapper_parsing_exception"}}}]}`
+	s.Send(&sinks.Record{Kind: sinks.Added, Event: newEvent("c", 1, day)})
+	if err := s.Close(); err == nil || !strings.Contains(err.Error(), "mapper_parsing_exception") {
+		t.Errorf("expected bulk item error, got %v", err)
+	}
+
+	// Buffered records are indexed on their own, returning their errors.
+	requests = nil
+	if err := s.SendSync(&sinks.Record{Kind: sinks.Added, Event: newEvent("d", 1, day)}); err == nil {
+		t.Error("expected bulk item error")
+	}
+	if len(requests) != 1 || strings.Count(requests[0], "\n") != 2 {
+		t.Errorf("got bulk requests %q, want a single event", requests)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	for _, config := range []string{
+		`{}`,
+		`{"url": "http://localhost", "flushInterval": 5}`,
+	} {
+		if _, err := New("test", json.RawMessage(config)); err == nil {
+			t.Errorf("expected error for config %s", config)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// group batches the records of a route with the same group key.
+type group struct {
+	// active holds the latest record of the events of the group that were
+	// not deleted, by event key.
+	active map[string]*Record
+	// pending holds the latest record of the events added since the group
+	// was last sent, by event key, in the order of their first record.
+	pending map[string]*Record
+	order   []string
+	created time.Time
+	sent    time.Time
+}
+
+// groups batches the records of a route.
+type groups struct {
+	wait     time.Duration
+	interval time.Duration
+	repeat   time.Duration
+
+	locker sync.Mutex
+	groups map[string]*group
+}
+
+func newGroups(wait, interval, repeat time.Duration) *groups {
+	return &groups{wait: wait, interval: interval, repeat: repeat, groups: make(map[string]*group)}
+}
+
+func eventKey(record *Record) string {
+	return record.Event.Namespace + "/" + record.Event.Name
+}
+
+// add adds the record to its group.
+func (g *groups) add(key string, record *Record, now time.Time) {
+	g.locker.Lock()
+	defer g.locker.Unlock()
+	gr, ok := g.groups[key]
+	if !ok {
+		gr = &group{active: make(map[string]*Record), pending: make(map[string]*Record), created: now}
+		g.groups[key] = gr
+	}
+	event := eventKey(record)
+	if record.Kind == Deleted {
+		delete(gr.active, event)
+	} else {
+		gr.active[event] = record
+	}
+	if _, ok := gr.pending[event]; !ok {
+		gr.order = append(gr.order, event)
+	}
+	gr.pending[event] = record
+}
+
+// due returns the records of the groups to send, by group: the pending
+// records of the groups created wait ago or sent interval ago, or the active
+// records of the groups sent repeat ago. All pending records are due if force
+// is set. Groups without active nor pending records are removed.
+func (g *groups) due(now time.Time, force bool) [][]*Record {
+	g.locker.Lock()
+	defer g.locker.Unlock()
+	var due [][]*Record
+	for key, gr := range g.groups {
+		var records []*Record
+		switch {
+		case len(gr.pending) > 0:
+			if !force && gr.sent.IsZero() && now.Sub(gr.created) < g.wait {
+				continue
+			}
+			if !force && !gr.sent.IsZero() && now.Sub(gr.sent) < g.interval {
+				continue
+			}
+			for _, event := range gr.order {
+				records = append(records, gr.pending[event])
+			}
+			gr.pending = make(map[string]*Record)
+			gr.order = nil
+			gr.sent = now
+		case g.repeat > 0 && len(gr.active) > 0 && now.Sub(gr.sent) >= g.repeat && !force:
+			events := make([]string, 0, len(gr.active))
+			for event := range gr.active {
+				events = append(events, event)
+			}
+			sort.Strings(events)
+			for _, event := range events {
+				records = append(records, gr.active[event])
+			}
+			gr.sent = now
+		}
+		if len(records) > 0 {
+			due = append(due, records)
+		}
+		if len(gr.active) == 0 && len(gr.pending) == 0 {
+			delete(g.groups, key)
+		}
+	}
+	return due
+}
@@ -0,0 +1,319 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package httpclient implements the HTTP client shared by the sinks posting
+// records to remote endpoints.
+package httpclient
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultTimeout        = 10 * time.Second
+	defaultMaxRetries     = 3
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = 30 * time.Second
+)
+
+// Config configures the requests sent to an endpoint.
+type Config struct {
+	// Headers are added to every request.
+	Headers map[string]string `json:"headers,omitempty"`
+	// BearerToken or the content of BearerTokenFile is sent in the
+	// Authorization header.
+	BearerToken     string     `json:"bearerToken,omitempty"`
+	BearerTokenFile string     `json:"bearerTokenFile,omitempty"`
+	BasicAuth       *BasicAuth `json:"basicAuth,omitempty"`
+	TLS             TLSConfig  `json:"tls,omitempty"`
+	// Timeout bounds every attempt of a request (default 10s).
+	Timeout meta_v1.Duration `json:"timeout,omitempty"`
+	Retry   RetryConfig      `json:"retry,omitempty"`
+}
+
+// BasicAuth configures HTTP basic authentication.
+type BasicAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password,omitempty"`
+}
+
+// TLSConfig configures the TLS connection to the endpoint.
+type TLSConfig struct {
+	// CAFile verifies the endpoint instead of the system roots.
+	CAFile string `json:"caFile,omitempty"`
+	// CertFile and KeyFile are the client certificate presented to the
+	// endpoint.
+	CertFile           string `json:"certFile,omitempty"`
+	KeyFile            string `json:"keyFile,omitempty"`
+	ServerName         string `json:"serverName,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+}
+
+// RetryConfig configures the exponential backoff between the attempts of a
+// request that failed with a network error, a 429 or a 5xx status.
+type RetryConfig struct {
+	// MaxRetries is the number of retries after the first attempt (default
+	// 3). A negative value disables retries.
+	MaxRetries     int              `json:"maxRetries,omitempty"`
+	InitialBackoff meta_v1.Duration `json:"initialBackoff,omitempty"`
+	MaxBackoff     meta_v1.Duration `json:"maxBackoff,omitempty"`
+}
+
+// StatusError is returned for responses with a non 2xx status.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+// Client sends requests to an endpoint, retrying them with exponential
+// backoff.
+type Client struct {
+	client        *http.Client
+	tlsConfig     *tls.Config
+	header        http.Header
+	authorization string
+	retries       int
+	backoff       wait.Backoff
+	check         func(resp *http.Response) error
+}
+
+// Option adapts a client to a protocol on top of HTTP.
+type Option func(c *Client)
+
+// HTTP2 sends the requests over HTTP/2, as required by gRPC. Requests to http
+// URLs are sent in cleartext (h2c) if cleartext is set.
+func HTTP2(cleartext bool) Option {
+	return func(c *Client) {
+		transport := &http2.Transport{TLSClientConfig: c.tlsConfig}
+		if cleartext {
+			transport.AllowHTTP = true
+			transport.DialTLS = func(network, addr string, _ *tls.Config) (net.Conn, error) {
+				return net.DialTimeout(network, addr, c.client.Timeout)
+			}
+		}
+		c.client.Transport = transport
+	}
+}
+
+// ResponseCheck checks the responses with a 2xx status once their body and
+// trailers were read. Errors are retried unless marked as Permanent.
+func ResponseCheck(check func(resp *http.Response) error) Option {
+	return func(c *Client) {
+		c.check = check
+	}
+}
+
+type permanentError struct {
+	error
+}
+
+// Permanent marks an error returned by a response check as not retryable.
+func Permanent(err error) error {
+	return &permanentError{err}
+}
+
+// New creates a client from the config.
+func New(config Config, options ...Option) (*Client, error) {
+	tlsConfig, err := NewTLSConfig(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	timeout := config.Timeout.Duration
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	c := &Client{
+		client:    &http.Client{Transport: transport, Timeout: timeout},
+		tlsConfig: tlsConfig,
+		header:    make(http.Header),
+		retries:   config.Retry.MaxRetries,
+		backoff: wait.Backoff{
+			Duration: config.Retry.InitialBackoff.Duration,
+			Factor:   2,
+			Jitter:   0.1,
+			Cap:      config.Retry.MaxBackoff.Duration,
+		},
+	}
+	for key, value := range config.Headers {
+		c.header.Set(key, value)
+	}
+	for _, option := range options {
+		option(c)
+	}
+	if c.retries == 0 {
+		c.retries = defaultMaxRetries
+	}
+	if c.backoff.Duration <= 0 {
+		c.backoff.Duration = defaultInitialBackoff
+	}
+	if c.backoff.Cap <= 0 {
+		c.backoff.Cap = defaultMaxBackoff
+	}
+	c.backoff.Steps = c.retries
+
+	token := config.BearerToken
+	if config.BearerTokenFile != "" {
+		data, err := ioutil.ReadFile(config.BearerTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bearer token file: %v", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token != "" && config.BasicAuth != nil {
+		return nil, fmt.Errorf("only one of bearer token and basic auth may be set")
+	}
+	if token != "" {
+		c.authorization = "Bearer " + token
+	}
+	if config.BasicAuth != nil {
+		credentials := config.BasicAuth.Username + ":" + config.BasicAuth.Password
+		c.authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+	}
+	return c, nil
+}
+
+// NewTLSConfig creates the TLS configuration of a client. It is shared with
+// the sinks connecting to their endpoints over other protocols.
+func NewTLSConfig(config TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+	if config.CAFile != "" {
+		data, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificate found in CA file %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if (config.CertFile == "") != (config.KeyFile == "") {
+		return nil, fmt.Errorf("certFile and keyFile must be set together")
+	}
+	if config.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// Do sends the request and returns the body of the response. Failed attempts
+// are retried until the retries are exhausted or stopCh is closed, so a
+// request sent after stopCh was closed is attempted once.
+func (c *Client) Do(stopCh <-chan struct{}, method, url string, body []byte, header http.Header) ([]byte, error) {
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		respBody, err := c.do(method, url, body, header)
+		if err == nil {
+			return respBody, nil
+		}
+		if attempt >= c.retries || !Retryable(err) {
+			return nil, err
+		}
+		delay := backoff.Step()
+		klog.V(4).Infof("%s %s failed, retrying in %v: %v", method, url, delay, err)
+		select {
+		case <-stopCh:
+			return nil, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (c *Client) do(method, url string, body []byte, header http.Header) ([]byte, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range c.header {
+		req.Header[key] = values
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if c.authorization != "" {
+		req.Header.Set("Authorization", c.authorization)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: truncate(string(respBody), 256)}
+	}
+	if c.check != nil {
+		if err := c.check(resp); err != nil {
+			return nil, err
+		}
+	}
+	return respBody, nil
+}
+
+// Retryable reports whether a request that failed with err may succeed if it
+// is sent again: network errors, 429 and 5xx statuses, and response check
+// errors that aren't Permanent. Errors wrapping them with %w are classified
+// alike.
+func Retryable(err error) bool {
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return true
+	}
+	return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,240 @@
+package httpclient
+
+import (
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestClientRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		retries  int
+		wantErr  bool
+		attempts int
+	}{
+		{name: "success", statuses: []int{200}, attempts: 1},
+		{name: "retried server errors", statuses: []int{503, 500, 204}, attempts: 3},
+		{name: "retried throttling", statuses: []int{429, 200}, attempts: 2},
+		{name: "client error", statuses: []int{400}, wantErr: true, attempts: 1},
+		{name: "retries exhausted", statuses: []int{502, 502, 502}, retries: 2, wantErr: true, attempts: 3},
+		{name: "retries disabled", statuses: []int{502}, retries: -1, wantErr: true, attempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statuses[attempts])
+				attempts++
+			}))
+			defer server.Close()
+
+			client, err := New(Config{Retry: RetryConfig{
+				MaxRetries:     tt.retries,
+				InitialBackoff: meta_v1.Duration{Duration: time.Millisecond},
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = client.Do(nil, http.MethodPost, server.URL, nil, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if attempts != tt.attempts {
+				t.Errorf("got %d attempts, want %d", attempts, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestClientStopsRetrying(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client, err := New(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopCh := make(chan struct{})
+	close(stopCh)
+	if _, err := client.Do(stopCh, http.MethodPost, server.URL, nil, nil); err == nil {
+		t.Error("expected error")
+	}
+	if attempts != 1 {
+		t.Errorf("got %d attempts after stop, want 1", attempts)
+	}
+}
+
+func TestClientResponseCheck(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("X-Status", strconv.Itoa(attempts))
+	}))
+	defer server.Close()
+
+	check := func(resp *http.Response) error {
+		switch resp.Header.Get("X-Status") {
+		case "1":
+			return errors.New("unavailable")
+		case "2":
+			return Permanent(errors.New("invalid"))
+		}
+		return nil
+	}
+	client, err := New(Config{Retry: RetryConfig{InitialBackoff: meta_v1.Duration{Duration: time.Millisecond}}}, ResponseCheck(check))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Do(nil, http.MethodPost, server.URL, nil, nil); err == nil || err.Error() != "invalid" {
+		t.Errorf("got error %v, want invalid", err)
+	}
+	if attempts != 2 {
+		t.Errorf("got %d attempts, want 2", attempts)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	client, err := New(Config{
+		Timeout: meta_v1.Duration{Duration: 10 * time.Millisecond},
+		Retry:   RetryConfig{MaxRetries: -1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Do(nil, http.MethodGet, server.URL, nil, nil); err == nil {
+		t.Error("expected timeout")
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{err: errors.New("connection refused"), want: true},
+		{err: &StatusError{StatusCode: http.StatusServiceUnavailable}, want: true},
+		{err: &StatusError{StatusCode: http.StatusTooManyRequests}, want: true},
+		{err: &StatusError{StatusCode: http.StatusBadRequest}},
+		{err: Permanent(errors.New("rejected"))},
+		{err: fmt.Errorf("failed to push: %w", &StatusError{StatusCode: http.StatusBadRequest})},
+		{err: fmt.Errorf("failed to push: %w", &StatusError{StatusCode: http.StatusBadGateway}), want: true},
+	} {
+		if got := Retryable(tt.err); got != tt.want {
+			t.Errorf("Retryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestClientHeaders(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{name: "bearer token", config: Config{BearerToken: "secret"}, want: "Bearer secret"},
+		{name: "bearer token file", config: Config{BearerTokenFile: tokenFile}, want: "Bearer secret"},
+		{name: "basic auth", config: Config{BasicAuth: &BasicAuth{Username: "user", Password: "pass"}}, want: "Basic dXNlcjpwYXNz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got http.Header
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header
+			}))
+			defer server.Close()
+
+			tt.config.Headers = map[string]string{"X-Scope": "events"}
+			client, err := New(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			header := http.Header{"Content-Type": []string{"text/plain"}}
+			if _, err := client.Do(nil, http.MethodPost, server.URL, []byte("body"), header); err != nil {
+				t.Fatal(err)
+			}
+			if got.Get("Authorization") != tt.want {
+				t.Errorf("got authorization %q, want %q", got.Get("Authorization"), tt.want)
+			}
+			if got.Get("X-Scope") != "events" || got.Get("Content-Type") != "text/plain" {
+				t.Errorf("missing headers: %v", got)
+			}
+		})
+	}
+
+	if _, err := New(Config{BearerToken: "a", BasicAuth: &BasicAuth{Username: "b"}}); err == nil {
+		t.Error("expected error for conflicting auth")
+	}
+}
+
+func TestClientTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	if _, err := mustNew(t, Config{Retry: RetryConfig{MaxRetries: -1}}).Do(nil, http.MethodGet, server.URL, nil, nil); err == nil {
+		t.Error("expected an unknown authority to be rejected")
+	}
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	client := mustNew(t, Config{TLS: TLSConfig{CAFile: caFile, ServerName: "example.com"}})
+	if _, err := client.Do(nil, http.MethodGet, server.URL, nil, nil); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := New(Config{TLS: TLSConfig{CertFile: "cert.pem"}}); err == nil {
+		t.Error("expected error for a certificate without key")
+	}
+	if _, err := New(Config{TLS: TLSConfig{CAFile: filepath.Join(dir, "missing.pem")}}); err == nil {
+		t.Error("expected error for a missing CA file")
+	}
+}
+
+func mustNew(t *testing.T, config Config) *Client {
+	client, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "httpclient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
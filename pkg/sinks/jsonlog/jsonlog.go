@@ -0,0 +1,162 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package jsonlog implements a sink writing events as JSON lines to the
+// standard output or to rotated files.
+package jsonlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/event_exporter/pkg/eventutil"
+	"github.com/caicloud/event_exporter/pkg/sinks"
+	"github.com/caicloud/event_exporter/pkg/workload"
+)
+
+const (
+	stdout            = "stdout"
+	defaultMaxSize    = 100
+	defaultMaxBackups = 5
+)
+
+func init() {
+	sinks.Register("jsonlog", New)
+}
+
+// Config configures a jsonlog sink.
+type Config struct {
+	// Path of the file the events are written to, or stdout (default stdout).
+	Path string `json:"path,omitempty"`
+	// MaxSize is the size in megabytes a file is rotated at (default 100).
+	MaxSize int `json:"maxSize,omitempty"`
+	// RotateInterval rotates the file when it is older, if set.
+	RotateInterval meta_v1.Duration `json:"rotateInterval,omitempty"`
+	// MaxBackups is the number of rotated files kept (default 5).
+	MaxBackups int `json:"maxBackups,omitempty"`
+	// Compress gzips the rotated files.
+	Compress bool `json:"compress,omitempty"`
+	// Fields are added to every line, e.g. the name of the cluster.
+	Fields map[string]string `json:"fields,omitempty"`
+	// ResolveWorkloads adds the workload owning pods to the lines.
+	ResolveWorkloads bool `json:"resolveWorkloads,omitempty"`
+}
+
+// line is the JSON object written for a record.
+type line struct {
+	// Time is the last time of the event.
+	Time      time.Time  `json:"time"`
+	FirstTime time.Time  `json:"firstTime"`
+	Count     int32      `json:"count"`
+	Record    sinks.Kind `json:"record"`
+	Severity  string     `json:"severity,omitempty"`
+	// Suppressed is the number of records the throttle of the sink dropped
+	// since the previous line.
+	Suppressed int32               `json:"suppressed,omitempty"`
+	Workload   *workload.Reference `json:"workload,omitempty"`
+	Fields     map[string]string   `json:"fields,omitempty"`
+	Event      *v1.Event           `json:"event"`
+}
+
+// Sink writes a JSON line per record.
+type Sink struct {
+	config Config
+	out    io.WriteCloser
+}
+
+// New creates a jsonlog sink from its raw configuration.
+func New(_ string, raw json.RawMessage) (sinks.Sink, error) {
+	var config Config
+	if err := sinks.DecodeConfig(raw, &config); err != nil {
+		return nil, err
+	}
+	if config.Path == "" {
+		config.Path = stdout
+	}
+	if config.MaxSize <= 0 {
+		config.MaxSize = defaultMaxSize
+	}
+	if config.MaxBackups <= 0 {
+		config.MaxBackups = defaultMaxBackups
+	}
+	return &Sink{config: config}, nil
+}
+
+// NeedsWorkload implements sinks.WorkloadSink.
+func (s *Sink) NeedsWorkload() bool {
+	return s.config.ResolveWorkloads
+}
+
+// Start implements sinks.Sink, opening the file.
+func (s *Sink) Start(_ <-chan struct{}) error {
+	if s.config.Path == stdout {
+		s.out = nopCloser{os.Stdout}
+		return nil
+	}
+	file, err := openRotatingFile(s.config.Path, int64(s.config.MaxSize)<<20, s.config.RotateInterval.Duration,
+		s.config.MaxBackups, s.config.Compress)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", s.config.Path, err)
+	}
+	s.out = file
+	return nil
+}
+
+// Send implements sinks.Sink.
+func (s *Sink) Send(record *sinks.Record) error {
+	event := record.Event
+	l := line{
+		Time:       eventutil.LastTime(event).UTC(),
+		FirstTime:  eventutil.FirstTime(event).UTC(),
+		Count:      eventutil.Count(event),
+		Record:     record.Kind,
+		Severity:   record.Severity,
+		Suppressed: record.Suppressed,
+		Workload:   record.Workload,
+		Fields:     s.config.Fields,
+		Event:      event,
+	}
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	_, err = s.out.Write(append(data, '\n'))
+	return err
+}
+
+// SendSync implements sinks.SyncSink, Send writing the line right away.
+func (s *Sink) SendSync(record *sinks.Record) error {
+	return s.Send(record)
+}
+
+// Close implements sinks.Sink, closing the file.
+func (s *Sink) Close() error {
+	return s.out.Close()
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,201 @@
+package jsonlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/event_exporter/pkg/sinks"
+	"github.com/caicloud/event_exporter/pkg/workload"
+)
+
+var now = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func TestSink(t *testing.T) {
+	sink, err := New("test", json.RawMessage(`{"fields": {"cluster": "prod"}, "resolveWorkloads": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := sink.(*Sink)
+	if !s.NeedsWorkload() {
+		t.Error("sink doesn't need workloads")
+	}
+	var buf bytes.Buffer
+	s.out = nopCloser{&buf}
+
+	event := &v1.Event{
+		ObjectMeta:     meta_v1.ObjectMeta{Name: "nginx.1", Namespace: "default"},
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "nginx-6d4cf56db6-x2x9r"},
+		Reason:         "BackOff",
+		Type:           v1.EventTypeWarning,
+		FirstTimestamp: meta_v1.NewTime(now.Add(-time.Minute)),
+		LastTimestamp:  meta_v1.NewTime(now),
+	}
+	owner := &workload.Reference{Kind: "Deployment", Namespace: "default", Name: "nginx"}
+	for _, record := range []*sinks.Record{
+		{Kind: sinks.Added, Event: event, Workload: owner},
+		{Kind: sinks.Deleted, Event: event},
+	} {
+		if err := s.Send(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lines := bytes.Split(bytes.TrimSuffix(buf.Bytes(), []byte("\n")), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(lines[0], &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"time":      "2020-06-01T12:00:00Z",
+		"firstTime": "2020-06-01T11:59:00Z",
+		"count":     1.0,
+		"record":    "Added",
+		"workload":  map[string]interface{}{"kind": "Deployment", "namespace": "default", "name": "nginx"},
+		"fields":    map[string]interface{}{"cluster": "prod"},
+	}
+	delete(got, "event")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got line %v, want %v", got, want)
+	}
+	var deleted line
+	if err := json.Unmarshal(lines[1], &deleted); err != nil {
+		t.Fatal(err)
+	}
+	if deleted.Record != sinks.Deleted || deleted.Workload != nil || deleted.Event.Reason != "BackOff" {
+		t.Errorf("unexpected line %s", lines[1])
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "logs", "events.log")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte("existing\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := openRotatingFile(path, 20, time.Hour, 2, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := now
+	f.now = func() time.Time { return clock }
+	f.opened = clock
+
+	write := func(s string) {
+		if _, err := f.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("0123456789\n") // fits along with the existing line
+	clock = clock.Add(time.Second)
+	write("first\n") // exceeds the size
+	clock = clock.Add(time.Hour)
+	write("second\n") // exceeds the interval
+	clock = clock.Add(time.Second)
+	write("0123456789012345678901234\n") // exceeds the size
+	clock = clock.Add(time.Second)
+	write("third\n")
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := ioutil.ReadFile(path)
+	if string(data) != "third\n" {
+		t.Errorf("got current file %q", data)
+	}
+	want := map[string]string{
+		"events-20200601T130002.000.log.gz": "second\n",
+		"events-20200601T130003.000.log.gz": "0123456789012345678901234\n",
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "logs", "events-*"))
+	if len(files) != len(want) {
+		t.Fatalf("got backups %v, want %d", files, len(want))
+	}
+	for _, file := range files {
+		in, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := gzip.NewReader(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(r)
+		in.Close()
+		if string(data) != want[filepath.Base(file)] {
+			t.Errorf("got %q in %s", data, filepath.Base(file))
+		}
+	}
+}
+
+func TestRotatingFileAge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "events.log")
+	existing := `{"time":"2020-06-01T12:00:00Z"}` + "\n" + `{"time":"2020-06-01T12:50:00Z"}` + "\n"
+	if err := ioutil.WriteFile(path, []byte(existing), 0644); err != nil {
+		t.Fatal(err)
+	}
+	written := now.Add(50 * time.Minute)
+	if err := os.Chtimes(path, written, written); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := openRotatingFile(path, 1024, time.Hour, 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.now = func() time.Time { return now.Add(time.Hour) }
+	// The first line of the existing file was written an interval ago.
+	if _, err := f.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadFile(path)
+	if string(data) != "first\n" {
+		t.Errorf("got current file %q, want the existing file rotated", data)
+	}
+	data, _ = ioutil.ReadFile(filepath.Join(dir, "events-20200601T130000.000.log"))
+	if string(data) != existing {
+		t.Errorf("got backup %q", data)
+	}
+}
+
+func TestNewDefaults(t *testing.T) {
+	sink, err := New("test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := sink.(*Sink).config
+	if config.Path != stdout || config.MaxSize != defaultMaxSize || config.MaxBackups != defaultMaxBackups {
+		t.Errorf("unexpected defaults %+v", config)
+	}
+	if _, err := New("test", json.RawMessage(`{"rotate": "1h"}`)); err == nil {
+		t.Error("expected error for unknown field")
+	}
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jsonlog
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupLayout is the layout of the time in the name of rotated files, which
+// sorts them chronologically.
+const backupLayout = "20060102T150405.000"
+
+// rotatingFile is a file rotated once writing to it would exceed its maximum
+// size, or at the first write after the rotation interval elapsed. The file
+// is renamed to its name suffixed by the rotation time, e.g.
+// events-20200601T120000.000.log, and compressed if required.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	interval   time.Duration
+	maxBackups int
+	compress   bool
+	now        func() time.Time
+
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+func openRotatingFile(path string, maxSize int64, interval time.Duration, maxBackups int, compress bool) (*rotatingFile, error) {
+	f := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		interval:   interval,
+		maxBackups: maxBackups,
+		compress:   compress,
+		now:        time.Now,
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the file, appending to an existing one. The age of an existing
+// file is counted from the time of its first line, so that restarts don't
+// postpone its rotation.
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	f.opened = f.now()
+	if f.size > 0 {
+		if first, err := firstLineTime(f.path); err == nil && !first.IsZero() {
+			f.opened = first
+		}
+	}
+	return nil
+}
+
+// firstLineTime returns the time field of the first line of the file.
+func firstLineTime(path string) (time.Time, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer file.Close()
+	data, err := bufio.NewReader(file).ReadBytes('\n')
+	if err != nil {
+		return time.Time{}, err
+	}
+	var l struct {
+		Time time.Time `json:"time"`
+	}
+	err = json.Unmarshal(data, &l)
+	return l.Time, err
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	expired := f.interval > 0 && f.now().Sub(f.opened) >= f.interval
+	if f.size > 0 && (f.size+int64(len(p)) > f.maxSize || expired) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	ext := filepath.Ext(f.path)
+	backup := strings.TrimSuffix(f.path, ext) + "-" + f.now().UTC().Format(backupLayout) + ext
+	if err := os.Rename(f.path, backup); err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	if f.compress {
+		if err := compressFile(backup); err != nil {
+			return err
+		}
+	}
+	return f.removeBackups()
+}
+
+// removeBackups removes the oldest rotated files beyond the maximum.
+func (f *rotatingFile) removeBackups() error {
+	ext := filepath.Ext(f.path)
+	backups, err := filepath.Glob(strings.TrimSuffix(f.path, ext) + "-*" + ext + "*")
+	if err != nil {
+		return err
+	}
+	sort.Strings(backups)
+	for len(backups) > f.maxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+func (f *rotatingFile) Close() error {
+	return f.file.Close()
+}
+
+// compressFile replaces the file by its gzipped copy.
+func compressFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	w := gzip.NewWriter(out)
+	if _, err := io.Copy(w, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kafka
+
+import (
+	"encoding/json"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/caicloud/event_exporter/pkg/eventutil"
+	"github.com/caicloud/event_exporter/pkg/internal/exportutil"
+	"github.com/caicloud/event_exporter/pkg/sinks"
+)
+
+func encodeJSON(record *sinks.Record) ([]byte, error) {
+	return json.Marshal(record)
+}
+
+// encodeProto encodes the record as the Record message of record.proto.
+func encodeProto(record *sinks.Record) ([]byte, error) {
+	event := record.Event
+	var e []byte
+	e = exportutil.AppendString(e, 1, string(event.UID))
+	e = exportutil.AppendString(e, 2, event.Namespace)
+	e = exportutil.AppendString(e, 3, event.Name)
+	e = exportutil.AppendMessage(e, 4, encodeObjectReference(event.InvolvedObject))
+	e = exportutil.AppendString(e, 5, event.Reason)
+	e = exportutil.AppendString(e, 6, event.Message)
+	e = exportutil.AppendString(e, 7, event.Type)
+	e = exportutil.AppendVarint(e, 8, uint64(eventutil.Count(event)))
+	e = exportutil.AppendString(e, 9, event.Source.Component)
+	e = exportutil.AppendString(e, 10, event.Source.Host)
+	if first := eventutil.FirstTime(event); !first.IsZero() {
+		e = exportutil.AppendMessage(e, 11, encodeTimestamp(first))
+	}
+	if last := eventutil.LastTime(event); !last.IsZero() {
+		e = exportutil.AppendMessage(e, 12, encodeTimestamp(last))
+	}
+	e = exportutil.AppendString(e, 13, event.ReportingController)
+	e = exportutil.AppendString(e, 14, event.ReportingInstance)
+	e = exportutil.AppendString(e, 15, event.Action)
+
+	var r []byte
+	r = exportutil.AppendString(r, 1, string(record.Kind))
+	r = exportutil.AppendMessage(r, 2, e)
+	if w := record.Workload; w != nil {
+		r = exportutil.AppendMessage(r, 3, encodeObjectReference(v1.ObjectReference{Kind: w.Kind, Namespace: w.Namespace, Name: w.Name}))
+	}
+	r = exportutil.AppendVarint(r, 4, uint64(record.Suppressed))
+	r = exportutil.AppendString(r, 5, record.Severity)
+	return r, nil
+}
+
+func encodeObjectReference(ref v1.ObjectReference) []byte {
+	var b []byte
+	b = exportutil.AppendString(b, 1, ref.Kind)
+	b = exportutil.AppendString(b, 2, ref.Namespace)
+	b = exportutil.AppendString(b, 3, ref.Name)
+	b = exportutil.AppendString(b, 4, string(ref.UID))
+	b = exportutil.AppendString(b, 5, ref.APIVersion)
+	b = exportutil.AppendString(b, 6, ref.FieldPath)
+	return b
+}
+
+func encodeTimestamp(t time.Time) []byte {
+	var b []byte
+	b = exportutil.AppendVarint(b, 1, uint64(t.Unix()))
+	return exportutil.AppendVarint(b, 2, uint64(t.Nanosecond()))
+}
@@ -0,0 +1,291 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kafka implements a sink publishing events to a Kafka topic.
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+	"k8s.io/apimachinery/pkg/util/runtime"
+
+	"github.com/caicloud/event_exporter/pkg/sinks"
+	"github.com/caicloud/event_exporter/pkg/sinks/cloudevents"
+	"github.com/caicloud/event_exporter/pkg/sinks/httpclient"
+)
+
+var (
+	acks = map[string]kafka.RequiredAcks{
+		"none": kafka.RequireNone,
+		"one":  kafka.RequireOne,
+		"all":  kafka.RequireAll,
+	}
+	compressions = map[string]kafka.Compression{
+		"none":   0,
+		"gzip":   kafka.Gzip,
+		"snappy": kafka.Snappy,
+		"lz4":    kafka.Lz4,
+		"zstd":   kafka.Zstd,
+	}
+	encodings = map[string]func(record *sinks.Record) ([]byte, error){
+		"json":     encodeJSON,
+		"protobuf": encodeProto,
+	}
+	contentTypes = map[string]string{
+		"json":     "application/json",
+		"protobuf": "application/protobuf",
+	}
+)
+
+func init() {
+	sinks.Register("kafka", New)
+}
+
+// Config configures a kafka sink.
+type Config struct {
+	Brokers []string `json:"brokers"`
+	Topic   string   `json:"topic"`
+	// PartitionKey is the key of the messages: the uid of the involved
+	// object or its namespace (default uid).
+	PartitionKey string `json:"partitionKey,omitempty"`
+	// Acks is the number of acknowledgements the brokers must send: none, one
+	// or all (default all).
+	Acks string `json:"acks,omitempty"`
+	// Compression of the messages: none, gzip, snappy, lz4 or zstd (default
+	// none).
+	Compression string `json:"compression,omitempty"`
+	// Encoding of the messages: json or protobuf (default json).
+	Encoding string `json:"encoding,omitempty"`
+	// CloudEvents publishes the records as CloudEvents in structured or
+	// binary mode, with the encoded record as data.
+	CloudEvents string                `json:"cloudEvents,omitempty"`
+	SASL        *SASLConfig           `json:"sasl,omitempty"`
+	TLS         *httpclient.TLSConfig `json:"tls,omitempty"`
+}
+
+// SASLConfig configures the SASL authentication to the brokers.
+type SASLConfig struct {
+	// Mechanism is plain, scram-sha-256 or scram-sha-512.
+	Mechanism string `json:"mechanism"`
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+}
+
+// producer is the part of kafka.Writer used by the sink.
+type producer interface {
+	WriteMessages(ctx context.Context, messages ...kafka.Message) error
+	Close() error
+}
+
+// Sink publishes records to a topic. Messages are written asynchronously in
+// batches, so delivery errors are logged instead of being returned by Send,
+// unless they are written one by one by SendSync.
+type Sink struct {
+	config     Config
+	writer     producer
+	syncWriter producer
+	encode     func(record *sinks.Record) ([]byte, error)
+}
+
+// New creates a kafka sink from its raw configuration.
+func New(_ string, raw json.RawMessage) (sinks.Sink, error) {
+	var config Config
+	if err := sinks.DecodeConfig(raw, &config); err != nil {
+		return nil, err
+	}
+	if len(config.Brokers) == 0 || config.Topic == "" {
+		return nil, fmt.Errorf("brokers and topic are required")
+	}
+	switch config.PartitionKey {
+	case "":
+		config.PartitionKey = "uid"
+	case "uid", "namespace":
+	default:
+		return nil, fmt.Errorf("unknown partition key %q", config.PartitionKey)
+	}
+	if config.Acks == "" {
+		config.Acks = "all"
+	}
+	if config.Compression == "" {
+		config.Compression = "none"
+	}
+	if config.Encoding == "" {
+		config.Encoding = "json"
+	}
+	encode, ok := encodings[config.Encoding]
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding %q", config.Encoding)
+	}
+	if err := cloudevents.ValidateMode(config.CloudEvents); err != nil {
+		return nil, err
+	}
+	writer, err := newWriter(config, true)
+	if err != nil {
+		return nil, err
+	}
+	syncWriter, err := newWriter(config, false)
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{config: config, writer: writer, syncWriter: syncWriter, encode: encode}, nil
+}
+
+// newWriter creates the writer of the topic. Synchronous writers write every
+// message on its own instead of waiting for a batch.
+func newWriter(config Config, async bool) (*kafka.Writer, error) {
+	requiredAcks, ok := acks[config.Acks]
+	if !ok {
+		return nil, fmt.Errorf("unknown acks %q", config.Acks)
+	}
+	compression, ok := compressions[config.Compression]
+	if !ok {
+		return nil, fmt.Errorf("unknown compression %q", config.Compression)
+	}
+	transport := &kafka.Transport{}
+	if config.TLS != nil {
+		tlsConfig, err := httpclient.NewTLSConfig(*config.TLS)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLS = tlsConfig
+	}
+	if config.SASL != nil {
+		mechanism, err := newMechanism(*config.SASL)
+		if err != nil {
+			return nil, err
+		}
+		transport.SASL = mechanism
+	}
+	topic := config.Topic
+	w := &kafka.Writer{
+		Addr:         kafka.TCP(config.Brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Murmur2Balancer{},
+		RequiredAcks: requiredAcks,
+		Compression:  compression,
+		Async:        async,
+		Transport:    transport,
+	}
+	if async {
+		w.Completion = func(messages []kafka.Message, err error) {
+			if err != nil {
+				runtime.HandleError(fmt.Errorf("failed to publish %d events to topic %s: %v", len(messages), topic, err))
+			}
+		}
+	} else {
+		w.BatchSize = 1
+	}
+	return w, nil
+}
+
+func newMechanism(config SASLConfig) (sasl.Mechanism, error) {
+	switch config.Mechanism {
+	case "plain":
+		return plain.Mechanism{Username: config.Username, Password: config.Password}, nil
+	case "scram-sha-256":
+		return scram.Mechanism(scram.SHA256, config.Username, config.Password)
+	case "scram-sha-512":
+		return scram.Mechanism(scram.SHA512, config.Username, config.Password)
+	}
+	return nil, fmt.Errorf("unknown sasl mechanism %q", config.Mechanism)
+}
+
+// Start implements sinks.Sink.
+func (s *Sink) Start(_ <-chan struct{}) error {
+	return nil
+}
+
+// Send implements sinks.Sink.
+func (s *Sink) Send(record *sinks.Record) error {
+	message, err := s.message(record)
+	if err != nil {
+		return err
+	}
+	return s.writer.WriteMessages(context.Background(), message)
+}
+
+// SendSync implements sinks.SyncSink, returning once the brokers acknowledged
+// the message.
+func (s *Sink) SendSync(record *sinks.Record) error {
+	message, err := s.message(record)
+	if err != nil {
+		return err
+	}
+	return s.syncWriter.WriteMessages(context.Background(), message)
+}
+
+func (s *Sink) message(record *sinks.Record) (kafka.Message, error) {
+	value, err := s.encode(record)
+	if err != nil {
+		return kafka.Message{}, err
+	}
+	message := kafka.Message{Key: []byte(s.key(record)), Value: value}
+	if s.config.CloudEvents != "" {
+		if err := s.cloudEvent(record, &message); err != nil {
+			return kafka.Message{}, err
+		}
+	}
+	return message, nil
+}
+
+// cloudEvent wraps the encoded record of the message in a CloudEvent.
+func (s *Sink) cloudEvent(record *sinks.Record, message *kafka.Message) error {
+	contentType := contentTypes[s.config.Encoding]
+	event := cloudevents.NewEvent(record, contentType, message.Value)
+	if s.config.CloudEvents == cloudevents.Binary {
+		message.Headers = append(message.Headers, kafka.Header{Key: "content-type", Value: []byte(contentType)})
+		for name, value := range event.Attributes() {
+			message.Headers = append(message.Headers, kafka.Header{Key: "ce_" + name, Value: []byte(value)})
+		}
+		return nil
+	}
+	value, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	message.Value = value
+	message.Headers = append(message.Headers, kafka.Header{Key: "content-type", Value: []byte(cloudevents.StructuredContentType)})
+	return nil
+}
+
+func (s *Sink) key(record *sinks.Record) string {
+	object := record.Event.InvolvedObject
+	if s.config.PartitionKey == "namespace" {
+		return object.Namespace
+	}
+	if object.UID != "" {
+		return string(object.UID)
+	}
+	// Objects referenced without uid, e.g. by synthesized events, are keyed
+	// by name instead.
+	return object.Kind + "/" + object.Namespace + "/" + object.Name
+}
+
+// Close implements sinks.Sink, waiting for the pending messages to be
+// written.
+func (s *Sink) Close() error {
+	err := s.writer.Close()
+	if syncErr := s.syncWriter.Close(); err == nil {
+		err = syncErr
+	}
+	return err
+}
@@ -0,0 +1,260 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/protobuf/encoding/protowire"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/event_exporter/pkg/sinks"
+	"github.com/caicloud/event_exporter/pkg/workload"
+)
+
+type fakeProducer struct {
+	messages []kafka.Message
+	closed   bool
+}
+
+func (p *fakeProducer) WriteMessages(_ context.Context, messages ...kafka.Message) error {
+	p.messages = append(p.messages, messages...)
+	return nil
+}
+
+func (p *fakeProducer) Close() error {
+	p.closed = true
+	return nil
+}
+
+var last = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newRecord() *sinks.Record {
+	return &sinks.Record{
+		Kind:     sinks.Updated,
+		Severity: "warning",
+		Event: &v1.Event{
+			ObjectMeta:     meta_v1.ObjectMeta{Name: "nginx-1.1", Namespace: "default", UID: "event-uid"},
+			InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "nginx-1", UID: "pod-uid"},
+			Reason:         "BackOff",
+			Message:        "Back-off restarting failed container",
+			Type:           v1.EventTypeWarning,
+			Count:          3,
+			Source:         v1.EventSource{Component: "kubelet", Host: "node-1"},
+			FirstTimestamp: meta_v1.NewTime(last.Add(-time.Hour)),
+			LastTimestamp:  meta_v1.NewTime(last),
+		},
+		Workload: &workload.Reference{Kind: "Deployment", Namespace: "default", Name: "nginx"},
+	}
+}
+
+func newSink(t *testing.T, config string) (*Sink, *fakeProducer) {
+	sink, err := New("test", json.RawMessage(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := sink.(*Sink)
+	p := &fakeProducer{}
+	s.writer = p
+	s.syncWriter = p
+	return s, p
+}
+
+func TestSinkJSON(t *testing.T) {
+	s, p := newSink(t, `{"brokers": ["localhost:9092"], "topic": "events"}`)
+	record := newRecord()
+	if err := s.Send(record); err != nil {
+		t.Fatal(err)
+	}
+	synthesized := newRecord()
+	synthesized.Event.InvolvedObject.UID = ""
+	if err := s.Send(synthesized); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !p.closed {
+		t.Error("producer was not closed")
+	}
+
+	if len(p.messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(p.messages))
+	}
+	if got := string(p.messages[0].Key); got != "pod-uid" {
+		t.Errorf("got key %s, want pod-uid", got)
+	}
+	if got := string(p.messages[1].Key); got != "Pod/default/nginx-1" {
+		t.Errorf("got key %s, want Pod/default/nginx-1", got)
+	}
+	var got sinks.Record
+	if err := json.Unmarshal(p.messages[0].Value, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Kind != record.Kind || got.Event.Reason != "BackOff" || *got.Workload != *record.Workload {
+		t.Errorf("got record %+v", got)
+	}
+}
+
+func TestSinkProtobuf(t *testing.T) {
+	s, p := newSink(t, `{"brokers": ["localhost:9092"], "topic": "events", "partitionKey": "namespace", "encoding": "protobuf"}`)
+	if err := s.Send(newRecord()); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(p.messages[0].Key); got != "default" {
+		t.Errorf("got key %s, want default", got)
+	}
+
+	record := fields(t, p.messages[0].Value)
+	if got := string(record[1]); got != "Updated" {
+		t.Errorf("got kind %s", got)
+	}
+	event := fields(t, record[2])
+	want := map[protowire.Number]string{
+		1: "event-uid", 2: "default", 3: "nginx-1.1", 5: "BackOff",
+		6: "Back-off restarting failed container", 7: "Warning", 9: "kubelet", 10: "node-1",
+	}
+	for num, value := range want {
+		if got := string(event[num]); got != value {
+			t.Errorf("got field %d %q, want %q", num, got, value)
+		}
+	}
+	if count, _ := protowire.ConsumeVarint(event[8]); count != 3 {
+		t.Errorf("got count %d, want 3", count)
+	}
+	object := fields(t, event[4])
+	if string(object[1]) != "Pod" || string(object[3]) != "nginx-1" || string(object[4]) != "pod-uid" {
+		t.Errorf("got involved object %q", object)
+	}
+	seconds, _ := protowire.ConsumeVarint(fields(t, event[12])[1])
+	if int64(seconds) != last.Unix() {
+		t.Errorf("got last time %d, want %d", seconds, last.Unix())
+	}
+	owner := fields(t, record[3])
+	if string(owner[1]) != "Deployment" || string(owner[3]) != "nginx" {
+		t.Errorf("got workload %q", owner)
+	}
+	if got := string(record[5]); got != "warning" {
+		t.Errorf("got severity %s", got)
+	}
+}
+
+func TestSinkCloudEvents(t *testing.T) {
+	s, p := newSink(t, `{"brokers": ["localhost:9092"], "topic": "events", "encoding": "protobuf", "cloudEvents": "binary"}`)
+	if err := s.Send(newRecord()); err != nil {
+		t.Fatal(err)
+	}
+	headers := make(map[string]string)
+	for _, header := range p.messages[0].Headers {
+		headers[header.Key] = string(header.Value)
+	}
+	want := map[string]string{
+		"content-type":   "application/protobuf",
+		"ce_specversion": "1.0",
+		"ce_id":          "event-uid-3",
+		"ce_source":      "kubelet",
+		"ce_type":        "io.k8s.event.warning.BackOff",
+		"ce_subject":     "Pod/default/nginx-1",
+		"ce_time":        "2020-06-01T12:00:00Z",
+	}
+	if !reflect.DeepEqual(headers, want) {
+		t.Errorf("got headers %v, want %v", headers, want)
+	}
+	if string(fields(t, p.messages[0].Value)[1]) != "Updated" {
+		t.Error("binary mode value is not the encoded record")
+	}
+
+	s, p = newSink(t, `{"brokers": ["localhost:9092"], "topic": "events", "cloudEvents": "structured"}`)
+	if err := s.Send(newRecord()); err != nil {
+		t.Fatal(err)
+	}
+	var envelope struct {
+		Type string       `json:"type"`
+		Data sinks.Record `json:"data"`
+	}
+	if err := json.Unmarshal(p.messages[0].Value, &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if envelope.Type != "io.k8s.event.warning.BackOff" || envelope.Data.Event.Reason != "BackOff" {
+		t.Errorf("unexpected structured message %s", p.messages[0].Value)
+	}
+	if h := p.messages[0].Headers; len(h) != 1 || string(h[0].Value) != "application/cloudevents+json" {
+		t.Errorf("got headers %v", h)
+	}
+}
+
+// fields decodes a protobuf message without repeated fields into the raw
+// values of its fields.
+func fields(t *testing.T, message []byte) map[protowire.Number][]byte {
+	result := make(map[protowire.Number][]byte)
+	for len(message) > 0 {
+		num, typ, n := protowire.ConsumeTag(message)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		message = message[n:]
+		switch typ {
+		case protowire.BytesType:
+			result[num], n = protowire.ConsumeBytes(message)
+		case protowire.VarintType:
+			_, n = protowire.ConsumeVarint(message)
+			if n >= 0 {
+				result[num] = message[:n]
+			}
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		message = message[n:]
+	}
+	return result
+}
+
+func TestNewWriter(t *testing.T) {
+	w, err := newWriter(Config{
+		Brokers:     []string{"kafka-0:9092", "kafka-1:9092"},
+		Topic:       "events",
+		Acks:        "one",
+		Compression: "zstd",
+		SASL:        &SASLConfig{Mechanism: "scram-sha-512", Username: "user", Password: "pass"},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Addr.String() != "kafka-0:9092,kafka-1:9092" || w.Topic != "events" {
+		t.Errorf("got addr %s and topic %s", w.Addr, w.Topic)
+	}
+	if w.RequiredAcks != kafka.RequireOne || w.Compression != kafka.Zstd || !w.Async {
+		t.Errorf("got acks %v, compression %v and async %v", w.RequiredAcks, w.Compression, w.Async)
+	}
+	transport := w.Transport.(*kafka.Transport)
+	if transport.SASL == nil || transport.SASL.Name() != "SCRAM-SHA-512" || transport.TLS != nil {
+		t.Errorf("unexpected transport %+v", transport)
+	}
+	if !reflect.DeepEqual(w.Balancer, &kafka.Murmur2Balancer{}) {
+		t.Errorf("got balancer %T", w.Balancer)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	for _, config := range []string{
+		`{"brokers": ["localhost:9092"]}`,
+		`{"topic": "events"}`,
+		`{"brokers": ["localhost:9092"], "topic": "events", "partitionKey": "name"}`,
+		`{"brokers": ["localhost:9092"], "topic": "events", "acks": "two"}`,
+		`{"brokers": ["localhost:9092"], "topic": "events", "compression": "brotli"}`,
+		`{"brokers": ["localhost:9092"], "topic": "events", "encoding": "avro"}`,
+		`{"brokers": ["localhost:9092"], "topic": "events", "cloudEvents": "batched"}`,
+		`{"brokers": ["localhost:9092"], "topic": "events", "sasl": {"mechanism": "gssapi"}}`,
+	} {
+		if _, err := New("test", json.RawMessage(config)); err == nil {
+			t.Errorf("expected error for config %s", config)
+		}
+	}
+}
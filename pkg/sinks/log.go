@@ -0,0 +1,64 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+
+	"k8s.io/klog/v2"
+)
+
+func init() {
+	Register("log", func(name string, _ json.RawMessage) (Sink, error) {
+		return &logSink{name: name}, nil
+	})
+}
+
+// logSink writes records to the exporter's log. It needs no configuration.
+type logSink struct {
+	name string
+}
+
+func (s *logSink) Start(_ <-chan struct{}) error {
+	return nil
+}
+
+func (s *logSink) Send(record *Record) error {
+	event := record.Event
+	klog.Infof(
+		"sink %s: %s event name: %s,count: %d,involvedObject_namespace: %s,involvedObject_kind: %s,involvedObject_name: %s,reason: %s,type: %s,message: %s",
+		s.name,
+		record.Kind,
+		event.Name,
+		event.Count,
+		event.InvolvedObject.Namespace,
+		event.InvolvedObject.Kind,
+		event.InvolvedObject.Name,
+		event.Reason,
+		event.Type,
+		event.Message,
+	)
+	return nil
+}
+
+func (s *logSink) SendSync(record *Record) error {
+	return s.Send(record)
+}
+
+func (s *logSink) Close() error {
+	return nil
+}
@@ -0,0 +1,384 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package loki implements a sink pushing events to Grafana Loki.
+package loki
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/caicloud/event_exporter/pkg/eventutil"
+	"github.com/caicloud/event_exporter/pkg/sinks"
+	"github.com/caicloud/event_exporter/pkg/sinks/httpclient"
+)
+
+const (
+	defaultBatchSize     = 1000
+	defaultFlushInterval = time.Second
+)
+
+var (
+	defaultLabels       = []string{"namespace", "kind", "reason", "type"}
+	defaultStaticLabels = map[string]string{"job": "event-exporter"}
+)
+
+// streamLabels are the record fields available as stream labels. They are
+// meant to keep the number of streams low, which rules out object names.
+var streamLabels = map[string]func(record *sinks.Record) string{
+	"namespace": func(record *sinks.Record) string { return record.Event.InvolvedObject.Namespace },
+	"kind":      func(record *sinks.Record) string { return record.Event.InvolvedObject.Kind },
+	"reason":    func(record *sinks.Record) string { return record.Event.Reason },
+	"type":      func(record *sinks.Record) string { return record.Event.Type },
+	"source":    func(record *sinks.Record) string { return record.Event.Source.Component },
+	"severity":  func(record *sinks.Record) string { return record.Severity },
+}
+
+func init() {
+	sinks.Register("loki", New)
+}
+
+// Config configures a loki sink.
+type Config struct {
+	// URL is the base URL of Loki, e.g. http://loki:3100.
+	URL string `json:"url"`
+	// TenantID is sent in the X-Scope-OrgID header.
+	TenantID string `json:"tenantID,omitempty"`
+	// Labels are the record fields labeling the streams: namespace, kind,
+	// reason, type, source and severity (default namespace, kind, reason and
+	// type).
+	Labels []string `json:"labels,omitempty"`
+	// StaticLabels label every stream (default job=event-exporter).
+	StaticLabels map[string]string `json:"staticLabels,omitempty"`
+	// Format of the lines: logfmt or json (default logfmt).
+	Format string `json:"format,omitempty"`
+	// Compression of the requests: none, gzip or snappy (default none).
+	// Snappy compressed requests are encoded with protobuf, the others with
+	// JSON.
+	Compression string `json:"compression,omitempty"`
+	// BatchSize is the number of lines pushed by a request (default 1000).
+	BatchSize int `json:"batchSize,omitempty"`
+	// FlushInterval is the longest a line waits for its batch to fill up
+	// (default 1s).
+	FlushInterval meta_v1.Duration `json:"flushInterval,omitempty"`
+	httpclient.Config
+}
+
+type entry struct {
+	timestamp time.Time
+	line      string
+}
+
+type stream struct {
+	labels  map[string]string
+	entries []entry
+}
+
+// Sink pushes records as log lines in batches. Lines are timestamped with the
+// time they were sent to the sink, as Loki rejects the lines older than the
+// last one of their stream, and events are replayed or updated out of order.
+type Sink struct {
+	config Config
+	url    string
+	client *httpclient.Client
+	stopCh <-chan struct{}
+	now    func() time.Time
+
+	locker  sync.Mutex
+	streams map[string]*stream
+	size    int
+}
+
+// New creates a loki sink from its raw configuration.
+func New(_ string, raw json.RawMessage) (sinks.Sink, error) {
+	var config Config
+	if err := sinks.DecodeConfig(raw, &config); err != nil {
+		return nil, err
+	}
+	if config.URL == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	if config.Labels == nil {
+		config.Labels = defaultLabels
+	}
+	for _, label := range config.Labels {
+		if _, ok := streamLabels[label]; !ok {
+			return nil, fmt.Errorf("unknown label %q", label)
+		}
+	}
+	if config.StaticLabels == nil {
+		config.StaticLabels = defaultStaticLabels
+	}
+	switch config.Format {
+	case "":
+		config.Format = "logfmt"
+	case "logfmt", "json":
+	default:
+		return nil, fmt.Errorf("unknown format %q", config.Format)
+	}
+	switch config.Compression {
+	case "":
+		config.Compression = "none"
+	case "none", "gzip", "snappy":
+	default:
+		return nil, fmt.Errorf("unknown compression %q", config.Compression)
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.FlushInterval.Duration <= 0 {
+		config.FlushInterval.Duration = defaultFlushInterval
+	}
+	client, err := httpclient.New(config.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{
+		config:  config,
+		url:     strings.TrimSuffix(config.URL, "/") + "/loki/api/v1/push",
+		client:  client,
+		now:     time.Now,
+		streams: make(map[string]*stream),
+	}, nil
+}
+
+// Start implements sinks.Sink.
+func (s *Sink) Start(stopCh <-chan struct{}) error {
+	s.stopCh = stopCh
+	go wait.Until(func() {
+		if err := s.flush(); err != nil {
+			runtime.HandleError(err)
+		}
+	}, s.config.FlushInterval.Duration, stopCh)
+	return nil
+}
+
+// Send implements sinks.Sink, flushing the batch once it is full. Deleted
+// events are not pushed.
+func (s *Sink) Send(record *sinks.Record) error {
+	if record.Kind == sinks.Deleted {
+		return nil
+	}
+	labels, e, err := s.entry(record)
+	if err != nil {
+		return err
+	}
+	key := labelString(labels)
+
+	s.locker.Lock()
+	st, ok := s.streams[key]
+	if !ok {
+		st = &stream{labels: labels}
+		s.streams[key] = st
+	}
+	st.entries = append(st.entries, e)
+	s.size++
+	full := s.size >= s.config.BatchSize
+	s.locker.Unlock()
+
+	if full {
+		return s.flush()
+	}
+	return nil
+}
+
+// SendSync implements sinks.SyncSink, pushing the record on its own.
+func (s *Sink) SendSync(record *sinks.Record) error {
+	if record.Kind == sinks.Deleted {
+		return nil
+	}
+	labels, e, err := s.entry(record)
+	if err != nil {
+		return err
+	}
+	st := &stream{labels: labels, entries: []entry{e}}
+	return s.push([]string{labelString(labels)}, []*stream{st}, 1)
+}
+
+// entry returns the stream labels and the log entry of the record.
+func (s *Sink) entry(record *sinks.Record) (map[string]string, entry, error) {
+	line, err := s.line(record)
+	if err != nil {
+		return nil, entry{}, err
+	}
+	return s.labels(record), entry{timestamp: s.now(), line: line}, nil
+}
+
+func (s *Sink) labels(record *sinks.Record) map[string]string {
+	labels := make(map[string]string, len(s.config.StaticLabels)+len(s.config.Labels))
+	for name, value := range s.config.StaticLabels {
+		labels[name] = value
+	}
+	for _, name := range s.config.Labels {
+		if value := streamLabels[name](record); value != "" {
+			labels[name] = value
+		}
+	}
+	return labels
+}
+
+func (s *Sink) line(record *sinks.Record) (string, error) {
+	if s.config.Format == "json" {
+		data, err := json.Marshal(record)
+		return string(data), err
+	}
+	event := record.Event
+	var b strings.Builder
+	for _, field := range [][2]string{
+		{"time", eventutil.LastTime(event).UTC().Format(time.RFC3339Nano)},
+		{"record", string(record.Kind)},
+		{"type", event.Type},
+		{"severity", record.Severity},
+		{"reason", event.Reason},
+		{"namespace", event.InvolvedObject.Namespace},
+		{"kind", event.InvolvedObject.Kind},
+		{"name", event.InvolvedObject.Name},
+		{"count", strconv.Itoa(int(eventutil.Count(event)))},
+		{"source", event.Source.Component},
+		{"message", event.Message},
+	} {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(field[0])
+		b.WriteByte('=')
+		b.WriteString(logfmtValue(field[1]))
+	}
+	return b.String(), nil
+}
+
+func logfmtValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " =\"\\\t\r\n") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// labelString formats labels as a sorted Prometheus label set.
+func labelString(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[name]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// flush pushes the pending batch.
+func (s *Sink) flush() error {
+	s.locker.Lock()
+	if s.size == 0 {
+		s.locker.Unlock()
+		return nil
+	}
+	streams := make([]*stream, 0, len(s.streams))
+	keys := make([]string, 0, len(s.streams))
+	for key, st := range s.streams {
+		keys = append(keys, key)
+		streams = append(streams, st)
+	}
+	size := s.size
+	s.streams = make(map[string]*stream)
+	s.size = 0
+	s.locker.Unlock()
+	return s.push(keys, streams, size)
+}
+
+// push pushes the streams of size lines.
+func (s *Sink) push(keys []string, streams []*stream, size int) error {
+	body, header, err := s.encode(keys, streams)
+	if err != nil {
+		return err
+	}
+	if _, err := s.client.Do(s.stopCh, http.MethodPost, s.url, body, header); err != nil {
+		return fmt.Errorf("failed to push %d lines: %w", size, err)
+	}
+	return nil
+}
+
+type jsonStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func (s *Sink) encode(keys []string, streams []*stream) ([]byte, http.Header, error) {
+	header := make(http.Header)
+	if s.config.TenantID != "" {
+		header.Set("X-Scope-OrgID", s.config.TenantID)
+	}
+	if s.config.Compression == "snappy" {
+		header.Set("Content-Type", "application/x-protobuf")
+		return snappy.Encode(nil, encodePushRequest(keys, streams)), header, nil
+	}
+
+	request := struct {
+		Streams []jsonStream `json:"streams"`
+	}{}
+	for _, st := range streams {
+		js := jsonStream{Stream: st.labels}
+		for _, e := range st.entries {
+			js.Values = append(js.Values, [2]string{strconv.FormatInt(e.timestamp.UnixNano(), 10), e.line})
+		}
+		request.Streams = append(request.Streams, js)
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, nil, err
+	}
+	header.Set("Content-Type", "application/json")
+	if s.config.Compression == "gzip" {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(body); err != nil {
+			return nil, nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, nil, err
+		}
+		body = buf.Bytes()
+		header.Set("Content-Encoding", "gzip")
+	}
+	return body, header, nil
+}
+
+// Close implements sinks.Sink, pushing the pending batch.
+func (s *Sink) Close() error {
+	return s.flush()
+}
@@ -0,0 +1,215 @@
+package loki
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/event_exporter/pkg/sinks"
+)
+
+var now = time.Date(2020, 6, 1, 12, 0, 0, 500, time.UTC)
+
+func newRecord(namespace, reason string, last time.Time) *sinks.Record {
+	return &sinks.Record{Kind: sinks.Added, Severity: "warning", Event: &v1.Event{
+		ObjectMeta:     meta_v1.ObjectMeta{Name: "nginx.1", Namespace: namespace},
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: namespace, Name: "nginx"},
+		Reason:         reason,
+		Message:        `Back-off restarting failed container "nginx"`,
+		Type:           v1.EventTypeWarning,
+		Count:          2,
+		LastTimestamp:  meta_v1.NewTime(last),
+	}}
+}
+
+type push struct {
+	header http.Header
+	body   []byte
+}
+
+func newServer(t *testing.T, pushes *[]push) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/loki/api/v1/push" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		*pushes = append(*pushes, push{header: r.Header, body: data})
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func newSink(t *testing.T, config string) *Sink {
+	sink, err := New("test", json.RawMessage(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := sink.(*Sink)
+	s.stopCh = make(chan struct{})
+	tick := now
+	s.now = func() time.Time {
+		tick = tick.Add(time.Millisecond)
+		return tick
+	}
+	return s
+}
+
+func TestSinkJSON(t *testing.T) {
+	var pushes []push
+	server := newServer(t, &pushes)
+	defer server.Close()
+
+	s := newSink(t, `{"url": "`+server.URL+`", "tenantID": "team-a", "labels": ["namespace", "reason", "severity"], "compression": "gzip", "batchSize": 3}`)
+	for _, record := range []*sinks.Record{
+		newRecord("default", "BackOff", now),
+		newRecord("default", "BackOff", now.Add(-time.Second)),
+		{Kind: sinks.Deleted, Event: newRecord("default", "BackOff", now).Event},
+		newRecord("", "NodeNotReady", now),
+	} {
+		if err := s.Send(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(pushes) != 1 {
+		t.Fatalf("got %d pushes, want 1", len(pushes))
+	}
+	p := pushes[0]
+	if p.header.Get("X-Scope-OrgID") != "team-a" || p.header.Get("Content-Encoding") != "gzip" {
+		t.Errorf("unexpected headers %v", p.header)
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(p.body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(reader)
+	var request struct {
+		Streams []jsonStream `json:"streams"`
+	}
+	if err := json.Unmarshal(data, &request); err != nil {
+		t.Fatal(err)
+	}
+	line := `time=2020-06-01T12:00:00.0000005Z record=Added type=Warning severity=warning reason=BackOff namespace=default kind=Pod name=nginx count=2 source="" message="Back-off restarting failed container \"nginx\""`
+	want := map[string]jsonStream{
+		"BackOff": {
+			Stream: map[string]string{"job": "event-exporter", "namespace": "default", "reason": "BackOff", "severity": "warning"},
+			Values: [][2]string{
+				{"1591012800001000500", line},
+				{"1591012800002000500", strings.Replace(line, "12:00:00", "11:59:59", 1)},
+			},
+		},
+		"NodeNotReady": {
+			Stream: map[string]string{"job": "event-exporter", "reason": "NodeNotReady", "severity": "warning"},
+			Values: [][2]string{
+				{"1591012800003000500", `time=2020-06-01T12:00:00.0000005Z record=Added type=Warning severity=warning reason=NodeNotReady namespace="" kind=Pod name=nginx count=2 source="" message="Back-off restarting failed container \"nginx\""`},
+			},
+		},
+	}
+	if len(request.Streams) != len(want) {
+		t.Fatalf("got %d streams, want %d", len(request.Streams), len(want))
+	}
+	for _, got := range request.Streams {
+		if !reflect.DeepEqual(got, want[got.Stream["reason"]]) {
+			t.Errorf("got stream %+v, want %+v", got, want[got.Stream["reason"]])
+		}
+	}
+}
+
+func TestSinkSnappy(t *testing.T) {
+	var pushes []push
+	server := newServer(t, &pushes)
+	defer server.Close()
+
+	s := newSink(t, `{"url": "`+server.URL+`", "format": "json", "compression": "snappy", "labels": ["type"], "staticLabels": {}}`)
+	record := newRecord("default", "BackOff", now)
+	if err := s.Send(record); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(pushes) != 1 {
+		t.Fatalf("got %d pushes, want 1", len(pushes))
+	}
+	if got := pushes[0].header.Get("Content-Type"); got != "application/x-protobuf" {
+		t.Errorf("got content type %s", got)
+	}
+	data, err := snappy.Decode(nil, pushes[0].body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streams := fields(t, data)[1]
+	if len(streams) != 1 {
+		t.Fatalf("got %d streams, want 1", len(streams))
+	}
+	stream := fields(t, streams[0])
+	if got := string(stream[1][0]); got != `{type="Warning"}` {
+		t.Errorf("got labels %s", got)
+	}
+	entry := fields(t, stream[2][0])
+	timestamp := fields(t, entry[1][0])
+	seconds, _ := protowire.ConsumeVarint(timestamp[1][0])
+	nanos, _ := protowire.ConsumeVarint(timestamp[2][0])
+	if seconds != uint64(now.Unix()) || nanos != 1000500 {
+		t.Errorf("got timestamp %d.%d", seconds, nanos)
+	}
+	line, _ := json.Marshal(record)
+	if got := string(entry[2][0]); got != string(line) {
+		t.Errorf("got line %s, want %s", got, line)
+	}
+}
+
+// fields decodes a protobuf message into the raw values of its fields. Varint
+// values are returned in their encoded form.
+func fields(t *testing.T, message []byte) map[protowire.Number][][]byte {
+	result := make(map[protowire.Number][][]byte)
+	for len(message) > 0 {
+		num, typ, n := protowire.ConsumeTag(message)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		message = message[n:]
+		var value []byte
+		switch typ {
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(message)
+		case protowire.VarintType:
+			_, n = protowire.ConsumeVarint(message)
+			if n >= 0 {
+				value = message[:n]
+			}
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		result[num] = append(result[num], value)
+		message = message[n:]
+	}
+	return result
+}
+
+func TestNewErrors(t *testing.T) {
+	for _, config := range []string{
+		`{}`,
+		`{"url": "http://localhost", "labels": ["name"]}`,
+		`{"url": "http://localhost", "format": "text"}`,
+		`{"url": "http://localhost", "compression": "zstd"}`,
+	} {
+		if _, err := New("test", json.RawMessage(config)); err == nil {
+			t.Errorf("expected error for config %s", config)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loki
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// encodePushRequest encodes the streams as a logproto.PushRequest:
+//
+//	message PushRequest { repeated Stream streams = 1; }
+//	message Stream { string labels = 1; repeated Entry entries = 2; }
+//	message Entry { google.protobuf.Timestamp timestamp = 1; string line = 2; }
+func encodePushRequest(keys []string, streams []*stream) []byte {
+	var request []byte
+	for i, st := range streams {
+		var s []byte
+		s = protowire.AppendTag(s, 1, protowire.BytesType)
+		s = protowire.AppendString(s, keys[i])
+		for _, e := range st.entries {
+			var timestamp []byte
+			timestamp = protowire.AppendTag(timestamp, 1, protowire.VarintType)
+			timestamp = protowire.AppendVarint(timestamp, uint64(e.timestamp.Unix()))
+			if nanos := e.timestamp.Nanosecond(); nanos != 0 {
+				timestamp = protowire.AppendTag(timestamp, 2, protowire.VarintType)
+				timestamp = protowire.AppendVarint(timestamp, uint64(nanos))
+			}
+			var entry []byte
+			entry = protowire.AppendTag(entry, 1, protowire.BytesType)
+			entry = protowire.AppendBytes(entry, timestamp)
+			entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+			entry = protowire.AppendString(entry, e.line)
+
+			s = protowire.AppendTag(s, 2, protowire.BytesType)
+			s = protowire.AppendBytes(s, entry)
+		}
+		request = protowire.AppendTag(request, 1, protowire.BytesType)
+		request = protowire.AppendBytes(request, s)
+	}
+	return request
+}
@@ -0,0 +1,389 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"github.com/caicloud/event_exporter/pkg/eventutil"
+	"github.com/caicloud/event_exporter/pkg/filters"
+	"github.com/caicloud/event_exporter/pkg/severity"
+	"github.com/caicloud/event_exporter/pkg/workload"
+)
+
+type namedSink struct {
+	name     string
+	sink     Sink
+	filters  []filters.EventFilter
+	throttle *throttle
+	// Records are either buffered on disk or queued in memory.
+	buffer *buffer
+	queue  *queue
+	done   chan struct{}
+}
+
+func (s *namedSink) accepts(event *v1.Event) bool {
+	for _, filter := range s.filters {
+		if !filter.Filter(event) {
+			return false
+		}
+	}
+	return true
+}
+
+// send sends the record to the sink, counting the result.
+func (s *namedSink) send(record *Record) error {
+	if record.Group != nil {
+		return s.result(record, s.sink.(GroupSink).SendGroup(record))
+	}
+	return s.result(record, s.sink.Send(record))
+}
+
+// sendSync delivers a buffered record, which requires the sink to be a
+// SyncSink.
+func (s *namedSink) sendSync(record *Record) error {
+	if record.Group != nil {
+		return s.result(record, s.sink.(GroupSink).SendGroup(record))
+	}
+	return s.result(record, s.sink.(SyncSink).SendSync(record))
+}
+
+// result counts the result of sending the record.
+func (s *namedSink) result(record *Record, err error) error {
+	if err != nil {
+		sinkRecords.WithLabelValues(s.name, resultError).Inc()
+		runtime.HandleError(fmt.Errorf("sink %s failed to send event %s/%s: %v",
+			s.name, record.Event.Namespace, record.Event.Name, err))
+		return err
+	}
+	sinkRecords.WithLabelValues(s.name, resultSuccess).Inc()
+	return nil
+}
+
+// run sends the queued records until the queue is closed and drained.
+func (s *namedSink) run() {
+	defer close(s.done)
+	for {
+		record, ok := s.queue.pop()
+		if !ok {
+			return
+		}
+		sinkQueueRecords.WithLabelValues(s.name).Set(float64(s.queue.len()))
+		s.send(record)
+	}
+}
+
+// Manager fans records out to the configured sinks.
+type Manager struct {
+	sinks []*namedSink
+	// route routes the records to the receivers if set, otherwise every
+	// sink receives every record.
+	route      *route
+	receivers  map[string]*namedSink
+	resolver   *workload.Resolver
+	classifier *severity.Classifier
+	now        func() time.Time
+	locker     sync.RWMutex
+	closed     bool
+}
+
+// NewManager creates the configured sinks, and the routing tree sending
+// records to them if route is set.
+func NewManager(configs []Config, route *RouteConfig) (*Manager, error) {
+	m := &Manager{receivers: make(map[string]*namedSink), classifier: severity.Default(), now: time.Now}
+	names := make(map[string]bool)
+	for _, config := range configs {
+		if config.Name == "" {
+			return nil, fmt.Errorf("sink of type %q has no name", config.Type)
+		}
+		if names[config.Name] {
+			return nil, fmt.Errorf("duplicate sink name %q", config.Name)
+		}
+		names[config.Name] = true
+		sink, err := newSink(config.Type, config.Name, config.Config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create sink %q: %v", config.Name, err)
+		}
+		if route != nil && len(config.Filter.filters()) > 0 {
+			return nil, fmt.Errorf("sink %q has a filter, which the route replaces", config.Name)
+		}
+		s := &namedSink{
+			name:    config.Name,
+			sink:    sink,
+			filters: config.Filter.filters(),
+		}
+		if config.Throttle != nil {
+			if s.throttle, err = newThrottle(*config.Throttle); err != nil {
+				return nil, fmt.Errorf("failed to create sink %q: %v", config.Name, err)
+			}
+		}
+		if config.Buffer != nil && config.Queue != (QueueConfig{}) {
+			return nil, fmt.Errorf("sink %q has both a queue and a buffer", config.Name)
+		}
+		if config.Buffer != nil {
+			if _, ok := sink.(SyncSink); !ok {
+				return nil, fmt.Errorf("sink %q of type %q can't be buffered", config.Name, config.Type)
+			}
+			s.buffer, err = newBuffer(config.Name, *config.Buffer)
+		} else {
+			s.queue, err = newQueue(config.Queue)
+			s.done = make(chan struct{})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create sink %q: %v", config.Name, err)
+		}
+		m.sinks = append(m.sinks, s)
+		m.receivers[s.name] = s
+	}
+	if route != nil {
+		var err error
+		if m.route, err = newRoute(*route, names); err != nil {
+			return nil, fmt.Errorf("invalid route: %v", err)
+		}
+	}
+	return m, nil
+}
+
+// NeedsWorkloads reports whether one of the sinks needs the Workload of
+// records, which are resolved once SetResolver is called.
+func (m *Manager) NeedsWorkloads() bool {
+	for _, s := range m.sinks {
+		if ws, ok := s.sink.(WorkloadSink); ok && ws.NeedsWorkload() {
+			return true
+		}
+	}
+	return false
+}
+
+// SetResolver sets the resolver of the Workload of records. It must be called
+// before any record is sent.
+func (m *Manager) SetResolver(resolver *workload.Resolver) {
+	m.resolver = resolver
+}
+
+// SetClassifier replaces the built-in classifier of the Severity of records.
+// It must be called before any record is sent.
+func (m *Manager) SetClassifier(c *severity.Classifier) {
+	m.classifier = c
+}
+
+// Start starts the sinks and the delivery of their buffered or queued
+// records. Their background work stops when stopCh is closed.
+func (m *Manager) Start(stopCh <-chan struct{}) error {
+	for _, s := range m.sinks {
+		if err := s.sink.Start(stopCh); err != nil {
+			return fmt.Errorf("failed to start sink %q: %v", s.name, err)
+		}
+		if s.buffer != nil {
+			go s.buffer.run(stopCh, s.sendSync)
+		} else {
+			go s.run()
+		}
+		klog.Infof("started sink %s", s.name)
+	}
+	if m.route != nil {
+		go wait.Until(func() {
+			m.locker.RLock()
+			defer m.locker.RUnlock()
+			if !m.closed {
+				m.flushGroups(false)
+			}
+		}, time.Second, stopCh)
+	}
+	go wait.Until(func() {
+		m.locker.RLock()
+		defer m.locker.RUnlock()
+		if !m.closed {
+			m.sendSummaries(false)
+		}
+	}, time.Second, stopCh)
+	return nil
+}
+
+// Run waits until stopCh is closed, sends the queued records and closes the
+// sinks. Records sent afterwards are dropped, while buffered records are kept
+// for the next run.
+func (m *Manager) Run(stopCh <-chan struct{}) {
+	<-stopCh
+	m.locker.Lock()
+	defer m.locker.Unlock()
+	m.closed = true
+	if m.route != nil {
+		m.flushGroups(true)
+	}
+	m.sendSummaries(true)
+	for _, s := range m.sinks {
+		if s.queue != nil {
+			s.queue.close()
+		}
+	}
+	for _, s := range m.sinks {
+		if s.queue != nil {
+			<-s.done
+		}
+		if s.buffer != nil {
+			if err := s.buffer.close(); err != nil {
+				runtime.HandleError(fmt.Errorf("failed to close buffer of sink %s: %v", s.name, err))
+			}
+		}
+		if err := s.sink.Close(); err != nil {
+			runtime.HandleError(fmt.Errorf("failed to close sink %s: %v", s.name, err))
+		}
+	}
+	klog.Info("closed sinks")
+}
+
+// Send routes the record to the receivers of the routes it matches, or to
+// every sink if there is no route.
+func (m *Manager) Send(record *Record) {
+	m.locker.RLock()
+	defer m.locker.RUnlock()
+	if m.closed {
+		return
+	}
+	if m.resolver != nil && record.Workload == nil {
+		ref := m.resolver.Resolve(record.Event.InvolvedObject)
+		record.Workload = &ref
+	}
+	if record.Severity == "" {
+		record.Severity = m.classifier.Classify(record.Event)
+	}
+	if m.route == nil {
+		for _, s := range m.sinks {
+			m.dispatch(s, record)
+		}
+		return
+	}
+	now := m.now()
+	for _, r := range m.route.match(record) {
+		if r.groups != nil {
+			r.groups.add(r.groupKey(record), record, now)
+			continue
+		}
+		m.dispatch(m.receivers[r.receiver], record)
+	}
+}
+
+// flushGroups sends the records of the groups that are due, or of every group
+// if force is set.
+func (m *Manager) flushGroups(force bool) {
+	now := m.now()
+	m.route.walk(func(r *route) {
+		if r.groups == nil {
+			return
+		}
+		for _, records := range r.groups.due(now, force) {
+			m.dispatchGroup(m.receivers[r.receiver], records)
+		}
+	})
+}
+
+// sendSummaries sends the throttled sinks the summaries of the keys that
+// stopped suppressing records, or of every key if force is set.
+func (m *Manager) sendSummaries(force bool) {
+	for _, s := range m.sinks {
+		if s.throttle == nil {
+			continue
+		}
+		for _, record := range s.throttle.summaries(force) {
+			m.enqueue(s, record)
+		}
+	}
+}
+
+// dispatch queues or buffers the record for the sink if its filters accept
+// the event, unless the throttle of the sink suppresses it. It only blocks on
+// the queues with the block policy.
+func (m *Manager) dispatch(s *namedSink, record *Record) {
+	if !s.accepts(record.Event) {
+		return
+	}
+	if record = s.allow(record); record != nil {
+		m.enqueue(s, record)
+	}
+}
+
+// dispatchGroup queues or buffers the records of a group for the sink, as a
+// single record if the sink is a GroupSink, leaving out the records its
+// throttle suppresses. Sinks sent records by routes have no filters.
+func (m *Manager) dispatchGroup(s *namedSink, records []*Record) {
+	if _, ok := s.sink.(GroupSink); !ok {
+		for _, record := range records {
+			m.dispatch(s, record)
+		}
+		return
+	}
+	var group []*Record
+	for _, record := range records {
+		if record = s.allow(record); record != nil {
+			group = append(group, record)
+		}
+	}
+	if len(group) == 0 {
+		return
+	}
+	last := group[len(group)-1]
+	m.enqueue(s, &Record{Kind: last.Kind, Event: last.Event, Workload: last.Workload, Severity: last.Severity, Group: group})
+}
+
+// allow returns the record to send to the sink, carrying the number of records
+// its throttle suppressed before, or nil if the throttle suppresses it.
+func (s *namedSink) allow(record *Record) *Record {
+	if s.throttle == nil {
+		return record
+	}
+	suppressed, ok := s.throttle.allow(record)
+	if !ok {
+		sinkThrottled.WithLabelValues(s.name).Inc()
+		return nil
+	}
+	if suppressed > 0 {
+		copied := *record
+		copied.Suppressed = suppressed
+		record = &copied
+	}
+	return record
+}
+
+// enqueue queues or buffers the record for the sink.
+func (m *Manager) enqueue(s *namedSink, record *Record) {
+	if s.queue != nil {
+		if dropped := s.queue.push(record); dropped > 0 {
+			sinkDropped.WithLabelValues(s.name).Add(float64(dropped))
+		}
+		sinkQueueRecords.WithLabelValues(s.name).Set(float64(s.queue.len()))
+		return
+	}
+	if err := s.buffer.add(record); err != nil {
+		sinkRecords.WithLabelValues(s.name, resultError).Inc()
+		runtime.HandleError(fmt.Errorf("failed to buffer event %s/%s for sink %s: %v",
+			record.Event.Namespace, record.Event.Name, s.name, err))
+	}
+}
+
+// Notify implements notify.Notifier, forwarding synthesized events to the
+// sinks as added records.
+func (m *Manager) Notify(event *v1.Event) {
+	m.Send(&Record{Kind: Added, Event: event, Occurrences: eventutil.Count(event)})
+}
@@ -0,0 +1,615 @@
+package sinks
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/event_exporter/pkg/sinks/httpclient"
+)
+
+type fakeSink struct {
+	locker  sync.Mutex
+	records []*Record
+	fail    bool
+	reject  bool
+	calls   int
+	started bool
+	closed  chan struct{}
+}
+
+func (f *fakeSink) Start(_ <-chan struct{}) error {
+	f.started = true
+	return nil
+}
+
+func (f *fakeSink) Send(record *Record) error {
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	f.calls++
+	if f.reject {
+		return httpclient.Permanent(errors.New("rejected"))
+	}
+	if f.fail {
+		return errors.New("unavailable")
+	}
+	f.records = append(f.records, record)
+	return nil
+}
+
+func (f *fakeSink) SendSync(record *Record) error {
+	return f.Send(record)
+}
+
+func (f *fakeSink) SendGroup(group *Record) error {
+	return f.Send(group)
+}
+
+func (f *fakeSink) Close() error {
+	close(f.closed)
+	return nil
+}
+
+// asyncSink accepts records without delivering them, and can't be buffered.
+type asyncSink struct{}
+
+func (asyncSink) Start(_ <-chan struct{}) error { return nil }
+func (asyncSink) Send(_ *Record) error          { return nil }
+func (asyncSink) Close() error                  { return nil }
+
+var fakeSinks = make(map[string]*fakeSink)
+
+func init() {
+	Register("fake", func(name string, config json.RawMessage) (Sink, error) {
+		var c struct {
+			Fail   bool `json:"fail"`
+			Reject bool `json:"reject"`
+		}
+		if err := DecodeConfig(config, &c); err != nil {
+			return nil, err
+		}
+		sink := &fakeSink{fail: c.Fail, reject: c.Reject, closed: make(chan struct{})}
+		fakeSinks[name] = sink
+		return sink, nil
+	})
+	Register("async", func(_ string, _ json.RawMessage) (Sink, error) {
+		return asyncSink{}, nil
+	})
+}
+
+func TestManager(t *testing.T) {
+	m, err := NewManager([]Config{
+		{Name: "all", Type: "fake"},
+		{Name: "warnings", Type: "fake", Filter: FilterConfig{Types: []string{"Warning"}}},
+		{Name: "broken", Type: "fake", Config: json.RawMessage(`{"fail": true}`)},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.NeedsWorkloads() {
+		t.Error("fake sinks don't need workloads")
+	}
+	stopCh := make(chan struct{})
+	if err := m.Start(stopCh); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"all", "warnings", "broken"} {
+		if !fakeSinks[name].started {
+			t.Errorf("sink %s was not started", name)
+		}
+	}
+
+	warning := &v1.Event{ObjectMeta: meta_v1.ObjectMeta{Name: "a"}, Type: v1.EventTypeWarning}
+	normal := &v1.Event{ObjectMeta: meta_v1.ObjectMeta{Name: "b"}, Type: v1.EventTypeNormal}
+	added := &Record{Kind: Added, Event: warning}
+	m.Send(added)
+	m.Send(&Record{Kind: Updated, Event: normal})
+	m.Notify(warning)
+	if added.Severity != "warning" {
+		t.Errorf("got severity %q, want warning", added.Severity)
+	}
+
+	// Queued records are sent before the sinks are closed.
+	close(stopCh)
+	m.Run(stopCh)
+	for _, name := range []string{"all", "warnings", "broken"} {
+		<-fakeSinks[name].closed
+	}
+	if got := fakeSinks["all"].received(); got != 3 {
+		t.Errorf("sink all got %d records, want 3", got)
+	}
+	if got := fakeSinks["warnings"].received(); got != 2 {
+		t.Errorf("sink warnings got %d records, want 2", got)
+	}
+	m.Send(&Record{Kind: Added, Event: warning})
+	if got := fakeSinks["all"].received(); got != 3 {
+		t.Errorf("sink all got a record after it was closed")
+	}
+}
+
+func TestQueue(t *testing.T) {
+	records := make([]*Record, 10)
+	for i := range records {
+		records[i] = &Record{Event: &v1.Event{ObjectMeta: meta_v1.ObjectMeta{Name: string(rune('a' + i))}}}
+	}
+	tests := []struct {
+		policy  string
+		want    string
+		dropped int
+	}{
+		{policy: policyDropNewest, want: "abcd", dropped: 6},
+		{policy: policyDropOldest, want: "ghij", dropped: 6},
+		// The queue is half full after b, then one of every 3 records is
+		// queued: e and h.
+		{policy: policySample, want: "abeh", dropped: 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			q, err := newQueue(QueueConfig{Size: 4, Policy: tt.policy, SampleRate: 3})
+			if err != nil {
+				t.Fatal(err)
+			}
+			dropped := 0
+			for _, record := range records {
+				dropped += q.push(record)
+			}
+			q.close()
+			got := ""
+			for record, ok := q.pop(); ok; record, ok = q.pop() {
+				got += record.Event.Name
+			}
+			if got != tt.want || dropped != tt.dropped {
+				t.Errorf("got %q and %d dropped records, want %q and %d", got, dropped, tt.want, tt.dropped)
+			}
+		})
+	}
+}
+
+func TestQueueBlock(t *testing.T) {
+	q, err := newQueue(QueueConfig{Size: 1, Policy: policyBlock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := &Record{Kind: Added}, &Record{Kind: Updated}
+	q.push(first)
+	pushed := make(chan int)
+	go func() {
+		pushed <- q.push(second)
+	}()
+	select {
+	case <-pushed:
+		t.Fatal("push to a full queue didn't block")
+	case <-time.After(10 * time.Millisecond):
+	}
+	if record, _ := q.pop(); record != first {
+		t.Errorf("got %+v, want the first record", record)
+	}
+	if dropped := <-pushed; dropped != 0 {
+		t.Errorf("dropped %d records", dropped)
+	}
+	if record, _ := q.pop(); record != second {
+		t.Errorf("got %+v, want the second record", record)
+	}
+}
+
+func (f *fakeSink) setFail(fail bool) {
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	f.fail = fail
+}
+
+func (f *fakeSink) setReject(reject bool) {
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	f.reject = reject
+}
+
+func (f *fakeSink) sendCalls() int {
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	return f.calls
+}
+
+func (f *fakeSink) received() int {
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	return len(f.records)
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	for i := 0; i < 500; i++ {
+		if condition() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timed out")
+}
+
+func TestManagerBuffer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "buffer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	buffer := &BufferConfig{Path: dir, MaxRetries: 2, InitialBackoff: meta_v1.Duration{Duration: time.Millisecond}}
+	event := &v1.Event{ObjectMeta: meta_v1.ObjectMeta{Name: "a"}, Reason: "BackOff"}
+
+	// Rejected records are written to the dead-letter file once their
+	// retries are exhausted, while records failing with retryable errors are
+	// delivered once the sink recovers.
+	m, err := NewManager([]Config{{Name: "buffered", Type: "fake", Config: json.RawMessage(`{"reject": true}`), Buffer: buffer}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := fakeSinks["buffered"]
+	stopCh := make(chan struct{})
+	if err := m.Start(stopCh); err != nil {
+		t.Fatal(err)
+	}
+	m.Send(&Record{Kind: Added, Event: event})
+	waitFor(t, func() bool {
+		data, _ := ioutil.ReadFile(filepath.Join(dir, deadLetterFile))
+		return len(data) > 0
+	})
+	if got := sink.sendCalls(); got != 3 {
+		t.Errorf("rejected record was sent %d times, want 3", got)
+	}
+	sink.setReject(false)
+	sink.setFail(true)
+	m.Send(&Record{Kind: Updated, Event: event})
+	waitFor(t, func() bool { return sink.sendCalls() >= 8 })
+	sink.setFail(false)
+	waitFor(t, func() bool { return sink.received() == 1 })
+	if got := sink.records[0]; got.Kind != Updated || got.Event.Reason != "BackOff" {
+		t.Errorf("got record %+v", got)
+	}
+
+	file, err := os.Open(filepath.Join(dir, deadLetterFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner := bufio.NewScanner(file)
+	var letters []deadLetter
+	for scanner.Scan() {
+		var letter deadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			t.Fatal(err)
+		}
+		letters = append(letters, letter)
+	}
+	file.Close()
+	if len(letters) != 1 || letters[0].Error != "rejected" || letters[0].Record.Kind != Added {
+		t.Errorf("got dead letters %+v", letters)
+	}
+
+	// Records left in the buffer are delivered after a restart.
+	close(stopCh)
+	m.Send(&Record{Kind: Deleted, Event: event})
+	m.Run(stopCh)
+	if got := sink.received(); got != 1 {
+		t.Fatalf("sink got %d records after it was stopped, want 1", got)
+	}
+
+	m, err = NewManager([]Config{{Name: "buffered", Type: "fake", Buffer: buffer}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopCh = make(chan struct{})
+	if err := m.Start(stopCh); err != nil {
+		t.Fatal(err)
+	}
+	sink = fakeSinks["buffered"]
+	waitFor(t, func() bool { return sink.received() == 1 })
+	if got := sink.records[0]; got.Kind != Deleted {
+		t.Errorf("got record %+v after restart, want the deleted record", got)
+	}
+	close(stopCh)
+	m.Run(stopCh)
+}
+
+func TestThrottle(t *testing.T) {
+	pod := func(name, reason string) *Record {
+		return &Record{Kind: Updated, Event: &v1.Event{
+			InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: name},
+			Reason:         reason,
+		}}
+	}
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	type step struct {
+		after      time.Duration
+		record     *Record
+		allowed    bool
+		suppressed int32
+	}
+	tests := []struct {
+		config ThrottleConfig
+		steps  []step
+	}{
+		{
+			config: ThrottleConfig{Interval: meta_v1.Duration{Duration: 10 * time.Minute}},
+			steps: []step{
+				{record: pod("web", "BackOff"), allowed: true},
+				{after: time.Minute, record: pod("web", "Unhealthy")},
+				{after: time.Minute, record: pod("web", "BackOff")},
+				{record: pod("api", "BackOff"), allowed: true},
+				{record: &Record{Kind: Deleted, Event: pod("web", "BackOff").Event}, allowed: true},
+				{after: 8 * time.Minute, record: pod("web", "BackOff"), allowed: true, suppressed: 2},
+				{after: time.Minute, record: pod("web", "BackOff")},
+				// Keys without records for an interval keep their suppressed count.
+				{after: 30 * time.Minute, record: pod("web", "BackOff"), allowed: true, suppressed: 1},
+				{after: 30 * time.Minute, record: pod("api", "BackOff"), allowed: true},
+			},
+		},
+		{
+			config: ThrottleConfig{Key: "reason", Interval: meta_v1.Duration{Duration: time.Minute}, Limit: 2},
+			steps: []step{
+				{record: pod("web", "BackOff"), allowed: true},
+				{record: pod("api", "BackOff"), allowed: true},
+				{record: pod("db", "BackOff")},
+				{record: pod("db", "Unhealthy"), allowed: true},
+				{after: time.Minute, record: pod("web", "BackOff"), allowed: true, suppressed: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.config.Key, func(t *testing.T) {
+			throttle, err := newThrottle(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			clock := now
+			throttle.now = func() time.Time { return clock }
+			for i, step := range tt.steps {
+				clock = clock.Add(step.after)
+				suppressed, allowed := throttle.allow(step.record)
+				if allowed != step.allowed || suppressed != step.suppressed {
+					t.Errorf("step %d: got %v with %d suppressed, want %v with %d", i, allowed, suppressed, step.allowed, step.suppressed)
+				}
+			}
+		})
+	}
+}
+
+func TestThrottleSummaries(t *testing.T) {
+	pod := func(name, reason string) *Record {
+		return &Record{Kind: Updated, Event: &v1.Event{
+			InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: name},
+			Reason:         reason,
+		}}
+	}
+	throttle, err := newThrottle(ThrottleConfig{Interval: meta_v1.Duration{Duration: 10 * time.Minute}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	throttle.now = func() time.Time { return clock }
+	for _, record := range []*Record{pod("web", "BackOff"), pod("web", "Unhealthy"), pod("web", "BackOff"), pod("api", "BackOff"), pod("db", "BackOff"), pod("db", "BackOff")} {
+		throttle.allow(record)
+	}
+	clock = clock.Add(15 * time.Minute)
+	if got := throttle.summaries(false); len(got) != 0 {
+		t.Errorf("got %d summaries within two intervals, want none", len(got))
+	}
+	// The db key flaps again and carries its count to the allowed record.
+	throttle.allow(pod("db", "BackOff"))
+	clock = clock.Add(5 * time.Minute)
+	got := throttle.summaries(false)
+	if len(got) != 1 {
+		t.Fatalf("got %d summaries, want 1", len(got))
+	}
+	if got[0].Event.InvolvedObject.Name != "web" || got[0].Event.Reason != "BackOff" || got[0].Suppressed != 2 {
+		t.Errorf("got summary %+v of %+v, want the last suppressed record of web with 2 suppressed", got[0], got[0].Event)
+	}
+	if got := throttle.summaries(true); len(got) != 0 {
+		t.Errorf("got %d summaries after they were sent, want none", len(got))
+	}
+	if suppressed, allowed := throttle.allow(pod("web", "BackOff")); !allowed || suppressed != 0 {
+		t.Errorf("got %v with %d suppressed after the summary, want allowed with none", allowed, suppressed)
+	}
+	throttle.allow(pod("web", "BackOff"))
+	if got := throttle.summaries(true); len(got) != 1 || got[0].Suppressed != 1 {
+		t.Errorf("got summaries %+v when forced, want 1 with 1 suppressed", got)
+	}
+}
+
+func newEvent(namespace, name, eventType, reason string) *v1.Event {
+	return &v1.Event{
+		ObjectMeta:     meta_v1.ObjectMeta{Namespace: namespace, Name: name + ".1", Labels: map[string]string{"team": "search"}},
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: namespace, Name: name},
+		Type:           eventType,
+		Reason:         reason,
+	}
+}
+
+func TestRoute(t *testing.T) {
+	receivers := map[string]bool{"elasticsearch": true, "pager": true, "platform": true, "team-a": true, "search": true}
+	r, err := newRoute(RouteConfig{
+		Receiver: "elasticsearch",
+		Routes: []RouteConfig{
+			{Receiver: "elasticsearch", Continue: true},
+			{Receiver: "pager", Matchers: []string{"severity=critical"}},
+			{Receiver: "platform", Matchers: []string{`namespace="kube-system"`, "type = Warning"}},
+			{Receiver: "team-a", Matchers: []string{`namespace=~"team-a-.*"`}, Continue: true, Routes: []RouteConfig{
+				{Receiver: "search", Matchers: []string{`labels.team="search"`, `reason!~"Pull.*"`}},
+			}},
+			{Receiver: "platform", Matchers: []string{`namespace=~"team-.*"`}},
+		},
+	}, receivers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		event    *v1.Event
+		severity string
+		want     []string
+	}{
+		{event: newEvent("kube-system", "dns", v1.EventTypeWarning, "BackOff"), want: []string{"elasticsearch", "platform"}},
+		{event: newEvent("kube-system", "dns", v1.EventTypeNormal, "Pulled"), want: []string{"elasticsearch"}},
+		{event: newEvent("team-a-web", "web", v1.EventTypeWarning, "BackOff"), want: []string{"elasticsearch", "search", "platform"}},
+		{event: newEvent("team-a-web", "web", v1.EventTypeNormal, "Pulled"), want: []string{"elasticsearch", "team-a", "platform"}},
+		{event: newEvent("default", "api", v1.EventTypeWarning, "BackOff"), want: []string{"elasticsearch"}},
+		{event: newEvent("kube-system", "node", v1.EventTypeWarning, "NodeNotReady"), severity: "critical", want: []string{"elasticsearch", "pager"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, matched := range r.match(&Record{Kind: Added, Event: tt.event, Severity: tt.severity}) {
+			got = append(got, matched.receiver)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %s/%s routed to %v, want %v", tt.event.Reason, tt.event.Namespace, tt.event.Name, got, tt.want)
+		}
+	}
+}
+
+func TestGroups(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	g := newGroups(30*time.Second, 5*time.Minute, time.Hour)
+	names := func(due [][]*Record) string {
+		var result []string
+		for _, records := range due {
+			for _, record := range records {
+				result = append(result, string(record.Kind)+" "+record.Event.Name)
+			}
+		}
+		return strings.Join(result, ", ")
+	}
+	web, api := newEvent("default", "web", v1.EventTypeWarning, "BackOff"), newEvent("default", "api", v1.EventTypeWarning, "BackOff")
+	steps := []struct {
+		after time.Duration
+		add   []*Record
+		force bool
+		want  string
+	}{
+		{add: []*Record{{Kind: Added, Event: web}, {Kind: Added, Event: api}, {Kind: Updated, Event: web}}},
+		// The first records are sent after the group wait, keeping the
+		// latest record of every event.
+		{after: 29 * time.Second},
+		{after: time.Second, want: "Updated web.1, Added api.1"},
+		{after: time.Minute, add: []*Record{{Kind: Updated, Event: api}}},
+		// Records added afterwards wait for the group interval.
+		{after: 3 * time.Minute},
+		{after: time.Minute, want: "Updated api.1"},
+		{after: time.Minute, add: []*Record{{Kind: Deleted, Event: api}}, force: true, want: "Deleted api.1"},
+		// The events that weren't deleted are repeated.
+		{after: 59 * time.Minute},
+		{after: time.Minute, want: "Updated web.1"},
+		{add: []*Record{{Kind: Deleted, Event: web}}},
+		{after: 5 * time.Minute, want: "Deleted web.1"},
+	}
+	for i, step := range steps {
+		now = now.Add(step.after)
+		for _, record := range step.add {
+			g.add("default", record, now)
+		}
+		if got := names(g.due(now, step.force)); got != step.want {
+			t.Errorf("step %d: got %q, want %q", i, got, step.want)
+		}
+	}
+	if len(g.groups) != 0 {
+		t.Errorf("group without events was not removed")
+	}
+}
+
+func TestManagerRoute(t *testing.T) {
+	m, err := NewManager([]Config{
+		{Name: "archive", Type: "fake"},
+		{Name: "team", Type: "fake"},
+	}, &RouteConfig{
+		Receiver: "archive",
+		Routes: []RouteConfig{
+			{Receiver: "team", Matchers: []string{"type=Warning"}, GroupBy: []string{"namespace"}, GroupWait: meta_v1.Duration{Duration: time.Minute}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var clockLocker sync.Mutex
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time {
+		clockLocker.Lock()
+		defer clockLocker.Unlock()
+		return now
+	}
+	stopCh := make(chan struct{})
+	if err := m.Start(stopCh); err != nil {
+		t.Fatal(err)
+	}
+	m.Send(&Record{Kind: Added, Event: newEvent("default", "web", v1.EventTypeWarning, "BackOff")})
+	m.Send(&Record{Kind: Added, Event: newEvent("default", "api", v1.EventTypeWarning, "BackOff")})
+	m.Send(&Record{Kind: Added, Event: newEvent("default", "web", v1.EventTypeNormal, "Pulled")})
+	m.Send(&Record{Kind: Added, Event: newEvent("kube-system", "dns", v1.EventTypeWarning, "BackOff")})
+	clockLocker.Lock()
+	now = now.Add(time.Minute)
+	clockLocker.Unlock()
+	// Every group is sent as a single record.
+	waitFor(t, func() bool { return fakeSinks["team"].received() == 2 })
+	sizes := make(map[string]int)
+	fakeSinks["team"].locker.Lock()
+	for _, record := range fakeSinks["team"].records {
+		sizes[record.Event.Namespace] = len(record.Group)
+	}
+	fakeSinks["team"].locker.Unlock()
+	if want := map[string]int{"default": 2, "kube-system": 1}; !reflect.DeepEqual(sizes, want) {
+		t.Errorf("got groups of sizes %v, want %v", sizes, want)
+	}
+
+	// Grouped records are sent when the manager stops.
+	m.Send(&Record{Kind: Updated, Event: newEvent("default", "web", v1.EventTypeWarning, "BackOff")})
+	close(stopCh)
+	m.Run(stopCh)
+	if got := fakeSinks["team"].received(); got != 3 {
+		t.Errorf("sink team got %d records, want 3", got)
+	}
+	if got := fakeSinks["archive"].received(); got != 1 {
+		t.Errorf("sink archive got %d records, want 1", got)
+	}
+}
+
+func TestNewManagerErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs []Config
+		route   *RouteConfig
+	}{
+		{name: "missing name", configs: []Config{{Type: "fake"}}},
+		{name: "duplicate name", configs: []Config{{Name: "a", Type: "fake"}, {Name: "a", Type: "fake"}}},
+		{name: "unknown type", configs: []Config{{Name: "a", Type: "unknown"}}},
+		{name: "unknown field", configs: []Config{{Name: "a", Type: "fake", Config: json.RawMessage(`{"fial": true}`)}}},
+		{name: "buffer without path", configs: []Config{{Name: "a", Type: "fake", Buffer: &BufferConfig{}}}},
+		{name: "throttle without interval", configs: []Config{{Name: "a", Type: "fake", Throttle: &ThrottleConfig{}}}},
+		{name: "unknown queue policy", configs: []Config{{Name: "a", Type: "fake", Queue: QueueConfig{Policy: "drop"}}}},
+		{name: "buffer of async sink", configs: []Config{{Name: "a", Type: "async", Buffer: &BufferConfig{Path: "/tmp"}}}},
+		{name: "queue and buffer", configs: []Config{{Name: "a", Type: "fake", Queue: QueueConfig{Size: 10}, Buffer: &BufferConfig{Path: "/tmp"}}}},
+		{name: "route without receiver", configs: []Config{{Name: "a", Type: "fake"}}, route: &RouteConfig{}},
+		{name: "unknown receiver", configs: []Config{{Name: "a", Type: "fake"}}, route: &RouteConfig{Receiver: "b"}},
+		{name: "invalid matcher", configs: []Config{{Name: "a", Type: "fake"}},
+			route: &RouteConfig{Receiver: "a", Routes: []RouteConfig{{Matchers: []string{"namespace~default"}}}}},
+		{name: "unknown matcher field", configs: []Config{{Name: "a", Type: "fake"}},
+			route: &RouteConfig{Receiver: "a", Routes: []RouteConfig{{Matchers: []string{"team=a"}}}}},
+		{name: "invalid regular expression", configs: []Config{{Name: "a", Type: "fake"}},
+			route: &RouteConfig{Receiver: "a", Routes: []RouteConfig{{Matchers: []string{`reason=~"("`}}}}},
+		{name: "unknown group by field", configs: []Config{{Name: "a", Type: "fake"}},
+			route: &RouteConfig{Receiver: "a", GroupBy: []string{"node"}}},
+		{name: "filter with route", configs: []Config{{Name: "a", Type: "fake", Filter: FilterConfig{Types: []string{"Warning"}}}},
+			route: &RouteConfig{Receiver: "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewManager(tt.configs, tt.route); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	resultSuccess = "success"
+	resultError   = "error"
+)
+
+var (
+	sinkRecords = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "event_exporter",
+		Subsystem: "sink",
+		Name:      "records_total",
+		Help:      "Total number of records sent to a sink, by result",
+	}, []string{"sink", "result"})
+	sinkThrottled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "event_exporter",
+		Subsystem: "sink",
+		Name:      "throttled_records_total",
+		Help:      "Total number of records suppressed by the throttle of a sink",
+	}, []string{"sink"})
+	sinkQueueRecords = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "event_exporter",
+		Subsystem: "sink",
+		Name:      "queue_records",
+		Help:      "Number of records in the queue of a sink",
+	}, []string{"sink"})
+	sinkDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "event_exporter",
+		Subsystem: "sink",
+		Name:      "dropped_records_total",
+		Help:      "Total number of records dropped by the queue of a sink",
+	}, []string{"sink"})
+	sinkBufferBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "event_exporter",
+		Subsystem: "sink",
+		Name:      "buffer_bytes",
+		Help:      "Disk usage of the buffer of a sink",
+	}, []string{"sink"})
+	sinkBufferRecords = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "event_exporter",
+		Subsystem: "sink",
+		Name:      "buffer_records",
+		Help:      "Number of records in the buffer of a sink",
+	}, []string{"sink"})
+	sinkBufferOldestAge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "event_exporter",
+		Subsystem: "sink",
+		Name:      "buffer_oldest_record_age_seconds",
+		Help:      "Time the oldest record of the buffer of a sink has been waiting",
+	}, []string{"sink"})
+	sinkBufferDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "event_exporter",
+		Subsystem: "sink",
+		Name:      "buffer_dropped_records_total",
+		Help:      "Total number of records dropped from the full buffer of a sink",
+	}, []string{"sink"})
+	sinkDeadLetters = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "event_exporter",
+		Subsystem: "sink",
+		Name:      "dead_letter_records_total",
+		Help:      "Total number of records written to the dead-letter file of a sink after their retries failed",
+	}, []string{"sink"})
+)
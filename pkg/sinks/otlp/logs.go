@@ -0,0 +1,163 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otlp
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/caicloud/event_exporter/pkg/eventutil"
+	"github.com/caicloud/event_exporter/pkg/internal/exportutil"
+	"github.com/caicloud/event_exporter/pkg/severity"
+	"github.com/caicloud/event_exporter/pkg/sinks"
+)
+
+// Severity numbers of log records.
+const (
+	severityInfo  = 9
+	severityWarn  = 13
+	severityError = 17
+)
+
+// severityNumbers maps the severity of records to severity numbers, which
+// are otherwise derived from the type of the event.
+var severityNumbers = map[string]uint64{
+	severity.Info:     severityInfo,
+	severity.Warning:  severityWarn,
+	severity.Critical: severityError,
+}
+
+// objectAttributes are the prefixes of the resource attributes of the kinds
+// with Kubernetes semantic conventions.
+var objectAttributes = map[string]string{
+	"Pod":         "k8s.pod",
+	"Node":        "k8s.node",
+	"Deployment":  "k8s.deployment",
+	"ReplicaSet":  "k8s.replicaset",
+	"StatefulSet": "k8s.statefulset",
+	"DaemonSet":   "k8s.daemonset",
+	"Job":         "k8s.job",
+	"CronJob":     "k8s.cronjob",
+}
+
+// resourceAttributes returns the resource attributes of the involved object of
+// the record, its workload and the node reporting the event.
+func resourceAttributes(record *sinks.Record, static map[string]string) map[string]string {
+	event := record.Event
+	object := event.InvolvedObject
+	attributes := make(map[string]string, len(static)+6)
+	for key, value := range static {
+		attributes[key] = value
+	}
+	attributes["k8s.namespace.name"] = object.Namespace
+	if object.Kind == "Namespace" {
+		attributes["k8s.namespace.name"] = object.Name
+	}
+	if event.Source.Host != "" {
+		attributes["k8s.node.name"] = event.Source.Host
+	}
+	if w := record.Workload; w != nil {
+		if prefix, ok := objectAttributes[w.Kind]; ok {
+			attributes[prefix+".name"] = w.Name
+		}
+	}
+	if prefix, ok := objectAttributes[object.Kind]; ok {
+		attributes[prefix+".name"] = object.Name
+		attributes[prefix+".uid"] = string(object.UID)
+	}
+	return attributes
+}
+
+// encodeLogs encodes the records as an ExportLogsServiceRequest, grouping
+// them by resource:
+//
+//	message ExportLogsServiceRequest { repeated ResourceLogs resource_logs = 1; }
+//	message ResourceLogs { Resource resource = 1; repeated ScopeLogs scope_logs = 2; }
+//	message ScopeLogs { InstrumentationScope scope = 1; repeated LogRecord log_records = 2; }
+func encodeLogs(records []*sinks.Record, static map[string]string, observed time.Time) []byte {
+	type resourceLogs struct {
+		resource []byte
+		logs     []byte
+	}
+	var order []string
+	resources := make(map[string]*resourceLogs)
+	for _, record := range records {
+		resource := encodeResource(resourceAttributes(record, static))
+		r, ok := resources[string(resource)]
+		if !ok {
+			r = &resourceLogs{resource: resource}
+			resources[string(resource)] = r
+			order = append(order, string(resource))
+		}
+		r.logs = exportutil.AppendMessage(r.logs, 2, encodeLogRecord(record, observed))
+	}
+
+	var request []byte
+	for _, key := range order {
+		r := resources[key]
+		scopeLogs := exportutil.AppendMessage(nil, 1, encodeScope())
+		scopeLogs = append(scopeLogs, r.logs...)
+		var rl []byte
+		rl = exportutil.AppendMessage(rl, 1, r.resource)
+		rl = exportutil.AppendMessage(rl, 2, scopeLogs)
+		request = exportutil.AppendMessage(request, 1, rl)
+	}
+	return request
+}
+
+// encodeLogRecord encodes the record as a LogRecord with the message of the
+// event as body:
+//
+//	message LogRecord {
+//	  fixed64 time_unix_nano = 1;
+//	  SeverityNumber severity_number = 2;
+//	  string severity_text = 3;
+//	  AnyValue body = 5;
+//	  repeated KeyValue attributes = 6;
+//	  fixed64 observed_time_unix_nano = 11;
+//	}
+func encodeLogRecord(record *sinks.Record, observed time.Time) []byte {
+	event := record.Event
+	var b []byte
+	b = exportutil.AppendFixed64(b, 1, uint64(eventutil.LastTime(event).UnixNano()))
+	if number, ok := severityNumbers[record.Severity]; ok {
+		b = exportutil.AppendVarint(b, 2, number)
+	} else if event.Type == v1.EventTypeWarning {
+		b = exportutil.AppendVarint(b, 2, severityWarn)
+	} else if event.Type == v1.EventTypeNormal {
+		b = exportutil.AppendVarint(b, 2, severityInfo)
+	}
+	b = exportutil.AppendString(b, 3, event.Type)
+	b = exportutil.AppendMessage(b, 5, stringValue(event.Message))
+	b = appendAttributes(b, 6, map[string]string{
+		"k8s.event.record":               string(record.Kind),
+		"k8s.event.name":                 event.Name,
+		"k8s.event.uid":                  string(event.UID),
+		"k8s.event.reason":               event.Reason,
+		"k8s.event.severity":             record.Severity,
+		"k8s.event.action":               event.Action,
+		"k8s.event.source.component":     event.Source.Component,
+		"k8s.object.kind":                event.InvolvedObject.Kind,
+		"k8s.object.name":                event.InvolvedObject.Name,
+		"k8s.object.fieldpath":           event.InvolvedObject.FieldPath,
+		"k8s.event.reporting_controller": event.ReportingController,
+	})
+	b = appendKeyValue(b, 6, "k8s.event.count", intValue(int64(eventutil.Count(event))))
+	b = exportutil.AppendFixed64(b, 11, uint64(observed.UnixNano()))
+	return b
+}
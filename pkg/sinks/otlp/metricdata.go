@@ -0,0 +1,159 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otlp
+
+import (
+	"math"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/caicloud/event_exporter/pkg/internal/exportutil"
+)
+
+// Aggregation temporality of cumulative sums and histograms.
+const temporalityCumulative = 2
+
+// encodeMetrics encodes the metric families whose name has one of the
+// prefixes as an ExportMetricsServiceRequest. Counters are monotonic
+// cumulative sums since start, gauges and untyped metrics are gauges, and
+// summaries are skipped:
+//
+//	message ExportMetricsServiceRequest { repeated ResourceMetrics resource_metrics = 1; }
+//	message ResourceMetrics { Resource resource = 1; repeated ScopeMetrics scope_metrics = 2; }
+//	message ScopeMetrics { InstrumentationScope scope = 1; repeated Metric metrics = 2; }
+//	message Metric {
+//	  string name = 1;
+//	  string description = 2;
+//	  oneof data { Gauge gauge = 5; Sum sum = 7; Histogram histogram = 9; }
+//	}
+//	message Gauge { repeated NumberDataPoint data_points = 1; }
+//	message Sum { repeated NumberDataPoint data_points = 1; AggregationTemporality aggregation_temporality = 2; bool is_monotonic = 3; }
+//	message Histogram { repeated HistogramDataPoint data_points = 1; AggregationTemporality aggregation_temporality = 2; }
+func encodeMetrics(families []*dto.MetricFamily, prefixes []string, static map[string]string, start, now time.Time) []byte {
+	scopeMetrics := exportutil.AppendMessage(nil, 1, encodeScope())
+	for _, family := range families {
+		if !exportutil.HasPrefix(family.GetName(), prefixes) {
+			continue
+		}
+		var data []byte
+		var num protowire.Number
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			for _, m := range family.Metric {
+				data = exportutil.AppendMessage(data, 1, encodeNumberDataPoint(m.Label, m.GetCounter().GetValue(), start, now))
+			}
+			data = exportutil.AppendVarint(data, 2, temporalityCumulative)
+			data = exportutil.AppendVarint(data, 3, 1)
+			num = 7
+		case dto.MetricType_GAUGE:
+			for _, m := range family.Metric {
+				data = exportutil.AppendMessage(data, 1, encodeNumberDataPoint(m.Label, m.GetGauge().GetValue(), time.Time{}, now))
+			}
+			num = 5
+		case dto.MetricType_UNTYPED:
+			for _, m := range family.Metric {
+				data = exportutil.AppendMessage(data, 1, encodeNumberDataPoint(m.Label, m.GetUntyped().GetValue(), time.Time{}, now))
+			}
+			num = 5
+		case dto.MetricType_HISTOGRAM:
+			for _, m := range family.Metric {
+				data = exportutil.AppendMessage(data, 1, encodeHistogramDataPoint(m.Label, m.GetHistogram(), start, now))
+			}
+			data = exportutil.AppendVarint(data, 2, temporalityCumulative)
+			num = 9
+		default:
+			continue
+		}
+		var metric []byte
+		metric = exportutil.AppendString(metric, 1, family.GetName())
+		metric = exportutil.AppendString(metric, 2, family.GetHelp())
+		metric = exportutil.AppendMessage(metric, num, data)
+		scopeMetrics = exportutil.AppendMessage(scopeMetrics, 2, metric)
+	}
+
+	var rm []byte
+	rm = exportutil.AppendMessage(rm, 1, encodeResource(static))
+	rm = exportutil.AppendMessage(rm, 2, scopeMetrics)
+	return exportutil.AppendMessage(nil, 1, rm)
+}
+
+func labelAttributes(b []byte, num protowire.Number, labels []*dto.LabelPair) []byte {
+	attributes := make(map[string]string, len(labels))
+	for _, label := range labels {
+		attributes[label.GetName()] = label.GetValue()
+	}
+	return appendAttributes(b, num, attributes)
+}
+
+// encodeNumberDataPoint encodes a NumberDataPoint with a double value. The
+// start time is omitted for gauges.
+//
+//	message NumberDataPoint {
+//	  fixed64 start_time_unix_nano = 2;
+//	  fixed64 time_unix_nano = 3;
+//	  double as_double = 4;
+//	  repeated KeyValue attributes = 7;
+//	}
+func encodeNumberDataPoint(labels []*dto.LabelPair, value float64, start, now time.Time) []byte {
+	var b []byte
+	if !start.IsZero() {
+		b = exportutil.AppendFixed64(b, 2, uint64(start.UnixNano()))
+	}
+	b = exportutil.AppendFixed64(b, 3, uint64(now.UnixNano()))
+	b = exportutil.AppendDouble(b, 4, value)
+	return labelAttributes(b, 7, labels)
+}
+
+// encodeHistogramDataPoint encodes a HistogramDataPoint. Prometheus buckets
+// are cumulative while OTLP bucket counts are not, and the last OTLP bucket
+// counts the observations above the highest bound.
+//
+//	message HistogramDataPoint {
+//	  fixed64 start_time_unix_nano = 2;
+//	  fixed64 time_unix_nano = 3;
+//	  fixed64 count = 4;
+//	  double sum = 5;
+//	  repeated fixed64 bucket_counts = 6;
+//	  repeated double explicit_bounds = 7;
+//	  repeated KeyValue attributes = 9;
+//	}
+func encodeHistogramDataPoint(labels []*dto.LabelPair, h *dto.Histogram, start, now time.Time) []byte {
+	var counts, bounds []byte
+	var previous uint64
+	for _, bucket := range h.Bucket {
+		if math.IsInf(bucket.GetUpperBound(), 1) {
+			continue
+		}
+		counts = protowire.AppendFixed64(counts, bucket.GetCumulativeCount()-previous)
+		bounds = protowire.AppendFixed64(bounds, math.Float64bits(bucket.GetUpperBound()))
+		previous = bucket.GetCumulativeCount()
+	}
+	counts = protowire.AppendFixed64(counts, h.GetSampleCount()-previous)
+
+	var b []byte
+	b = exportutil.AppendFixed64(b, 2, uint64(start.UnixNano()))
+	b = exportutil.AppendFixed64(b, 3, uint64(now.UnixNano()))
+	b = exportutil.AppendFixed64(b, 4, h.GetSampleCount())
+	b = exportutil.AppendDouble(b, 5, h.GetSampleSum())
+	b = exportutil.AppendMessage(b, 6, counts)
+	if len(bounds) > 0 {
+		b = exportutil.AppendMessage(b, 7, bounds)
+	}
+	return labelAttributes(b, 9, labels)
+}
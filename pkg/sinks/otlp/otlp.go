@@ -0,0 +1,371 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package otlp implements a sink exporting events as OpenTelemetry log
+// records, and optionally the event metrics, over OTLP.
+package otlp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/caicloud/event_exporter/pkg/sinks"
+	"github.com/caicloud/event_exporter/pkg/sinks/httpclient"
+)
+
+const (
+	protocolGRPC         = "grpc"
+	protocolHTTPProtobuf = "http/protobuf"
+
+	defaultBatchSize       = 512
+	defaultFlushInterval   = 5 * time.Second
+	defaultMetricsInterval = time.Minute
+	// maxPendingBatches bounds the records kept for the next flush when
+	// exports fail.
+	maxPendingBatches = 4
+)
+
+var (
+	defaultMetricPrefixes = []string{"kube_event_"}
+
+	// paths of the export requests by signal and protocol.
+	paths = map[string]map[string]string{
+		"logs": {
+			protocolGRPC:         "/opentelemetry.proto.collector.logs.v1.LogsService/Export",
+			protocolHTTPProtobuf: "/v1/logs",
+		},
+		"metrics": {
+			protocolGRPC:         "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export",
+			protocolHTTPProtobuf: "/v1/metrics",
+		},
+	}
+
+	// retryableCodes are the gRPC status codes an export may be retried on.
+	retryableCodes = map[string]bool{
+		"1":  true, // CANCELLED
+		"4":  true, // DEADLINE_EXCEEDED
+		"8":  true, // RESOURCE_EXHAUSTED
+		"10": true, // ABORTED
+		"11": true, // OUT_OF_RANGE
+		"14": true, // UNAVAILABLE
+		"15": true, // DATA_LOSS
+	}
+)
+
+func init() {
+	sinks.Register("otlp", New)
+}
+
+// Config configures an otlp sink.
+type Config struct {
+	// Endpoint is the base URL of the collector, e.g. http://collector:4317
+	// for gRPC or http://collector:4318 for HTTP. gRPC requests to http URLs
+	// are sent in cleartext.
+	Endpoint string `json:"endpoint"`
+	// Protocol is grpc or http/protobuf (default grpc).
+	Protocol string `json:"protocol,omitempty"`
+	// Compression of the requests: none or gzip (default none).
+	Compression string `json:"compression,omitempty"`
+	// ResourceAttributes are added to the resource of every log record and
+	// metric, e.g. k8s.cluster.name.
+	ResourceAttributes map[string]string `json:"resourceAttributes,omitempty"`
+	// ResolveWorkloads adds the workload owning pods to their resource
+	// attributes, e.g. k8s.deployment.name.
+	ResolveWorkloads bool `json:"resolveWorkloads,omitempty"`
+	// BatchSize is the number of log records exported by a request (default
+	// 512).
+	BatchSize int `json:"batchSize,omitempty"`
+	// FlushInterval is the longest a log record waits for its batch to fill
+	// up (default 5s).
+	FlushInterval meta_v1.Duration `json:"flushInterval,omitempty"`
+	// Metrics pushes the metrics of the exporter if set.
+	Metrics *MetricsConfig `json:"metrics,omitempty"`
+	httpclient.Config
+}
+
+// MetricsConfig configures the export of metrics.
+type MetricsConfig struct {
+	// Interval between exports (default 1m).
+	Interval meta_v1.Duration `json:"interval,omitempty"`
+	// Prefixes of the names of the exported metrics (default kube_event_).
+	// Every metric is exported if set to an empty list.
+	Prefixes []string `json:"prefixes,omitempty"`
+}
+
+type grpcStatusError struct {
+	code    string
+	message string
+}
+
+func (e *grpcStatusError) Error() string {
+	return fmt.Sprintf("grpc status %s: %s", e.code, e.message)
+}
+
+// Sink exports records as log records in batches.
+type Sink struct {
+	config   Config
+	client   *httpclient.Client
+	header   http.Header
+	gatherer prometheus.Gatherer
+	start    time.Time
+	now      func() time.Time
+	stopCh   <-chan struct{}
+
+	locker  sync.Mutex
+	records []*sinks.Record
+}
+
+// New creates an otlp sink from its raw configuration.
+func New(_ string, raw json.RawMessage) (sinks.Sink, error) {
+	var config Config
+	if err := sinks.DecodeConfig(raw, &config); err != nil {
+		return nil, err
+	}
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q", config.Endpoint)
+	}
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+	switch config.Protocol {
+	case "":
+		config.Protocol = protocolGRPC
+	case protocolGRPC, protocolHTTPProtobuf:
+	default:
+		return nil, fmt.Errorf("unknown protocol %q", config.Protocol)
+	}
+	switch config.Compression {
+	case "":
+		config.Compression = "none"
+	case "none", "gzip":
+	default:
+		return nil, fmt.Errorf("unknown compression %q", config.Compression)
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.FlushInterval.Duration <= 0 {
+		config.FlushInterval.Duration = defaultFlushInterval
+	}
+	if m := config.Metrics; m != nil {
+		if m.Interval.Duration <= 0 {
+			m.Interval.Duration = defaultMetricsInterval
+		}
+		if m.Prefixes == nil {
+			m.Prefixes = defaultMetricPrefixes
+		}
+	}
+
+	s := &Sink{
+		config:   config,
+		header:   make(http.Header),
+		gatherer: prometheus.DefaultGatherer,
+		start:    time.Now(),
+		now:      time.Now,
+	}
+	var options []httpclient.Option
+	if config.Protocol == protocolGRPC {
+		options = append(options, httpclient.HTTP2(endpoint.Scheme == "http"), httpclient.ResponseCheck(checkGRPCStatus))
+		s.header.Set("Content-Type", "application/grpc")
+		s.header.Set("TE", "trailers")
+		if config.Compression == "gzip" {
+			s.header.Set("grpc-encoding", "gzip")
+		}
+	} else {
+		s.header.Set("Content-Type", "application/x-protobuf")
+		if config.Compression == "gzip" {
+			s.header.Set("Content-Encoding", "gzip")
+		}
+	}
+	if s.client, err = httpclient.New(config.Config, options...); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// checkGRPCStatus checks the status of a gRPC response, which is sent in the
+// trailers, or in the headers of responses without body.
+func checkGRPCStatus(resp *http.Response) error {
+	code, message := resp.Trailer.Get("grpc-status"), resp.Trailer.Get("grpc-message")
+	if code == "" {
+		code, message = resp.Header.Get("grpc-status"), resp.Header.Get("grpc-message")
+	}
+	if code == "0" {
+		return nil
+	}
+	if unescaped, err := url.PathUnescape(message); err == nil {
+		message = unescaped
+	}
+	err := &grpcStatusError{code: code, message: message}
+	if retryableCodes[code] {
+		return err
+	}
+	return httpclient.Permanent(err)
+}
+
+// NeedsWorkload implements sinks.WorkloadSink.
+func (s *Sink) NeedsWorkload() bool {
+	return s.config.ResolveWorkloads
+}
+
+// Start implements sinks.Sink.
+func (s *Sink) Start(stopCh <-chan struct{}) error {
+	s.stopCh = stopCh
+	go wait.Until(func() {
+		if err := s.flush(); err != nil {
+			runtime.HandleError(err)
+		}
+	}, s.config.FlushInterval.Duration, stopCh)
+	if s.config.Metrics != nil {
+		go wait.Until(func() {
+			if err := s.pushMetrics(); err != nil {
+				runtime.HandleError(err)
+			}
+		}, s.config.Metrics.Interval.Duration, stopCh)
+	}
+	return nil
+}
+
+// Send implements sinks.Sink, flushing the records whenever another batch is
+// full.
+func (s *Sink) Send(record *sinks.Record) error {
+	s.locker.Lock()
+	s.records = append(s.records, record)
+	full := len(s.records)%s.config.BatchSize == 0
+	s.locker.Unlock()
+
+	if full {
+		return s.flush()
+	}
+	return nil
+}
+
+// SendSync implements sinks.SyncSink, exporting the record on its own.
+func (s *Sink) SendSync(record *sinks.Record) error {
+	if err := s.export("logs", encodeLogs([]*sinks.Record{record}, s.config.ResourceAttributes, s.now())); err != nil {
+		return fmt.Errorf("failed to export log record: %w", err)
+	}
+	return nil
+}
+
+// flush exports the pending records in batches. The records of a batch that
+// failed to be exported are kept for the next flush, unless the collector
+// rejected them.
+func (s *Sink) flush() error {
+	for {
+		s.locker.Lock()
+		n := len(s.records)
+		if n > s.config.BatchSize {
+			n = s.config.BatchSize
+		}
+		records := s.records[:n:n]
+		s.records = s.records[n:]
+		s.locker.Unlock()
+		if n == 0 {
+			return nil
+		}
+		err := s.export("logs", encodeLogs(records, s.config.ResourceAttributes, s.now()))
+		if err == nil {
+			continue
+		}
+		if !httpclient.Retryable(err) {
+			return fmt.Errorf("failed to export %d log records: %v", n, err)
+		}
+		if dropped := s.requeue(records); dropped > 0 {
+			return fmt.Errorf("failed to export %d log records, dropped the %d oldest pending ones: %v", n, dropped, err)
+		}
+		return fmt.Errorf("failed to export %d log records, retrying on the next flush: %v", n, err)
+	}
+}
+
+// requeue puts the records back before the pending ones, dropping the oldest
+// records beyond maxPendingBatches batches. It returns the number of dropped
+// records.
+func (s *Sink) requeue(records []*sinks.Record) int {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.records = append(records, s.records...)
+	dropped := len(s.records) - maxPendingBatches*s.config.BatchSize
+	if dropped <= 0 {
+		return 0
+	}
+	s.records = s.records[dropped:]
+	return dropped
+}
+
+// pushMetrics exports the current value of the metrics.
+func (s *Sink) pushMetrics() error {
+	families, err := s.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather metrics: %v", err)
+	}
+	request := encodeMetrics(families, s.config.Metrics.Prefixes, s.config.ResourceAttributes, s.start, s.now())
+	if err := s.export("metrics", request); err != nil {
+		return fmt.Errorf("failed to export metrics: %v", err)
+	}
+	return nil
+}
+
+func (s *Sink) export(signal string, request []byte) error {
+	compressed := s.config.Compression == "gzip"
+	if compressed {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(request); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		request = buf.Bytes()
+	}
+	body := request
+	if s.config.Protocol == protocolGRPC {
+		// gRPC messages are prefixed by their compressed flag and length.
+		body = make([]byte, 5, 5+len(request))
+		if compressed {
+			body[0] = 1
+		}
+		binary.BigEndian.PutUint32(body[1:], uint32(len(request)))
+		body = append(body, request...)
+	}
+	_, err := s.client.Do(s.stopCh, http.MethodPost, s.config.Endpoint+paths[signal][s.config.Protocol], body, s.header)
+	return err
+}
+
+// Close implements sinks.Sink, exporting the pending batch and the final value
+// of the metrics.
+func (s *Sink) Close() error {
+	err := s.flush()
+	if s.config.Metrics != nil {
+		if metricsErr := s.pushMetrics(); err == nil {
+			err = metricsErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,401 @@
+package otlp
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/encoding/protowire"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/caicloud/event_exporter/pkg/sinks"
+	"github.com/caicloud/event_exporter/pkg/workload"
+)
+
+var now = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newRecord(kind, name, host string) *sinks.Record {
+	return &sinks.Record{Kind: sinks.Added, Event: &v1.Event{
+		ObjectMeta:     meta_v1.ObjectMeta{Name: name + ".1", Namespace: "default"},
+		InvolvedObject: v1.ObjectReference{Kind: kind, Namespace: "default", Name: name, UID: types.UID("uid-" + name)},
+		Reason:         "BackOff",
+		Message:        "Back-off restarting failed container",
+		Type:           v1.EventTypeWarning,
+		Source:         v1.EventSource{Component: "kubelet", Host: host},
+		Count:          3,
+		LastTimestamp:  meta_v1.NewTime(now.Add(-time.Second)),
+	}}
+}
+
+func newSink(t *testing.T, config string) *Sink {
+	sink, err := New("test", json.RawMessage(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := sink.(*Sink)
+	s.stopCh = make(chan struct{})
+	s.now = func() time.Time { return now }
+	return s
+}
+
+// fields decodes the fields of a message, with fixed64 values decoded as
+// their 8 bytes.
+func fields(t *testing.T, message []byte) map[protowire.Number][][]byte {
+	result := make(map[protowire.Number][][]byte)
+	for len(message) > 0 {
+		num, typ, n := protowire.ConsumeTag(message)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		message = message[n:]
+		var value []byte
+		switch typ {
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(message)
+		case protowire.VarintType, protowire.Fixed64Type:
+			n = protowire.ConsumeFieldValue(num, typ, message)
+			if n >= 0 {
+				value = message[:n]
+			}
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		result[num] = append(result[num], value)
+		message = message[n:]
+	}
+	return result
+}
+
+func varint(t *testing.T, value []byte) uint64 {
+	v, n := protowire.ConsumeVarint(value)
+	if n < 0 {
+		t.Fatal(protowire.ParseError(n))
+	}
+	return v
+}
+
+func fixed64(value []byte) uint64 {
+	return binary.LittleEndian.Uint64(value)
+}
+
+// attributes decodes the KeyValues of a message, with int values formatted
+// as "int:<value>".
+func attributes(t *testing.T, kvs [][]byte) map[string]string {
+	result := make(map[string]string)
+	for _, kv := range kvs {
+		f := fields(t, kv)
+		value := fields(t, f[2][0])
+		if s, ok := value[1]; ok {
+			result[string(f[1][0])] = string(s[0])
+		} else {
+			result[string(f[1][0])] = "int:" + strconv.FormatUint(varint(t, value[3][0]), 10)
+		}
+	}
+	return result
+}
+
+func TestSinkHTTP(t *testing.T) {
+	var requests []*http.Request
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(reader)
+		requests = append(requests, r)
+		bodies = append(bodies, data)
+	}))
+	defer server.Close()
+
+	s := newSink(t, `{"endpoint": "`+server.URL+`/", "protocol": "http/protobuf", "compression": "gzip", "batchSize": 3,
+		"resourceAttributes": {"k8s.cluster.name": "prod"}}`)
+	pod := newRecord("Pod", "nginx-6d4cf56db6-x2x9r", "node-1")
+	pod.Workload = &workload.Reference{Kind: "Deployment", Namespace: "default", Name: "nginx"}
+	node := newRecord("Node", "node-1", "")
+	node.Severity = "critical"
+	for _, record := range []*sinks.Record{pod, node, pod} {
+		if err := s.Send(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	r := requests[0]
+	if r.URL.Path != "/v1/logs" || r.Header.Get("Content-Type") != "application/x-protobuf" || r.Header.Get("Content-Encoding") != "gzip" {
+		t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+	}
+
+	resourceLogs := fields(t, bodies[0])[1]
+	if len(resourceLogs) != 2 {
+		t.Fatalf("got %d resources, want 2", len(resourceLogs))
+	}
+	rl := fields(t, resourceLogs[0])
+	wantResource := map[string]string{
+		"k8s.cluster.name":    "prod",
+		"k8s.namespace.name":  "default",
+		"k8s.node.name":       "node-1",
+		"k8s.deployment.name": "nginx",
+		"k8s.pod.name":        "nginx-6d4cf56db6-x2x9r",
+		"k8s.pod.uid":         "uid-nginx-6d4cf56db6-x2x9r",
+	}
+	if got := attributes(t, fields(t, rl[1][0])[1]); !reflect.DeepEqual(got, wantResource) {
+		t.Errorf("got resource %v, want %v", got, wantResource)
+	}
+	scopeLogs := fields(t, rl[2][0])
+	if scope := fields(t, scopeLogs[1][0]); string(scope[1][0]) != scopeName {
+		t.Errorf("got scope %q, want %q", scope[1][0], scopeName)
+	}
+	if len(scopeLogs[2]) != 2 {
+		t.Fatalf("got %d log records, want 2", len(scopeLogs[2]))
+	}
+	lr := fields(t, scopeLogs[2][0])
+	if got, want := fixed64(lr[1][0]), uint64(now.Add(-time.Second).UnixNano()); got != want {
+		t.Errorf("got time %d, want %d", got, want)
+	}
+	if got := varint(t, lr[2][0]); got != severityWarn {
+		t.Errorf("got severity %d, want %d", got, severityWarn)
+	}
+	if got := string(fields(t, lr[5][0])[1][0]); got != "Back-off restarting failed container" {
+		t.Errorf("got body %q", got)
+	}
+	wantAttributes := map[string]string{
+		"k8s.event.record":           "Added",
+		"k8s.event.name":             "nginx-6d4cf56db6-x2x9r.1",
+		"k8s.event.reason":           "BackOff",
+		"k8s.event.source.component": "kubelet",
+		"k8s.object.kind":            "Pod",
+		"k8s.object.name":            "nginx-6d4cf56db6-x2x9r",
+		"k8s.event.count":            "int:3",
+	}
+	if got := attributes(t, lr[6]); !reflect.DeepEqual(got, wantAttributes) {
+		t.Errorf("got attributes %v, want %v", got, wantAttributes)
+	}
+	if got := fixed64(lr[11][0]); got != uint64(now.UnixNano()) {
+		t.Errorf("got observed time %d", got)
+	}
+
+	rl = fields(t, resourceLogs[1])
+	wantResource = map[string]string{
+		"k8s.cluster.name":   "prod",
+		"k8s.namespace.name": "default",
+		"k8s.node.name":      "node-1",
+		"k8s.node.uid":       "uid-node-1",
+	}
+	if got := attributes(t, fields(t, rl[1][0])[1]); !reflect.DeepEqual(got, wantResource) {
+		t.Errorf("got resource %v, want %v", got, wantResource)
+	}
+	lr = fields(t, fields(t, rl[2][0])[2][0])
+	if got := varint(t, lr[2][0]); got != severityError {
+		t.Errorf("got severity %d, want %d", got, severityError)
+	}
+	if got := attributes(t, lr[6])["k8s.event.severity"]; got != "critical" {
+		t.Errorf("got severity attribute %q", got)
+	}
+}
+
+// newGRPCServer serves cleartext HTTP/2 requests, answering them with the
+// next status of codes.
+func newGRPCServer(t *testing.T, codes []string, paths *[]string) (string, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		if len(data) < 5 || int(binary.BigEndian.Uint32(data[1:5])) != len(data)-5 {
+			t.Errorf("invalid grpc message %v", data)
+		}
+		*paths = append(*paths, r.URL.Path)
+		code := codes[0]
+		codes = codes[1:]
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Write([]byte{0, 0, 0, 0, 0})
+		w.Header().Set(http.TrailerPrefix+"Grpc-Status", code)
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", "status%20"+code)
+	})
+	server := &http2.Server{}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeConn(conn, &http2.ServeConnOpts{Handler: handler})
+		}
+	}()
+	return "http://" + listener.Addr().String(), func() { listener.Close() }
+}
+
+func TestSinkGRPC(t *testing.T) {
+	var paths []string
+	endpoint, stop := newGRPCServer(t, []string{"14", "0", "3"}, &paths)
+	defer stop()
+
+	s := newSink(t, `{"endpoint": "`+endpoint+`", "retry": {"initialBackoff": "1ms"}}`)
+	if err := s.Send(newRecord("Pod", "nginx", "")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.flush(); err != nil {
+		t.Fatalf("unavailable status was not retried: %v", err)
+	}
+	want := []string{
+		"/opentelemetry.proto.collector.logs.v1.LogsService/Export",
+		"/opentelemetry.proto.collector.logs.v1.LogsService/Export",
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("got requests %v, want %v", paths, want)
+	}
+
+	if err := s.Send(newRecord("Pod", "nginx", "")); err != nil {
+		t.Fatal(err)
+	}
+	err := s.flush()
+	if err == nil || !strings.Contains(err.Error(), "grpc status 3: status 3") {
+		t.Errorf("got error %v, want invalid argument status", err)
+	}
+	if len(paths) != 3 {
+		t.Errorf("invalid argument status was retried")
+	}
+}
+
+func TestSinkRequeue(t *testing.T) {
+	var exported []int
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		// Records of different pods are exported as different resources.
+		exported = append(exported, len(fields(t, data)[1]))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	s := newSink(t, `{"endpoint": "`+server.URL+`", "protocol": "http/protobuf", "batchSize": 2, "retry": {"maxRetries": 1, "initialBackoff": "1ms"}}`)
+	for i := 0; i < 9; i++ {
+		s.Send(newRecord("Pod", "nginx-"+strconv.Itoa(i), ""))
+	}
+	// Every full batch failed, and the oldest record was dropped to keep 4
+	// batches.
+	if got := len(s.records); got != 9 {
+		t.Fatalf("got %d pending records, want 9", got)
+	}
+	err := s.flush()
+	if err == nil || !strings.Contains(err.Error(), "dropped the 1 oldest") {
+		t.Errorf("got error %v, want a dropped record", err)
+	}
+	if got := s.records[0].Event.InvolvedObject.Name; got != "nginx-1" {
+		t.Errorf("got oldest pending record %s, want nginx-1", got)
+	}
+
+	status = http.StatusOK
+	exported = nil
+	if err := s.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(exported, []int{2, 2, 2, 2}) || len(s.records) != 0 {
+		t.Errorf("got batches %v and %d pending records", exported, len(s.records))
+	}
+
+	// Rejected records aren't kept.
+	status = http.StatusBadRequest
+	s.Send(newRecord("Pod", "nginx", ""))
+	if err := s.flush(); err == nil || len(s.records) != 0 {
+		t.Errorf("got error %v and %d pending records", err, len(s.records))
+	}
+}
+
+func TestEncodeMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "kube_event_count", Help: "Events."}, []string{"reason"})
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "kube_event_unique_events_total", Help: "Unique events."})
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "kube_event_delay_seconds", Help: "Delay.", Buckets: []float64{1, 10}})
+	other := prometheus.NewGauge(prometheus.GaugeOpts{Name: "process_open_fds", Help: "Open fds."})
+	registry.MustRegister(counter, gauge, histogram, other)
+	counter.WithLabelValues("BackOff").Add(2)
+	gauge.Set(5)
+	for _, v := range []float64{0.5, 2, 3, 20} {
+		histogram.Observe(v)
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := now.Add(-time.Hour)
+	request := encodeMetrics(families, defaultMetricPrefixes, map[string]string{"k8s.cluster.name": "prod"}, start, now)
+	rm := fields(t, fields(t, request)[1][0])
+	if got := attributes(t, fields(t, rm[1][0])[1]); !reflect.DeepEqual(got, map[string]string{"k8s.cluster.name": "prod"}) {
+		t.Errorf("got resource %v", got)
+	}
+	metrics := make(map[string]map[protowire.Number][][]byte)
+	for _, m := range fields(t, rm[2][0])[2] {
+		f := fields(t, m)
+		metrics[string(f[1][0])] = f
+	}
+	if len(metrics) != 3 {
+		t.Fatalf("got %d metrics, want 3", len(metrics))
+	}
+
+	sum := fields(t, metrics["kube_event_count"][7][0])
+	if varint(t, sum[2][0]) != temporalityCumulative || varint(t, sum[3][0]) != 1 {
+		t.Errorf("counter is not a monotonic cumulative sum")
+	}
+	point := fields(t, sum[1][0])
+	if math.Float64frombits(fixed64(point[4][0])) != 2 || fixed64(point[2][0]) != uint64(start.UnixNano()) {
+		t.Errorf("unexpected counter point %v", point)
+	}
+	if got := attributes(t, point[7]); !reflect.DeepEqual(got, map[string]string{"reason": "BackOff"}) {
+		t.Errorf("got counter attributes %v", got)
+	}
+
+	point = fields(t, fields(t, metrics["kube_event_unique_events_total"][5][0])[1][0])
+	if math.Float64frombits(fixed64(point[4][0])) != 5 || point[2] != nil {
+		t.Errorf("unexpected gauge point %v", point)
+	}
+
+	point = fields(t, fields(t, metrics["kube_event_delay_seconds"][9][0])[1][0])
+	if fixed64(point[4][0]) != 4 || math.Float64frombits(fixed64(point[5][0])) != 25.5 {
+		t.Errorf("unexpected histogram count or sum")
+	}
+	var counts []uint64
+	for data := point[6][0]; len(data) > 0; data = data[8:] {
+		counts = append(counts, fixed64(data))
+	}
+	if want := []uint64{1, 2, 1}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("got bucket counts %v, want %v", counts, want)
+	}
+	if got := len(point[7][0]); got != 16 {
+		t.Errorf("got %d bytes of explicit bounds, want 16", got)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	for _, config := range []string{
+		`{}`,
+		`{"endpoint": "collector:4317"}`,
+		`{"endpoint": "http://collector:4317", "protocol": "http/json"}`,
+		`{"endpoint": "http://collector:4317", "compression": "snappy"}`,
+	} {
+		if _, err := New("test", json.RawMessage(config)); err == nil {
+			t.Errorf("expected error for %s", config)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otlp
+
+import (
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/caicloud/event_exporter/pkg/internal/exportutil"
+	"github.com/caicloud/event_exporter/pkg/version"
+)
+
+// scopeName is the name of the instrumentation scope of the log records and
+// metrics.
+const scopeName = "github.com/caicloud/event_exporter"
+
+// The helpers below encode the messages of opentelemetry/proto/common/v1 and
+// resource/v1:
+//
+//	message AnyValue { oneof value { string string_value = 1; int64 int_value = 3; } }
+//	message KeyValue { string key = 1; AnyValue value = 2; }
+//	message InstrumentationScope { string name = 1; string version = 2; }
+//	message Resource { repeated KeyValue attributes = 1; }
+
+func stringValue(s string) []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func intValue(i int64) []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 3, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(i))
+}
+
+func appendKeyValue(b []byte, num protowire.Number, key string, value []byte) []byte {
+	var kv []byte
+	kv = exportutil.AppendString(kv, 1, key)
+	kv = exportutil.AppendMessage(kv, 2, value)
+	return exportutil.AppendMessage(b, num, kv)
+}
+
+// appendAttributes appends string attributes sorted by key, skipping empty
+// values.
+func appendAttributes(b []byte, num protowire.Number, attributes map[string]string) []byte {
+	keys := make([]string, 0, len(attributes))
+	for key, value := range attributes {
+		if value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		b = appendKeyValue(b, num, key, stringValue(attributes[key]))
+	}
+	return b
+}
+
+func encodeResource(attributes map[string]string) []byte {
+	return appendAttributes(nil, 1, attributes)
+}
+
+func encodeScope() []byte {
+	var b []byte
+	b = exportutil.AppendString(b, 1, scopeName)
+	b = exportutil.AppendString(b, 2, version.Version)
+	return b
+}
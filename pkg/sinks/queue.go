@@ -0,0 +1,143 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"fmt"
+	"sync"
+)
+
+const (
+	policyBlock      = "block"
+	policyDropNewest = "drop-newest"
+	policyDropOldest = "drop-oldest"
+	policySample     = "sample"
+
+	defaultQueueSize  = 1000
+	defaultSampleRate = 10
+)
+
+// QueueConfig configures the in-memory queue of the records of a sink, from
+// which they are sent by a goroutine of the sink.
+type QueueConfig struct {
+	// Size is the number of records the queue holds (default 1000).
+	Size int `json:"size,omitempty"`
+	// Policy applies when the queue is full: block waits for room, while
+	// drop-newest and drop-oldest drop the new or the oldest record. sample
+	// only queues one of every SampleRate records once the queue is half
+	// full, and drops records while it is full (default drop-oldest).
+	Policy     string `json:"policy,omitempty"`
+	SampleRate int    `json:"sampleRate,omitempty"`
+}
+
+// queue is a bounded FIFO of records.
+type queue struct {
+	policy     string
+	size       int
+	sampleRate int
+
+	locker  sync.Mutex
+	cond    *sync.Cond
+	records []*Record
+	closed  bool
+	// sampled counts the records offered while sampling.
+	sampled int
+}
+
+func newQueue(config QueueConfig) (*queue, error) {
+	if config.Size <= 0 {
+		config.Size = defaultQueueSize
+	}
+	if config.SampleRate <= 0 {
+		config.SampleRate = defaultSampleRate
+	}
+	switch config.Policy {
+	case "":
+		config.Policy = policyDropOldest
+	case policyBlock, policyDropNewest, policyDropOldest, policySample:
+	default:
+		return nil, fmt.Errorf("unknown queue policy %q", config.Policy)
+	}
+	q := &queue{policy: config.Policy, size: config.Size, sampleRate: config.SampleRate}
+	q.cond = sync.NewCond(&q.locker)
+	return q, nil
+}
+
+// push adds the record, returning the number of records dropped to apply the
+// policy of the queue. Records pushed once the queue is closed are dropped.
+func (q *queue) push(record *Record) int {
+	q.locker.Lock()
+	defer q.locker.Unlock()
+	if q.policy == policyBlock {
+		for len(q.records) >= q.size && !q.closed {
+			q.cond.Wait()
+		}
+	}
+	if q.closed {
+		return 1
+	}
+	dropped := 0
+	switch {
+	case q.policy == policySample && len(q.records) >= q.size/2:
+		q.sampled++
+		if q.sampled%q.sampleRate != 0 || len(q.records) >= q.size {
+			return 1
+		}
+	case len(q.records) < q.size:
+	case q.policy == policyDropNewest:
+		return 1
+	case q.policy == policyDropOldest:
+		q.records[0] = nil
+		q.records = q.records[1:]
+		dropped = 1
+	}
+	q.records = append(q.records, record)
+	q.cond.Broadcast()
+	return dropped
+}
+
+// pop removes the oldest record, waiting for one to be pushed. ok is false
+// once the queue is closed and empty.
+func (q *queue) pop() (record *Record, ok bool) {
+	q.locker.Lock()
+	defer q.locker.Unlock()
+	for len(q.records) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if len(q.records) == 0 {
+		return nil, false
+	}
+	record = q.records[0]
+	q.records[0] = nil
+	q.records = q.records[1:]
+	q.cond.Broadcast()
+	return record, true
+}
+
+func (q *queue) len() int {
+	q.locker.Lock()
+	defer q.locker.Unlock()
+	return len(q.records)
+}
+
+// close makes pop return the remaining records and then stop waiting.
+func (q *queue) close() {
+	q.locker.Lock()
+	defer q.locker.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
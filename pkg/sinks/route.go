@@ -0,0 +1,251 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultGroupWait     = 30 * time.Second
+	defaultGroupInterval = 5 * time.Minute
+	labelPrefix          = "labels."
+)
+
+// eventFields are the fields of events routes match on and group by, besides
+// the labels of events and the severity of records.
+var eventFields = map[string]func(event *v1.Event) string{
+	"type":                func(event *v1.Event) string { return event.Type },
+	"reason":              func(event *v1.Event) string { return event.Reason },
+	"message":             func(event *v1.Event) string { return event.Message },
+	"namespace":           func(event *v1.Event) string { return event.InvolvedObject.Namespace },
+	"kind":                func(event *v1.Event) string { return event.InvolvedObject.Kind },
+	"name":                func(event *v1.Event) string { return event.InvolvedObject.Name },
+	"source":              func(event *v1.Event) string { return event.Source.Component },
+	"reportingController": func(event *v1.Event) string { return event.ReportingController },
+}
+
+var matcherPattern = regexp.MustCompile(`^\s*([a-zA-Z][a-zA-Z0-9_./-]*)\s*(=~|!~|!=|=)\s*(.*?)\s*$`)
+
+// RouteConfig is a node of the routing tree, which sends the records it
+// matches to its receiver unless one of its child routes matches them.
+type RouteConfig struct {
+	// Receiver is the name of the sink the records are sent to. It is
+	// inherited from the parent route.
+	Receiver string `json:"receiver,omitempty"`
+	// Matchers select the records of the route, e.g. namespace=~"team-a-.*"
+	// or labels.team="search". They are ignored on the root route, which
+	// matches every record.
+	Matchers []string `json:"matchers,omitempty"`
+	// Continue keeps matching the following sibling routes once the route
+	// matched.
+	Continue bool `json:"continue,omitempty"`
+	// GroupBy batches the records with the same values of these fields,
+	// which are sent GroupWait after the first record of a group, and then
+	// every GroupInterval if new records were added. Records are sent right
+	// away when GroupBy isn't set. The settings are inherited from the parent
+	// route.
+	GroupBy       []string         `json:"groupBy,omitempty"`
+	GroupWait     meta_v1.Duration `json:"groupWait,omitempty"`
+	GroupInterval meta_v1.Duration `json:"groupInterval,omitempty"`
+	// RepeatInterval resends the records of the events of a group that were
+	// not deleted if nothing was sent for that long, if set.
+	RepeatInterval meta_v1.Duration `json:"repeatInterval,omitempty"`
+	Routes         []RouteConfig    `json:"routes,omitempty"`
+}
+
+// matcher matches a field of records against a value or regular expression.
+type matcher struct {
+	field    func(record *Record) string
+	equal    bool
+	value    string
+	re       *regexp.Regexp
+	negative bool
+}
+
+func parseMatcher(s string) (*matcher, error) {
+	parts := matcherPattern.FindStringSubmatch(s)
+	if parts == nil {
+		return nil, fmt.Errorf("invalid matcher %q", s)
+	}
+	field, err := recordField(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	value := parts[3]
+	if strings.HasPrefix(value, `"`) {
+		if value, err = strconv.Unquote(value); err != nil {
+			return nil, fmt.Errorf("invalid value in matcher %q: %v", s, err)
+		}
+	}
+	m := &matcher{field: field, value: value, negative: strings.HasPrefix(parts[2], "!")}
+	if strings.HasSuffix(parts[2], "~") {
+		if m.re, err = regexp.Compile("^(?:" + value + ")$"); err != nil {
+			return nil, fmt.Errorf("invalid regular expression in matcher %q: %v", s, err)
+		}
+	}
+	return m, nil
+}
+
+func recordField(name string) (func(record *Record) string, error) {
+	if name == "severity" {
+		return func(record *Record) string { return record.Severity }, nil
+	}
+	if strings.HasPrefix(name, labelPrefix) {
+		key := strings.TrimPrefix(name, labelPrefix)
+		return func(record *Record) string { return record.Event.Labels[key] }, nil
+	}
+	field, ok := eventFields[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown event field %q", name)
+	}
+	return func(record *Record) string { return field(record.Event) }, nil
+}
+
+func (m *matcher) matches(record *Record) bool {
+	value := m.field(record)
+	var matches bool
+	if m.re != nil {
+		matches = m.re.MatchString(value)
+	} else {
+		matches = value == m.value
+	}
+	return matches != m.negative
+}
+
+// route is a node of the routing tree.
+type route struct {
+	receiver string
+	matchers []*matcher
+	cont     bool
+	groupBy  []func(record *Record) string
+	// groups is nil if the records are sent right away.
+	groups *groups
+	routes []*route
+}
+
+// newRoute creates the routing tree from its root configuration.
+func newRoute(config RouteConfig, receivers map[string]bool) (*route, error) {
+	config.Matchers = nil
+	if config.GroupWait.Duration <= 0 {
+		config.GroupWait.Duration = defaultGroupWait
+	}
+	if config.GroupInterval.Duration <= 0 {
+		config.GroupInterval.Duration = defaultGroupInterval
+	}
+	return buildRoute(config, RouteConfig{}, receivers)
+}
+
+func buildRoute(config, parent RouteConfig, receivers map[string]bool) (*route, error) {
+	if config.Receiver == "" {
+		config.Receiver = parent.Receiver
+	}
+	if config.GroupBy == nil {
+		config.GroupBy = parent.GroupBy
+	}
+	if config.GroupWait.Duration <= 0 {
+		config.GroupWait = parent.GroupWait
+	}
+	if config.GroupInterval.Duration <= 0 {
+		config.GroupInterval = parent.GroupInterval
+	}
+	if config.RepeatInterval.Duration <= 0 {
+		config.RepeatInterval = parent.RepeatInterval
+	}
+	if config.Receiver == "" {
+		return nil, fmt.Errorf("route has no receiver")
+	}
+	if !receivers[config.Receiver] {
+		return nil, fmt.Errorf("unknown receiver %q", config.Receiver)
+	}
+
+	r := &route{receiver: config.Receiver, cont: config.Continue}
+	for _, s := range config.Matchers {
+		m, err := parseMatcher(s)
+		if err != nil {
+			return nil, err
+		}
+		r.matchers = append(r.matchers, m)
+	}
+	for _, name := range config.GroupBy {
+		field, err := recordField(name)
+		if err != nil {
+			return nil, err
+		}
+		r.groupBy = append(r.groupBy, field)
+	}
+	if len(r.groupBy) > 0 {
+		r.groups = newGroups(config.GroupWait.Duration, config.GroupInterval.Duration, config.RepeatInterval.Duration)
+	}
+	for _, child := range config.Routes {
+		c, err := buildRoute(child, config, receivers)
+		if err != nil {
+			return nil, err
+		}
+		r.routes = append(r.routes, c)
+	}
+	return r, nil
+}
+
+// match returns the routes the record is sent by: the deepest matching
+// routes, or the route itself if none of its child routes match.
+func (r *route) match(record *Record) []*route {
+	for _, m := range r.matchers {
+		if !m.matches(record) {
+			return nil
+		}
+	}
+	var matched []*route
+	for _, child := range r.routes {
+		routes := child.match(record)
+		if len(routes) == 0 {
+			continue
+		}
+		matched = append(matched, routes...)
+		if !child.cont {
+			break
+		}
+	}
+	if len(matched) == 0 {
+		return []*route{r}
+	}
+	return matched
+}
+
+// walk calls f for the route and its descendants.
+func (r *route) walk(f func(r *route)) {
+	f(r)
+	for _, child := range r.routes {
+		child.walk(f)
+	}
+}
+
+// groupKey returns the values of the group by fields of the record.
+func (r *route) groupKey(record *Record) string {
+	values := make([]string, len(r.groupBy))
+	for i, field := range r.groupBy {
+		values[i] = strconv.Quote(field(record))
+	}
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/caicloud/event_exporter/pkg/workload"
+)
+
+// Kind is the kind of change to an event a record describes.
+type Kind string
+
+const (
+	Added   Kind = "Added"
+	Updated Kind = "Updated"
+	Deleted Kind = "Deleted"
+)
+
+// Record is an event forwarded to the sinks.
+type Record struct {
+	Kind Kind `json:"kind"`
+	// Event is shared between all sinks and must not be modified.
+	Event *v1.Event `json:"event"`
+	// Workload owns the involved object of the event. It is only resolved
+	// when one of the sinks is a WorkloadSink.
+	Workload *workload.Reference `json:"workload,omitempty"`
+	// Suppressed is the number of records of the same key the throttle of
+	// the sink dropped since the previous record it was sent.
+	Suppressed int32 `json:"suppressed,omitempty"`
+	// Severity is the severity the event is classified with: info, warning
+	// or critical.
+	Severity string `json:"severity,omitempty"`
+	// Occurrences is the number of times the event happened since its
+	// previous record. Events replayed after a restart only count the
+	// occurrences since.
+	Occurrences int32 `json:"occurrences,omitempty"`
+	// Group lists the records of a group of a route, in the order they were
+	// added, when the record is the notification of the group sent to a
+	// GroupSink. Its Kind and Event are those of the last record of the
+	// group.
+	Group []*Record `json:"group,omitempty"`
+}
+
+// Sink receives the events that pass the exporter's filters.
+type Sink interface {
+	// Start is called once before any record is sent. Background work of the
+	// sink must stop when stopCh is closed.
+	Start(stopCh <-chan struct{}) error
+	// Send delivers a record. It is called from a single goroutine.
+	Send(record *Record) error
+	// Close flushes pending records and releases the sink's resources. It is
+	// called once stopCh is closed, and no record is sent afterwards.
+	Close() error
+}
+
+// WorkloadSink is implemented by sinks that need the Workload of records.
+type WorkloadSink interface {
+	Sink
+	NeedsWorkload() bool
+}
+
+// SyncSink is implemented by sinks that can deliver a record synchronously,
+// which buffering requires: a record is only removed from the buffer of the
+// sink once SendSync returned nil. Sinks sending records in batches or
+// asynchronously deliver the record on its own instead.
+type SyncSink interface {
+	Sink
+	SendSync(record *Record) error
+}
+
+// GroupSink is implemented by sinks that notify of the records grouped by a
+// route at once. They are sent a single record per group, listing the records
+// of the group, while other sinks are sent these records one by one.
+// SendGroup delivers the group synchronously, like SendSync.
+type GroupSink interface {
+	Sink
+	SendGroup(group *Record) error
+}
+
+// Factory creates a sink from the raw JSON configuration of its type.
+type Factory func(name string, config json.RawMessage) (Sink, error)
+
+var (
+	factoriesLocker sync.Mutex
+	factories       = make(map[string]Factory)
+)
+
+// Register makes a sink type available. It is meant to be called from the
+// init function of the package implementing the sink, and panics if the type
+// is registered twice.
+func Register(sinkType string, factory Factory) {
+	factoriesLocker.Lock()
+	defer factoriesLocker.Unlock()
+	if _, ok := factories[sinkType]; ok {
+		panic(fmt.Sprintf("sink type %q registered twice", sinkType))
+	}
+	factories[sinkType] = factory
+}
+
+// Types returns the registered sink types.
+func Types() []string {
+	factoriesLocker.Lock()
+	defer factoriesLocker.Unlock()
+	types := make([]string, 0, len(factories))
+	for sinkType := range factories {
+		types = append(types, sinkType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func newSink(sinkType, name string, config json.RawMessage) (Sink, error) {
+	factoriesLocker.Lock()
+	factory, ok := factories[sinkType]
+	factoriesLocker.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown sink type %q, available types: %v", sinkType, Types())
+	}
+	return factory(name, config)
+}
@@ -0,0 +1,315 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package statsd implements a sink emitting event counters as StatsD or
+// DogStatsD increments.
+package statsd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/caicloud/event_exporter/pkg/sinks"
+)
+
+const (
+	formatDogStatsD = "dogstatsd"
+	formatStatsD    = "statsd"
+
+	defaultMetric        = "kube_event.count"
+	defaultFlushInterval = 10 * time.Second
+	defaultTimeout       = 10 * time.Second
+)
+
+// defaultMaxPacketSizes are the sizes recommended by Datadog: below the MTU
+// of most networks for UDP, and the default buffer of the agent for Unix
+// sockets.
+var defaultMaxPacketSizes = map[string]int{
+	"udp":      1432,
+	"unixgram": 8192,
+}
+
+func init() {
+	sinks.Register("statsd", New)
+}
+
+// Config configures a statsd sink.
+type Config struct {
+	// Network is udp or unixgram (default udp).
+	Network string `json:"network,omitempty"`
+	// Address is the host:port of the agent, or the path of its socket.
+	Address string `json:"address"`
+	// Format is dogstatsd or statsd (default dogstatsd). StatsD counters
+	// have no tags, and are summed over all events.
+	Format string `json:"format,omitempty"`
+	// Metric is the name of the counter (default kube_event.count).
+	Metric string `json:"metric,omitempty"`
+	// Tags are added to every counter, e.g. the name of the cluster.
+	Tags map[string]string `json:"tags,omitempty"`
+	// FlushInterval is the interval the aggregated counters are sent at
+	// (default 10s).
+	FlushInterval meta_v1.Duration `json:"flushInterval,omitempty"`
+	// MaxPacketSize is the size in bytes of the largest packet sent (default
+	// 1432 for udp and 8192 for unixgram).
+	MaxPacketSize int              `json:"maxPacketSize,omitempty"`
+	Timeout       meta_v1.Duration `json:"timeout,omitempty"`
+}
+
+// Sink counts the occurrences of events by the labels of kube_event_count,
+// and sends the increments of the counters every flush interval.
+type Sink struct {
+	config Config
+	// tags is the encoded suffix of the static tags.
+	tags string
+
+	// connLocker serializes the flushes.
+	connLocker sync.Mutex
+	conn       net.Conn
+
+	locker sync.Mutex
+	// pending are the increments not sent yet by tags.
+	pending map[string]int64
+}
+
+// New creates a statsd sink from its raw configuration.
+func New(_ string, raw json.RawMessage) (sinks.Sink, error) {
+	var config Config
+	if err := sinks.DecodeConfig(raw, &config); err != nil {
+		return nil, err
+	}
+	if config.Address == "" {
+		return nil, fmt.Errorf("address is required")
+	}
+	switch config.Network {
+	case "":
+		config.Network = "udp"
+	case "udp", "unixgram":
+	default:
+		return nil, fmt.Errorf("unknown network %q", config.Network)
+	}
+	switch config.Format {
+	case "":
+		config.Format = formatDogStatsD
+	case formatDogStatsD, formatStatsD:
+	default:
+		return nil, fmt.Errorf("unknown format %q", config.Format)
+	}
+	if config.Metric == "" {
+		config.Metric = defaultMetric
+	}
+	if strings.ContainsAny(config.Metric, ":|@#\n") {
+		return nil, fmt.Errorf("invalid metric %q", config.Metric)
+	}
+	if config.FlushInterval.Duration <= 0 {
+		config.FlushInterval.Duration = defaultFlushInterval
+	}
+	if config.MaxPacketSize <= 0 {
+		config.MaxPacketSize = defaultMaxPacketSizes[config.Network]
+	}
+	if config.Timeout.Duration <= 0 {
+		config.Timeout.Duration = defaultTimeout
+	}
+
+	s := &Sink{
+		config:  config,
+		pending: make(map[string]int64),
+	}
+	if len(config.Tags) > 0 {
+		names := make([]string, 0, len(config.Tags))
+		for name := range config.Tags {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var tags []string
+		for _, name := range names {
+			tags = append(tags, tag(name, config.Tags[name]))
+		}
+		s.tags = strings.Join(tags, ",")
+	}
+	return s, nil
+}
+
+// Start implements sinks.Sink.
+func (s *Sink) Start(stopCh <-chan struct{}) error {
+	go wait.Until(func() {
+		if err := s.flush(); err != nil {
+			runtime.HandleError(err)
+		}
+	}, s.config.FlushInterval.Duration, stopCh)
+	return nil
+}
+
+// Send implements sinks.Sink, adding the occurrences of the event since its
+// previous record to its counter.
+func (s *Sink) Send(record *sinks.Record) error {
+	if record.Kind == sinks.Deleted || record.Occurrences <= 0 {
+		return nil
+	}
+	tags := s.recordTags(record)
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.pending[tags] += int64(record.Occurrences)
+	return nil
+}
+
+// recordTags returns the encoded tags of the counter of the record, the
+// labels of kube_event_count followed by the static tags.
+func (s *Sink) recordTags(record *sinks.Record) string {
+	if s.config.Format == formatStatsD {
+		return ""
+	}
+	event := record.Event
+	var tags []string
+	for _, label := range [][2]string{
+		{"name", event.Name},
+		{"involved_object_namespace", event.InvolvedObject.Namespace},
+		{"namespace", event.Namespace},
+		{"involved_object_name", event.InvolvedObject.Name},
+		{"involved_object_kind", event.InvolvedObject.Kind},
+		{"reason", event.Reason},
+		{"type", event.Type},
+		{"source", fmt.Sprintf("%s/%s", event.Source.Host, event.Source.Component)},
+		{"severity", record.Severity},
+	} {
+		if label[1] == "" {
+			continue
+		}
+		tags = append(tags, tag(label[0], label[1]))
+	}
+	if s.tags != "" {
+		tags = append(tags, s.tags)
+	}
+	return strings.Join(tags, ",")
+}
+
+// tagEscaper replaces the characters DogStatsD tags cannot contain.
+var tagEscaper = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_")
+
+func tag(name, value string) string {
+	return tagEscaper.Replace(name) + ":" + tagEscaper.Replace(value)
+}
+
+// line renders the increment of the counter with the tags.
+func (s *Sink) line(tags string, increment int64) string {
+	l := s.config.Metric + ":" + strconv.FormatInt(increment, 10) + "|c"
+	if tags != "" {
+		l += "|#" + tags
+	}
+	return l
+}
+
+// flush sends the pending increments, in as few packets as possible. The
+// increments of packets that failed to be sent are kept for the next flush.
+func (s *Sink) flush() error {
+	s.connLocker.Lock()
+	defer s.connLocker.Unlock()
+	s.locker.Lock()
+	pending := s.pending
+	s.pending = make(map[string]int64)
+	s.locker.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	counters := make([]string, 0, len(pending))
+	for tags := range pending {
+		counters = append(counters, tags)
+	}
+	sort.Strings(counters)
+	var packet bytes.Buffer
+	var sent []string
+	var err error
+	send := func() {
+		if packet.Len() == 0 {
+			return
+		}
+		if err = s.write(packet.Bytes()); err == nil {
+			for _, tags := range sent {
+				delete(pending, tags)
+			}
+		}
+		packet.Reset()
+		sent = sent[:0]
+	}
+	for _, tags := range counters {
+		l := s.line(tags, pending[tags])
+		if packet.Len() > 0 && packet.Len()+1+len(l) > s.config.MaxPacketSize {
+			send()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(l)
+		sent = append(sent, tags)
+	}
+	send()
+
+	if len(pending) > 0 {
+		s.locker.Lock()
+		for tags, increment := range pending {
+			s.pending[tags] += increment
+		}
+		s.locker.Unlock()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send %d counters: %v", len(pending), err)
+	}
+	return nil
+}
+
+// write sends a packet, connecting to the agent first if needed. The
+// connection is reestablished after write errors.
+func (s *Sink) write(packet []byte) error {
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.config.Network, s.config.Address, s.config.Timeout.Duration)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(s.config.Timeout.Duration))
+	if _, err := s.conn.Write(packet); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+// Close implements sinks.Sink, sending the pending increments.
+func (s *Sink) Close() error {
+	err := s.flush()
+	s.connLocker.Lock()
+	defer s.connLocker.Unlock()
+	if s.conn != nil {
+		if closeErr := s.conn.Close(); err == nil {
+			err = closeErr
+		}
+		s.conn = nil
+	}
+	return err
+}
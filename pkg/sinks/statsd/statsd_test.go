@@ -0,0 +1,175 @@
+package statsd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/event_exporter/pkg/sinks"
+)
+
+func newRecord(kind sinks.Kind, name, reason string, occurrences int32) *sinks.Record {
+	return &sinks.Record{Kind: kind, Severity: "warning", Occurrences: occurrences, Event: &v1.Event{
+		ObjectMeta:     meta_v1.ObjectMeta{Name: name, Namespace: "default"},
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "nginx"},
+		Reason:         reason,
+		Type:           v1.EventTypeWarning,
+		Source:         v1.EventSource{Component: "kubelet", Host: "node-1"},
+	}}
+}
+
+func newSink(t *testing.T, config string) *Sink {
+	sink, err := New("test", json.RawMessage(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sink.(*Sink)
+}
+
+// receive returns the lines of the packets received until the connection is
+// idle.
+func receive(t *testing.T, conn net.PacketConn) (packets [][]string) {
+	buf := make([]byte, 65536)
+	for {
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if e, ok := err.(net.Error); ok && e.Timeout() {
+				return packets
+			}
+			t.Fatal(err)
+		}
+		packets = append(packets, strings.Split(string(buf[:n]), "\n"))
+	}
+}
+
+func TestFlush(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	s := newSink(t, `{"address": "`+conn.LocalAddr().String()+`", "tags": {"cluster": "prod,eu"}}`)
+	for _, record := range []*sinks.Record{
+		newRecord(sinks.Added, "nginx.1", "BackOff", 3),
+		newRecord(sinks.Updated, "nginx.1", "BackOff", 2),
+		newRecord(sinks.Updated, "nginx.1", "BackOff", 0),
+		newRecord(sinks.Added, "nginx.2", "Unhealthy", 1),
+	} {
+		if err := s.Send(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.flush(); err != nil {
+		t.Fatal(err)
+	}
+	tags := "involved_object_namespace:default,namespace:default,involved_object_name:nginx,involved_object_kind:Pod,type:Warning,source:node-1/kubelet,severity:warning,cluster:prod_eu"
+	want := [][]string{{
+		"kube_event.count:5|c|#name:nginx.1," + strings.Replace(tags, "type:", "reason:BackOff,type:", 1),
+		"kube_event.count:1|c|#name:nginx.2," + strings.Replace(tags, "type:", "reason:Unhealthy,type:", 1),
+	}}
+	if got := receive(t, conn); !reflect.DeepEqual(got, want) {
+		t.Errorf("got packets\n%v\nwant\n%v", got, want)
+	}
+
+	// Deleted records count nothing.
+	for _, record := range []*sinks.Record{
+		newRecord(sinks.Updated, "nginx.1", "BackOff", 2),
+		newRecord(sinks.Deleted, "nginx.2", "Unhealthy", 1),
+		newRecord(sinks.Added, "nginx.2", "Unhealthy", 1),
+	} {
+		if err := s.Send(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want = [][]string{{
+		"kube_event.count:2|c|#name:nginx.1," + strings.Replace(tags, "type:", "reason:BackOff,type:", 1),
+		"kube_event.count:1|c|#name:nginx.2," + strings.Replace(tags, "type:", "reason:Unhealthy,type:", 1),
+	}}
+	if got := receive(t, conn); !reflect.DeepEqual(got, want) {
+		t.Errorf("got packets\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestFlushUnixgram(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dsd.socket")
+
+	s := newSink(t, `{"network": "unixgram", "address": "`+path+`", "format": "statsd", "maxPacketSize": 30}`)
+	for i, reason := range []string{"BackOff", "Unhealthy", "Failed"} {
+		if err := s.Send(newRecord(sinks.Added, "nginx."+reason, reason, int32(i+1))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The increments are kept until the agent is reachable.
+	if err := s.flush(); err == nil {
+		t.Fatal("expected error without agent")
+	}
+	conn, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := s.Send(newRecord(sinks.Added, "nginx.Evicted", "Evicted", 4)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := receive(t, conn), [][]string{{"kube_event.count:10|c"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got packets %v, want %v", got, want)
+	}
+	s.Close()
+}
+
+func TestMaxPacketSize(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	s := newSink(t, `{"address": "`+conn.LocalAddr().String()+`", "metric": "events", "maxPacketSize": 40}`)
+	s.pending = map[string]int64{"reason:BackOff": 1, "reason:Failed": 2, "reason:Unhealthy": 3}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"events:1|c|#reason:BackOff"},
+		{"events:2|c|#reason:Failed"},
+		{"events:3|c|#reason:Unhealthy"},
+	}
+	if got := receive(t, conn); !reflect.DeepEqual(got, want) {
+		t.Errorf("got packets %v, want %v", got, want)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	for _, config := range []string{
+		`{}`,
+		`{"address": "localhost:8125", "network": "tcp"}`,
+		`{"address": "localhost:8125", "format": "graphite"}`,
+		`{"address": "localhost:8125", "metric": "kube_event|count"}`,
+	} {
+		if _, err := New("test", json.RawMessage(config)); err == nil {
+			t.Errorf("expected error for config %s", config)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package syslog implements a sink forwarding events to a syslog collector as
+// RFC 5424 messages.
+package syslog
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/event_exporter/pkg/eventutil"
+	"github.com/caicloud/event_exporter/pkg/notify"
+	"github.com/caicloud/event_exporter/pkg/severity"
+	"github.com/caicloud/event_exporter/pkg/sinks"
+	"github.com/caicloud/event_exporter/pkg/sinks/httpclient"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	// defaultSDID is the ID of the structured data element carrying the
+	// event. 32473 is the enterprise number reserved for documentation by
+	// RFC 5612.
+	defaultSDID     = "k8s@32473"
+	timestampLayout = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+var (
+	facilities = map[string]int{
+		"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+		"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+		"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+		"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+	}
+	severities = map[string]int{
+		"emerg": 0, "alert": 1, "crit": 2, "err": 3, "warning": 4, "notice": 5, "info": 6, "debug": 7,
+	}
+	defaultTypeSeverities = map[string]int{
+		v1.EventTypeWarning: severities["warning"],
+		v1.EventTypeNormal:  severities["info"],
+	}
+	// levelSeverities maps the severity records are classified with.
+	levelSeverities = map[string]int{
+		severity.Critical: severities["crit"],
+		severity.Warning:  severities["warning"],
+		severity.Info:     severities["info"],
+	}
+)
+
+func init() {
+	sinks.Register("syslog", New)
+}
+
+// Config configures a syslog sink.
+type Config struct {
+	// Network is udp, tcp or tls (default udp).
+	Network string `json:"network,omitempty"`
+	Address string `json:"address"`
+	// Framing of the messages sent over tcp and tls: octet-counting or
+	// newline (default octet-counting).
+	Framing string `json:"framing,omitempty"`
+	// Facility of the messages (default local0).
+	Facility string `json:"facility,omitempty"`
+	// TypeSeverities maps event types to severities, overriding the
+	// severity records are classified with: critical to crit, warning to
+	// warning and info to info. Unclassified Warning events are warnings,
+	// Normal events info and other types notices.
+	TypeSeverities map[string]string `json:"typeSeverities,omitempty"`
+	// ReasonSeverities maps event reasons to severities, overriding the
+	// severity of their type.
+	ReasonSeverities map[string]string `json:"reasonSeverities,omitempty"`
+	// Hostname of the messages (default the hostname of the exporter).
+	Hostname string `json:"hostname,omitempty"`
+	// SDID is the ID of the structured data element carrying the event
+	// (default k8s@32473).
+	SDID    string               `json:"sdID,omitempty"`
+	TLS     httpclient.TLSConfig `json:"tls,omitempty"`
+	Timeout meta_v1.Duration     `json:"timeout,omitempty"`
+}
+
+// Sink sends records to a syslog collector. The connection is established on
+// the first record and reestablished after write errors.
+type Sink struct {
+	config    Config
+	facility  int
+	types     map[string]int
+	reasons   map[string]int
+	tlsConfig *tls.Config
+	conn      net.Conn
+}
+
+// New creates a syslog sink from its raw configuration.
+func New(_ string, raw json.RawMessage) (sinks.Sink, error) {
+	var config Config
+	if err := sinks.DecodeConfig(raw, &config); err != nil {
+		return nil, err
+	}
+	if config.Address == "" {
+		return nil, fmt.Errorf("address is required")
+	}
+	switch config.Network {
+	case "":
+		config.Network = "udp"
+	case "udp", "tcp", "tls":
+	default:
+		return nil, fmt.Errorf("unknown network %q", config.Network)
+	}
+	switch config.Framing {
+	case "":
+		config.Framing = "octet-counting"
+	case "octet-counting", "newline":
+	default:
+		return nil, fmt.Errorf("unknown framing %q", config.Framing)
+	}
+	if config.Facility == "" {
+		config.Facility = "local0"
+	}
+	facility, ok := facilities[config.Facility]
+	if !ok {
+		return nil, fmt.Errorf("unknown facility %q", config.Facility)
+	}
+	if config.Hostname == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get hostname: %v", err)
+		}
+		config.Hostname = hostname
+	}
+	if config.SDID == "" {
+		config.SDID = defaultSDID
+	}
+	if config.Timeout.Duration <= 0 {
+		config.Timeout.Duration = defaultTimeout
+	}
+	s := &Sink{config: config, facility: facility}
+	var err error
+	if s.types, err = parseSeverities(config.TypeSeverities); err != nil {
+		return nil, err
+	}
+	if s.reasons, err = parseSeverities(config.ReasonSeverities); err != nil {
+		return nil, err
+	}
+	if config.Network == "tls" {
+		if s.tlsConfig, err = httpclient.NewTLSConfig(config.TLS); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func parseSeverities(names map[string]string) (map[string]int, error) {
+	result := make(map[string]int, len(names))
+	for key, name := range names {
+		severity, ok := severities[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown severity %q of %s", name, key)
+		}
+		result[key] = severity
+	}
+	return result, nil
+}
+
+// Start implements sinks.Sink.
+func (s *Sink) Start(_ <-chan struct{}) error {
+	return nil
+}
+
+// Send implements sinks.Sink. Deleted events are not sent. A write failing on
+// an established connection is retried once on a new connection.
+func (s *Sink) Send(record *sinks.Record) error {
+	if record.Kind == sinks.Deleted {
+		return nil
+	}
+	message := s.frame(s.format(record))
+	reused := s.conn != nil
+	err := s.write(message)
+	if err != nil && reused {
+		err = s.write(message)
+	}
+	return err
+}
+
+func (s *Sink) write(message []byte) error {
+	if s.conn == nil {
+		conn, err := s.dial()
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(s.config.Timeout.Duration))
+	if _, err := s.conn.Write(message); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (s *Sink) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: s.config.Timeout.Duration}
+	if s.config.Network == "tls" {
+		return tls.DialWithDialer(dialer, "tcp", s.config.Address, s.tlsConfig)
+	}
+	return dialer.Dial(s.config.Network, s.config.Address)
+}
+
+// severity returns the severity of the record, mapped from the reason or type
+// of its event or else its classification.
+func (s *Sink) severity(record *sinks.Record) int {
+	event := record.Event
+	if severity, ok := s.reasons[event.Reason]; ok {
+		return severity
+	}
+	if severity, ok := s.types[event.Type]; ok {
+		return severity
+	}
+	if severity, ok := levelSeverities[record.Severity]; ok {
+		return severity
+	}
+	if severity, ok := defaultTypeSeverities[event.Type]; ok {
+		return severity
+	}
+	return severities["notice"]
+}
+
+// format renders the record as an RFC 5424 message.
+func (s *Sink) format(record *sinks.Record) []byte {
+	event := record.Event
+	object := event.InvolvedObject
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<%d>1 %s %s %s - %s ",
+		s.facility*8+s.severity(record),
+		eventutil.LastTime(event).Format(timestampLayout),
+		header(s.config.Hostname, 255),
+		header(notify.Component, 48),
+		header(event.Reason, 32),
+	)
+	b.WriteByte('[')
+	b.WriteString(s.config.SDID)
+	for _, param := range [][2]string{
+		{"record", string(record.Kind)},
+		{"type", event.Type},
+		{"severity", record.Severity},
+		{"reason", event.Reason},
+		{"kind", object.Kind},
+		{"namespace", object.Namespace},
+		{"name", object.Name},
+		{"uid", string(object.UID)},
+		{"count", strconv.Itoa(int(eventutil.Count(event)))},
+		{"component", event.Source.Component},
+		{"host", event.Source.Host},
+	} {
+		if param[1] == "" {
+			continue
+		}
+		fmt.Fprintf(&b, ` %s="%s"`, param[0], sdEscaper.Replace(param[1]))
+	}
+	b.WriteByte(']')
+	if event.Message != "" {
+		b.WriteByte(' ')
+		b.WriteString(event.Message)
+	}
+	return b.Bytes()
+}
+
+var sdEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`)
+
+// header returns the value of a header field: printable ASCII without spaces,
+// truncated to max characters, or the nil value if empty.
+func header(value string, max int) string {
+	value = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, value)
+	if value == "" {
+		return "-"
+	}
+	if len(value) > max {
+		value = value[:max]
+	}
+	return value
+}
+
+// frame frames the message for the stream transports, datagrams carrying a
+// single message each.
+func (s *Sink) frame(message []byte) []byte {
+	if s.config.Network == "udp" {
+		return message
+	}
+	if s.config.Framing == "newline" {
+		return append(message, '\n')
+	}
+	return append([]byte(strconv.Itoa(len(message))+" "), message...)
+}
+
+// SendSync implements sinks.SyncSink, Send writing the message right away.
+func (s *Sink) SendSync(record *sinks.Record) error {
+	return s.Send(record)
+}
+
+// Close implements sinks.Sink.
+func (s *Sink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,210 @@
+package syslog
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/event_exporter/pkg/sinks"
+)
+
+func newRecord(reason, message string) *sinks.Record {
+	return &sinks.Record{Kind: sinks.Added, Event: &v1.Event{
+		ObjectMeta:     meta_v1.ObjectMeta{Name: "nginx.1", Namespace: "default"},
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "nginx", UID: "pod-uid"},
+		Reason:         reason,
+		Message:        message,
+		Type:           v1.EventTypeWarning,
+		Count:          2,
+		Source:         v1.EventSource{Component: "kubelet", Host: "node-1"},
+		LastTimestamp:  meta_v1.NewTime(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)),
+	}}
+}
+
+func newSink(t *testing.T, config string) *Sink {
+	sink, err := New("test", json.RawMessage(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sink.(*Sink)
+}
+
+func TestFormat(t *testing.T) {
+	s := newSink(t, `{"address": "localhost:514", "hostname": "exporter", "reasonSeverities": {"OOMKilling": "err"}}`)
+	tests := []struct {
+		record *sinks.Record
+		want   string
+	}{
+		{
+			record: newRecord("BackOff", "Back-off restarting failed container"),
+			want: `<132>1 2020-06-01T12:00:00.000000Z exporter event-exporter - BackOff ` +
+				`[k8s@32473 record="Added" type="Warning" reason="BackOff" kind="Pod" namespace="default" name="nginx" uid="pod-uid" count="2" component="kubelet" host="node-1"] ` +
+				`Back-off restarting failed container`,
+		},
+		{
+			record: newRecord("OOMKilling", `Memory cgroup out of memory: Killed process 1 ("nginx") [x]`),
+			want: `<131>1 2020-06-01T12:00:00.000000Z exporter event-exporter - OOMKilling ` +
+				`[k8s@32473 record="Added" type="Warning" reason="OOMKilling" kind="Pod" namespace="default" name="nginx" uid="pod-uid" count="2" component="kubelet" host="node-1"] ` +
+				`Memory cgroup out of memory: Killed process 1 ("nginx") [x]`,
+		},
+		{
+			record: &sinks.Record{Kind: sinks.Added, Severity: "critical", Event: newRecord("NodeNotReady", "").Event},
+			want: `<130>1 2020-06-01T12:00:00.000000Z exporter event-exporter - NodeNotReady ` +
+				`[k8s@32473 record="Added" type="Warning" severity="critical" reason="NodeNotReady" kind="Pod" namespace="default" name="nginx" uid="pod-uid" count="2" component="kubelet" host="node-1"]`,
+		},
+	}
+	for _, tt := range tests {
+		if got := string(s.format(tt.record)); got != tt.want {
+			t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+		}
+	}
+
+	record := newRecord(`Some "odd" reason that is way too long for a msgid`, "")
+	record.Event.Type = "Custom"
+	record.Event.InvolvedObject.Name = `a"b\c]`
+	want := `<133>1 2020-06-01T12:00:00.000000Z exporter event-exporter - Some"odd"reasonthatiswaytoolongf ` +
+		`[k8s@32473 record="Added" type="Custom" reason="Some \"odd\" reason that is way too long for a msgid" kind="Pod" namespace="default" name="a\"b\\c\]" uid="pod-uid" count="2" component="kubelet" host="node-1"]`
+	if got := string(s.format(record)); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSinkUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	s := newSink(t, `{"address": "`+conn.LocalAddr().String()+`"}`)
+	defer s.Close()
+	record := newRecord("BackOff", "Back-off restarting failed container")
+	if err := s.Send(record); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(buf[:n]), string(s.format(record)); got != want {
+		t.Errorf("got datagram %s, want %s", got, want)
+	}
+}
+
+func TestSinkTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	s := newSink(t, `{"network": "tcp", "address": "`+listener.Addr().String()+`"}`)
+	defer s.Close()
+	record := newRecord("BackOff", "Back-off restarting failed container")
+	want := string(s.format(record))
+
+	for i := 0; i < 2; i++ {
+		if err := s.Send(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+	for i := 0; i < 2; i++ {
+		length, err := reader.ReadString(' ')
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(length))
+		message := make([]byte, n)
+		if _, err := io.ReadFull(reader, message); err != nil {
+			t.Fatal(err)
+		}
+		if string(message) != want {
+			t.Errorf("got message %s, want %s", message, want)
+		}
+	}
+}
+
+func TestSinkTLS(t *testing.T) {
+	server := httptest.NewTLSServer(nil)
+	tlsConfig := server.TLS
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	server.Close()
+
+	dir, err := ioutil.TempDir("", "syslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(caFile, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	lines := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		lines <- line
+	}()
+
+	s := newSink(t, `{"network": "tls", "framing": "newline", "address": "`+listener.Addr().String()+`", "tls": {"caFile": "`+caFile+`"}}`)
+	defer s.Close()
+	record := newRecord("BackOff", "Back-off restarting failed container")
+	if err := s.Send(record); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case line := <-lines:
+		if want := string(s.format(record)) + "\n"; line != want {
+			t.Errorf("got line %q, want %q", line, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	for _, config := range []string{
+		`{}`,
+		`{"address": "localhost:514", "network": "unix"}`,
+		`{"address": "localhost:514", "framing": "nul"}`,
+		`{"address": "localhost:514", "facility": "local8"}`,
+		`{"address": "localhost:514", "reasonSeverities": {"BackOff": "fatal"}}`,
+	} {
+		if _, err := New("test", json.RawMessage(config)); err == nil {
+			t.Errorf("expected error for config %s", config)
+		}
+	}
+}
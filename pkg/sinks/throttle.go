@@ -0,0 +1,161 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// throttleKeys group the records a throttle limits together.
+var throttleKeys = map[string]func(event *v1.Event) string{
+	"object": func(event *v1.Event) string {
+		object := event.InvolvedObject
+		return object.Kind + "/" + object.Namespace + "/" + object.Name
+	},
+	"reason": func(event *v1.Event) string {
+		return event.InvolvedObject.Namespace + "/" + event.Reason
+	},
+	"sink": func(_ *v1.Event) string {
+		return ""
+	},
+}
+
+// ThrottleConfig limits the records sent to a sink, e.g. to one per object
+// every 10 minutes. Deleted records aren't limited.
+type ThrottleConfig struct {
+	// Key groups the records limited together: the involved object, the
+	// namespace and reason of the event, or the whole sink (default object).
+	Key string `json:"key,omitempty"`
+	// Interval is the period records are limited over.
+	Interval meta_v1.Duration `json:"interval"`
+	// Limit is the number of records of a key sent per interval (default 1).
+	Limit int `json:"limit,omitempty"`
+}
+
+// window counts the records of a key since the start of its interval.
+type window struct {
+	start      time.Time
+	sent       int
+	suppressed int32
+	// last is the last suppressed record.
+	last *Record
+}
+
+// throttle drops the records of a key beyond the limit of the interval.
+type throttle struct {
+	key      func(event *v1.Event) string
+	interval time.Duration
+	limit    int
+	now      func() time.Time
+
+	locker  sync.Mutex
+	windows map[string]*window
+	swept   time.Time
+}
+
+func newThrottle(config ThrottleConfig) (*throttle, error) {
+	if config.Key == "" {
+		config.Key = "object"
+	}
+	key, ok := throttleKeys[config.Key]
+	if !ok {
+		return nil, fmt.Errorf("unknown throttle key %q", config.Key)
+	}
+	if config.Interval.Duration <= 0 {
+		return nil, fmt.Errorf("throttle interval must be positive")
+	}
+	if config.Limit <= 0 {
+		config.Limit = 1
+	}
+	return &throttle{
+		key:      key,
+		interval: config.Interval.Duration,
+		limit:    config.Limit,
+		now:      time.Now,
+		windows:  make(map[string]*window),
+	}, nil
+}
+
+// allow reports whether the record may be sent, along with the number of
+// records of its key suppressed since the previous one that was sent.
+func (t *throttle) allow(record *Record) (suppressed int32, ok bool) {
+	if record.Kind == Deleted {
+		return 0, true
+	}
+	now := t.now()
+	t.locker.Lock()
+	defer t.locker.Unlock()
+	t.sweep(now)
+	key := t.key(record.Event)
+	w, found := t.windows[key]
+	if !found || now.Sub(w.start) >= t.interval {
+		next := &window{start: now}
+		if found {
+			next.suppressed, next.last = w.suppressed, w.last
+		}
+		w = next
+		t.windows[key] = w
+	}
+	if w.sent >= t.limit {
+		w.suppressed++
+		w.last = record
+		return 0, false
+	}
+	w.sent++
+	suppressed, w.suppressed, w.last = w.suppressed, 0, nil
+	return suppressed, true
+}
+
+// sweep forgets the keys without records over the last interval that have no
+// suppressed records left to summarize.
+func (t *throttle) sweep(now time.Time) {
+	if now.Sub(t.swept) < t.interval {
+		return
+	}
+	t.swept = now
+	for key, w := range t.windows {
+		if w.suppressed == 0 && now.Sub(w.start) >= 2*t.interval {
+			delete(t.windows, key)
+		}
+	}
+}
+
+// summaries forgets the keys that suppressed records but had none over the
+// last interval, or every key that suppressed records if force is set. It
+// returns their last suppressed record, carrying the number of records they
+// suppressed, so that the count isn't lost once a key stops flapping.
+func (t *throttle) summaries(force bool) []*Record {
+	now := t.now()
+	t.locker.Lock()
+	defer t.locker.Unlock()
+	var records []*Record
+	for key, w := range t.windows {
+		if w.suppressed == 0 || !force && now.Sub(w.start) < 2*t.interval {
+			continue
+		}
+		summary := *w.last
+		summary.Suppressed = w.suppressed
+		records = append(records, &summary)
+		delete(t.windows, key)
+	}
+	return records
+}
@@ -0,0 +1,363 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package wal implements the write-ahead log buffering the records of a sink
+// on disk. Entries are appended to segment files and consumed in order, and
+// the position of the consumer survives restarts.
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	segmentSuffix = ".seg"
+	cursorFile    = "cursor"
+	// headerSize is the size of the header of an entry: the length and
+	// CRC-32 of the data, and the time it was appended in nanoseconds.
+	headerSize = 16
+)
+
+// ErrFull is returned for entries larger than the log, or that can't be
+// appended without removing the segment being written.
+var ErrFull = errors.New("write-ahead log is full")
+
+// Options bounds the disk usage of a log.
+type Options struct {
+	// SegmentSize is the size a segment is closed at.
+	SegmentSize int64
+	// MaxSize is the size of all segments. The oldest segments are removed
+	// to make room for new entries, dropping their unconsumed entries.
+	MaxSize int64
+}
+
+type segment struct {
+	index   uint64
+	size    int64
+	entries int
+}
+
+// WAL is a write-ahead log. It is safe for one producer and one consumer to
+// use it concurrently.
+type WAL struct {
+	dir     string
+	options Options
+
+	locker   sync.Mutex
+	segments []*segment
+	writer   *os.File
+	// reader is the first segment, read from offset, after consumed entries.
+	reader   *os.File
+	offset   int64
+	consumed int
+	// next is the offset after the entry returned by Peek.
+	next int64
+}
+
+// Open opens the log in dir, creating it if needed. Entries partially written
+// when the exporter stopped are discarded.
+func Open(dir string, options Options) (*WAL, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	w := &WAL{dir: dir, options: options}
+	names, err := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		index, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(name), segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		w.segments = append(w.segments, &segment{index: index})
+	}
+	sort.Slice(w.segments, func(i, j int) bool { return w.segments[i].index < w.segments[j].index })
+
+	cursorIndex, cursorOffset := w.readCursor()
+	for len(w.segments) > 0 && w.segments[0].index < cursorIndex {
+		if err := os.Remove(w.path(w.segments[0].index)); err != nil {
+			return nil, err
+		}
+		w.segments = w.segments[1:]
+	}
+	for i, s := range w.segments {
+		var from int64
+		if i == 0 && s.index == cursorIndex {
+			from = cursorOffset
+		}
+		if err := w.scan(s, from); err != nil {
+			return nil, err
+		}
+	}
+	if len(w.segments) == 0 {
+		w.segments = append(w.segments, &segment{index: cursorIndex})
+	}
+	if w.segments[0].index == cursorIndex && cursorOffset <= w.segments[0].size {
+		w.offset = cursorOffset
+	}
+	last := w.segments[len(w.segments)-1]
+	if w.writer, err = os.OpenFile(w.path(last.index), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644); err != nil {
+		return nil, err
+	}
+	if w.reader, err = os.Open(w.path(w.segments[0].index)); err != nil {
+		w.writer.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *WAL) path(index uint64) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%020d%s", index, segmentSuffix))
+}
+
+func (w *WAL) readCursor() (uint64, int64) {
+	data, err := ioutil.ReadFile(filepath.Join(w.dir, cursorFile))
+	if err != nil {
+		return 0, 0
+	}
+	var index uint64
+	var offset int64
+	if _, err := fmt.Sscanf(string(data), "%d %d", &index, &offset); err != nil {
+		return 0, 0
+	}
+	return index, offset
+}
+
+func (w *WAL) writeCursor() error {
+	path := filepath.Join(w.dir, cursorFile)
+	data := fmt.Sprintf("%d %d\n", w.segments[0].index, w.offset)
+	if err := ioutil.WriteFile(path+".tmp", []byte(data), 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// scan counts the valid entries of a segment from offset, and truncates the
+// segment after the last one.
+func (w *WAL) scan(s *segment, from int64) error {
+	file, err := os.OpenFile(w.path(s.index), os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	offset := from
+	if offset > info.Size() {
+		offset = 0
+	}
+	for {
+		_, _, n, err := readEntry(file, offset)
+		if err != nil {
+			break
+		}
+		offset += n
+		s.entries++
+	}
+	if offset < info.Size() {
+		if err := file.Truncate(offset); err != nil {
+			return err
+		}
+	}
+	s.size = offset
+	return nil
+}
+
+// readEntry reads the entry at offset, returning its data, the time it was
+// appended and its size.
+func readEntry(r io.ReaderAt, offset int64) ([]byte, time.Time, int64, error) {
+	header := make([]byte, headerSize)
+	if _, err := r.ReadAt(header, offset); err != nil {
+		return nil, time.Time{}, 0, err
+	}
+	length := binary.BigEndian.Uint32(header[0:4])
+	data := make([]byte, length)
+	if _, err := r.ReadAt(data, offset+headerSize); err != nil {
+		return nil, time.Time{}, 0, err
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, time.Time{}, 0, fmt.Errorf("corrupted entry at offset %d", offset)
+	}
+	appended := time.Unix(0, int64(binary.BigEndian.Uint64(header[8:16])))
+	return data, appended, headerSize + int64(length), nil
+}
+
+// Append appends an entry, returning the number of unconsumed entries
+// dropped to keep the log within its maximum size.
+func (w *WAL) Append(data []byte, now time.Time) (int, error) {
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	size := headerSize + int64(len(data))
+	if size > w.options.MaxSize {
+		return 0, ErrFull
+	}
+	last := w.segments[len(w.segments)-1]
+	if last.size > 0 && last.size+size > w.options.SegmentSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+		last = w.segments[len(w.segments)-1]
+	}
+	dropped := 0
+	for w.size()+size > w.options.MaxSize {
+		if len(w.segments) == 1 {
+			return dropped, ErrFull
+		}
+		n, err := w.removeFirst()
+		if err != nil {
+			return dropped, err
+		}
+		dropped += n
+	}
+
+	entry := make([]byte, headerSize, size)
+	binary.BigEndian.PutUint32(entry[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(entry[4:8], crc32.ChecksumIEEE(data))
+	binary.BigEndian.PutUint64(entry[8:16], uint64(now.UnixNano()))
+	entry = append(entry, data...)
+	n, err := w.writer.Write(entry)
+	last.size += int64(n)
+	if err != nil {
+		return dropped, err
+	}
+	last.entries++
+	return dropped, nil
+}
+
+func (w *WAL) size() int64 {
+	var size int64
+	for _, s := range w.segments {
+		size += s.size
+	}
+	return size
+}
+
+// rotate closes the segment being written and starts the next one.
+func (w *WAL) rotate() error {
+	if err := w.writer.Close(); err != nil {
+		return err
+	}
+	next := &segment{index: w.segments[len(w.segments)-1].index + 1}
+	writer, err := os.OpenFile(w.path(next.index), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	w.writer = writer
+	w.segments = append(w.segments, next)
+	return nil
+}
+
+// removeFirst removes the first segment, which isn't being written, and
+// returns its number of unconsumed entries.
+func (w *WAL) removeFirst() (int, error) {
+	first := w.segments[0]
+	unconsumed := first.entries - w.consumed
+	if err := w.reader.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Remove(w.path(first.index)); err != nil {
+		return 0, err
+	}
+	w.segments = w.segments[1:]
+	reader, err := os.Open(w.path(w.segments[0].index))
+	if err != nil {
+		return 0, err
+	}
+	w.reader = reader
+	w.offset, w.next, w.consumed = 0, 0, 0
+	return unconsumed, w.writeCursor()
+}
+
+// Peek returns the first unconsumed entry and the time it was appended. ok is
+// false if the log is empty. The rest of a segment whose entry is corrupted is
+// skipped after returning the error.
+func (w *WAL) Peek() (data []byte, appended time.Time, ok bool, err error) {
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	for w.offset >= w.segments[0].size {
+		if len(w.segments) == 1 {
+			return nil, time.Time{}, false, nil
+		}
+		if _, err := w.removeFirst(); err != nil {
+			return nil, time.Time{}, false, err
+		}
+	}
+	data, appended, n, err := readEntry(w.reader, w.offset)
+	if err != nil {
+		// The rest of a corrupted segment can't be read, skip it.
+		w.offset = w.segments[0].size
+		w.consumed = w.segments[0].entries
+		return nil, time.Time{}, false, err
+	}
+	w.next = w.offset + n
+	return data, appended, true, nil
+}
+
+// Ack consumes the entry returned by Peek.
+func (w *WAL) Ack() error {
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	if w.next <= w.offset {
+		return nil
+	}
+	w.offset = w.next
+	w.consumed++
+	return w.writeCursor()
+}
+
+// Len returns the number of unconsumed entries.
+func (w *WAL) Len() int {
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	n := -w.consumed
+	for _, s := range w.segments {
+		n += s.entries
+	}
+	return n
+}
+
+// Size returns the size of the segments on disk.
+func (w *WAL) Size() int64 {
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	return w.size()
+}
+
+// Close closes the segments.
+func (w *WAL) Close() error {
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	err := w.writer.Close()
+	if readerErr := w.reader.Close(); err == nil {
+		err = readerErr
+	}
+	return err
+}
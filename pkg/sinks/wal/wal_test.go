@@ -0,0 +1,166 @@
+package wal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func mustOpen(t *testing.T, dir string, options Options) *WAL {
+	w, err := Open(dir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func appendAll(t *testing.T, w *WAL, entries ...string) int {
+	dropped := 0
+	for i, entry := range entries {
+		n, err := w.Append([]byte(entry), now.Add(time.Duration(i)*time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dropped += n
+	}
+	return dropped
+}
+
+// consume peeks and acks n entries.
+func consume(t *testing.T, w *WAL, n int) []string {
+	var result []string
+	for i := 0; i < n; i++ {
+		data, _, ok, err := w.Peek()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			break
+		}
+		result = append(result, string(data))
+		if err := w.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return result
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestWAL(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	options := Options{SegmentSize: 45, MaxSize: 1000}
+
+	w := mustOpen(t, dir, options)
+	appendAll(t, w, "first", "second", "third", "fourth")
+	if w.Len() != 4 {
+		t.Errorf("got %d entries, want 4", w.Len())
+	}
+	data, appended, ok, err := w.Peek()
+	if err != nil || !ok || string(data) != "first" || !appended.Equal(now) {
+		t.Fatalf("got %q appended at %v, %v, %v", data, appended, ok, err)
+	}
+	// Entries are consumed once acked.
+	if got := consume(t, w, 2); !equal(got, []string{"first", "second"}) {
+		t.Errorf("got %v", got)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	segments, _ := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	if len(segments) != 2 {
+		t.Errorf("got %d segments, want 2", len(segments))
+	}
+
+	// The position of the consumer survives restarts.
+	w = mustOpen(t, dir, options)
+	if w.Len() != 2 {
+		t.Errorf("got %d entries after reopening, want 2", w.Len())
+	}
+	appendAll(t, w, "fifth")
+	if got := consume(t, w, 10); !equal(got, []string{"third", "fourth", "fifth"}) {
+		t.Errorf("got %v", got)
+	}
+	if _, _, ok, _ := w.Peek(); ok || w.Len() != 0 {
+		t.Error("log is not empty")
+	}
+	segments, _ = filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	if len(segments) != 1 {
+		t.Errorf("consumed segments were not removed: %v", segments)
+	}
+	w.Close()
+}
+
+func TestWALMaxSize(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// Segments hold two entries of 18 bytes.
+	w := mustOpen(t, dir, Options{SegmentSize: 40, MaxSize: 80})
+	defer w.Close()
+	if dropped := appendAll(t, w, "a1", "a2", "b1", "b2"); dropped != 0 {
+		t.Errorf("dropped %d entries, want 0", dropped)
+	}
+	consume(t, w, 1)
+	if dropped := appendAll(t, w, "c1"); dropped != 1 {
+		t.Errorf("dropped %d entries, want the unconsumed entry of the first segment", dropped)
+	}
+	if w.Size() != 54 {
+		t.Errorf("got size %d, want 54", w.Size())
+	}
+	if got := consume(t, w, 10); !equal(got, []string{"b1", "b2", "c1"}) {
+		t.Errorf("got %v", got)
+	}
+	if _, err := w.Append(make([]byte, 100), now); err != ErrFull {
+		t.Errorf("got error %v, want ErrFull", err)
+	}
+}
+
+func TestWALRecovery(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	options := Options{SegmentSize: 1000, MaxSize: 1000}
+
+	w := mustOpen(t, dir, options)
+	appendAll(t, w, "first", "second")
+	w.Close()
+	// Simulate a crash while the second entry was written.
+	path := filepath.Join(dir, "00000000000000000000"+segmentSuffix)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, info.Size()-2); err != nil {
+		t.Fatal(err)
+	}
+
+	w = mustOpen(t, dir, options)
+	defer w.Close()
+	appendAll(t, w, "third")
+	if got := consume(t, w, 10); !equal(got, []string{"first", "third"}) {
+		t.Errorf("got %v", got)
+	}
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package webhook implements a sink sending every record to an HTTP endpoint.
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/caicloud/event_exporter/pkg/sinks"
+	"github.com/caicloud/event_exporter/pkg/sinks/cloudevents"
+	"github.com/caicloud/event_exporter/pkg/sinks/httpclient"
+)
+
+func init() {
+	sinks.Register("webhook", New)
+}
+
+// Config configures a webhook sink.
+type Config struct {
+	URL string `json:"url"`
+	// Method defaults to POST.
+	Method string `json:"method,omitempty"`
+	// ContentType defaults to application/json.
+	ContentType string `json:"contentType,omitempty"`
+	// Template is a text/template rendering the body from the record. The
+	// record is sent as JSON by default.
+	Template string `json:"template,omitempty"`
+	// CloudEvents sends the records as CloudEvents in structured or binary
+	// mode, with the JSON record as data.
+	CloudEvents string `json:"cloudEvents,omitempty"`
+	httpclient.Config
+}
+
+var funcs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// Sink sends records to a webhook.
+type Sink struct {
+	config   Config
+	client   *httpclient.Client
+	template *template.Template
+	header   http.Header
+	stopCh   <-chan struct{}
+}
+
+// New creates a webhook sink from its raw configuration.
+func New(name string, raw json.RawMessage) (sinks.Sink, error) {
+	var config Config
+	if err := sinks.DecodeConfig(raw, &config); err != nil {
+		return nil, err
+	}
+	if config.URL == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	if config.Method == "" {
+		config.Method = http.MethodPost
+	}
+	if config.ContentType == "" {
+		config.ContentType = "application/json"
+	}
+	if err := cloudevents.ValidateMode(config.CloudEvents); err != nil {
+		return nil, err
+	}
+	if config.CloudEvents != "" && config.Template != "" {
+		return nil, fmt.Errorf("template can't be used with cloudEvents")
+	}
+	client, err := httpclient.New(config.Config)
+	if err != nil {
+		return nil, err
+	}
+	s := &Sink{
+		config: config,
+		client: client,
+		header: http.Header{"Content-Type": []string{config.ContentType}},
+	}
+	if config.Template != "" {
+		s.template, err = template.New(name).Funcs(funcs).Parse(config.Template)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template: %v", err)
+		}
+	}
+	return s, nil
+}
+
+// Start implements sinks.Sink.
+func (s *Sink) Start(stopCh <-chan struct{}) error {
+	s.stopCh = stopCh
+	return nil
+}
+
+// Send implements sinks.Sink.
+func (s *Sink) Send(record *sinks.Record) error {
+	body, err := s.render(record)
+	if err != nil {
+		return httpclient.Permanent(err)
+	}
+	header := s.header
+	if s.config.CloudEvents != "" {
+		if body, header, err = s.cloudEvent(record, body); err != nil {
+			return err
+		}
+	}
+	_, err = s.client.Do(s.stopCh, s.config.Method, s.config.URL, body, header)
+	return err
+}
+
+// cloudEvent wraps the rendered record in a CloudEvent.
+func (s *Sink) cloudEvent(record *sinks.Record, data []byte) ([]byte, http.Header, error) {
+	event := cloudevents.NewEvent(record, s.config.ContentType, data)
+	if s.config.CloudEvents == cloudevents.Binary {
+		header := http.Header{"Content-Type": []string{s.config.ContentType}}
+		for name, value := range event.Attributes() {
+			header.Set("ce-"+name, value)
+		}
+		return data, header, nil
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, http.Header{"Content-Type": []string{cloudevents.StructuredContentType}}, nil
+}
+
+func (s *Sink) render(record *sinks.Record) ([]byte, error) {
+	if s.template == nil {
+		return json.Marshal(record)
+	}
+	var buf bytes.Buffer
+	if err := s.template.Execute(&buf, record); err != nil {
+		return nil, fmt.Errorf("failed to render template: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// SendSync implements sinks.SyncSink, Send posting the record right away.
+func (s *Sink) SendSync(record *sinks.Record) error {
+	return s.Send(record)
+}
+
+// SendGroup implements sinks.GroupSink, posting the record of the group, which
+// lists the records of the group in Group.
+func (s *Sink) SendGroup(group *sinks.Record) error {
+	return s.Send(group)
+}
+
+// Close implements sinks.Sink.
+func (s *Sink) Close() error {
+	return nil
+}
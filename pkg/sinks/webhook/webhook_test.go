@@ -0,0 +1,146 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/event_exporter/pkg/sinks"
+)
+
+func TestSink(t *testing.T) {
+	event := &v1.Event{
+		ObjectMeta:     meta_v1.ObjectMeta{Name: "nginx.1", Namespace: "default"},
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Name: "nginx"},
+		Reason:         "BackOff",
+		Message:        `Back-off "nginx"`,
+		Type:           v1.EventTypeWarning,
+	}
+	tests := []struct {
+		name            string
+		config          string
+		wantMethod      string
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "default body",
+			config:          `{"url": "%s"}`,
+			wantMethod:      http.MethodPost,
+			wantContentType: "application/json",
+		},
+		{
+			name: "template",
+			config: `{"url": "%s", "method": "PUT", "contentType": "text/plain", ` +
+				`"template": "{{upper .Event.Type}} {{.Event.InvolvedObject.Kind}}/{{.Event.InvolvedObject.Name}}: {{json .Event.Message}}"}`,
+			wantMethod:      http.MethodPut,
+			wantContentType: "text/plain",
+			wantBody:        `WARNING Pod/nginx: "Back-off \"nginx\""`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var method, contentType, body string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, _ := ioutil.ReadAll(r.Body)
+				method, contentType, body = r.Method, r.Header.Get("Content-Type"), string(data)
+			}))
+			defer server.Close()
+
+			raw := json.RawMessage(fmt.Sprintf(tt.config, server.URL))
+			sink, err := New("test", raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+			if err := sink.Start(stopCh); err != nil {
+				t.Fatal(err)
+			}
+			record := &sinks.Record{Kind: sinks.Added, Event: event}
+			if err := sink.Send(record); err != nil {
+				t.Fatal(err)
+			}
+
+			if method != tt.wantMethod {
+				t.Errorf("got method %s, want %s", method, tt.wantMethod)
+			}
+			if contentType != tt.wantContentType {
+				t.Errorf("got content type %s, want %s", contentType, tt.wantContentType)
+			}
+			wantBody := tt.wantBody
+			if wantBody == "" {
+				data, _ := json.Marshal(record)
+				wantBody = string(data)
+			}
+			if body != wantBody {
+				t.Errorf("got body %s, want %s", body, wantBody)
+			}
+		})
+	}
+}
+
+func TestSinkCloudEvents(t *testing.T) {
+	event := &v1.Event{
+		ObjectMeta:     meta_v1.ObjectMeta{Name: "nginx.1", Namespace: "default", UID: "event-uid"},
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "nginx"},
+		Reason:         "BackOff",
+		Type:           v1.EventTypeWarning,
+		Source:         v1.EventSource{Component: "kubelet"},
+	}
+	record := &sinks.Record{Kind: sinks.Added, Event: event}
+	data, _ := json.Marshal(record)
+
+	var header http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	for _, mode := range []string{"binary", "structured"} {
+		sink, err := New("test", json.RawMessage(`{"url": "`+server.URL+`", "cloudEvents": "`+mode+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Send(record); err != nil {
+			t.Fatal(err)
+		}
+		if mode == "binary" {
+			if header.Get("Content-Type") != "application/json" || header.Get("Ce-Type") != "io.k8s.event.warning.BackOff" ||
+				header.Get("Ce-Id") != "event-uid-1" || header.Get("Ce-Specversion") != "1.0" || string(body) != string(data) {
+				t.Errorf("unexpected binary request %v %s", header, body)
+			}
+			continue
+		}
+		var envelope map[string]interface{}
+		if err := json.Unmarshal(body, &envelope); err != nil {
+			t.Fatal(err)
+		}
+		if header.Get("Content-Type") != "application/cloudevents+json" || envelope["source"] != "kubelet" ||
+			envelope["subject"] != "Pod/default/nginx" || envelope["data"].(map[string]interface{})["kind"] != "Added" {
+			t.Errorf("unexpected structured request %v %s", header, body)
+		}
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	for _, config := range []string{
+		`{"url": "http://localhost", "cloudEvents": "batched"}`,
+		`{"url": "http://localhost", "cloudEvents": "binary", "template": "{{.Kind}}"}`,
+		`{}`,
+		`{"url": "http://localhost", "template": "{{"}`,
+		`{"url": "http://localhost", "timeuot": "1s"}`,
+	} {
+		if _, err := New("test", json.RawMessage(config)); err == nil {
+			t.Errorf("expected error for config %s", config)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package topn
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/caicloud/event_exporter/pkg/eventutil"
+)
+
+// Dimensions events are ranked by.
+const (
+	ByObject = "object"
+	ByReason = "reason"
+	BySource = "source"
+)
+
+var dimensions = []string{ByObject, ByReason, BySource}
+
+// buckets is the number of buckets every window is divided into.
+const buckets = 60
+
+type bucket struct {
+	// epoch is the index of the bucket since the unix epoch; a bucket of an
+	// older epoch is stale and reset before it is reused.
+	epoch  int64
+	counts map[string]map[string]float64
+}
+
+type window struct {
+	length  time.Duration
+	buckets [buckets]bucket
+}
+
+func (w *window) bucketSize() time.Duration {
+	return w.length / buckets
+}
+
+func (w *window) add(at time.Time, keys map[string]string, occurrences float64) {
+	epoch := at.UnixNano() / int64(w.bucketSize())
+	b := &w.buckets[epoch%buckets]
+	if b.epoch != epoch || b.counts == nil {
+		b.epoch = epoch
+		b.counts = make(map[string]map[string]float64, len(dimensions))
+		for _, dimension := range dimensions {
+			b.counts[dimension] = make(map[string]float64)
+		}
+	}
+	for dimension, key := range keys {
+		if key == "" {
+			continue
+		}
+		b.counts[dimension][key] += occurrences
+	}
+}
+
+func (w *window) top(now time.Time, dimension string, n int) []Entry {
+	current := now.UnixNano() / int64(w.bucketSize())
+	totals := make(map[string]float64)
+	for i := range w.buckets {
+		b := &w.buckets[i]
+		if b.counts == nil || current-b.epoch >= buckets || b.epoch > current {
+			continue
+		}
+		for key, count := range b.counts[dimension] {
+			totals[key] += count
+		}
+	}
+	entries := make([]Entry, 0, len(totals))
+	for key, count := range totals {
+		entries = append(entries, Entry{Key: key, Count: count})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return entries[i].Key < entries[j].Key
+	})
+	if len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+// Entry is a ranked key with the number of events it accounts for.
+type Entry struct {
+	Key   string  `json:"key"`
+	Count float64 `json:"count"`
+}
+
+// Report lists the top keys of a dimension within a window.
+type Report struct {
+	Window    string  `json:"window"`
+	Dimension string  `json:"dimension"`
+	Top       []Entry `json:"top"`
+}
+
+// TopN ranks involved objects, reasons and source components by event volume
+// over sliding windows. It serves the ranking over HTTP and exports it as a
+// metric limited to the top n keys of every window and dimension.
+type TopN struct {
+	n       int
+	windows []*window
+	now     func() time.Time
+	locker  sync.Mutex
+	desc    *prometheus.Desc
+}
+
+// New returns a TopN ranking over the given windows.
+func New(windows []time.Duration, n int) (*TopN, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("top n must be positive, got %d", n)
+	}
+	t := &TopN{
+		n:   n,
+		now: time.Now,
+		desc: prometheus.NewDesc(
+			"kube_event_top_events",
+			"Number of events of the top keys of a dimension within a window",
+			[]string{"window", "dimension", "key"}, nil,
+		),
+	}
+	seen := make(map[time.Duration]bool)
+	for _, length := range windows {
+		if length < buckets*time.Second {
+			return nil, fmt.Errorf("top window must be at least %ds, got %s", buckets, length)
+		}
+		if seen[length] {
+			continue
+		}
+		seen[length] = true
+		t.windows = append(t.windows, &window{length: length})
+	}
+	return t, nil
+}
+
+// Observe implements collector.Observer.
+func (t *TopN) Observe(event *v1.Event, occurrences int32) {
+	if occurrences <= 0 {
+		return
+	}
+	now := t.now()
+	at := eventutil.LastTime(event)
+	if at.After(now) {
+		at = now
+	}
+	keys := map[string]string{
+		ByObject: objectKey(event.InvolvedObject),
+		ByReason: event.Reason,
+		BySource: event.Source.Component,
+	}
+	t.locker.Lock()
+	defer t.locker.Unlock()
+	for _, w := range t.windows {
+		if now.Sub(at) < w.length {
+			w.add(at, keys, float64(occurrences))
+		}
+	}
+}
+
+// Reports returns the top n keys of every dimension within every window.
+func (t *TopN) Reports(n int) []Report {
+	now := t.now()
+	t.locker.Lock()
+	defer t.locker.Unlock()
+	var reports []Report
+	for _, w := range t.windows {
+		for _, dimension := range dimensions {
+			reports = append(reports, Report{
+				Window:    shortDuration(w.length),
+				Dimension: dimension,
+				Top:       w.top(now, dimension, n),
+			})
+		}
+	}
+	return reports
+}
+
+// ServeHTTP serves the reports as JSON. They can be narrowed down with the
+// window and dimension query parameters, and n overrides the number of keys.
+func (t *TopN) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	n := t.n
+	if value := query.Get("n"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			http.Error(w, fmt.Sprintf("invalid n %q", value), http.StatusBadRequest)
+			return
+		}
+		n = parsed
+	}
+	reports := []Report{}
+	for _, report := range t.Reports(n) {
+		if window := query.Get("window"); window != "" && !sameWindow(window, report.Window) {
+			continue
+		}
+		if dimension := query.Get("dimension"); dimension != "" && dimension != report.Dimension {
+			continue
+		}
+		reports = append(reports, report)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(reports); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (t *TopN) Describe(ch chan<- *prometheus.Desc) {
+	ch <- t.desc
+}
+
+// Collect implements prometheus.Collector.
+func (t *TopN) Collect(ch chan<- prometheus.Metric) {
+	for _, report := range t.Reports(t.n) {
+		for _, entry := range report.Top {
+			ch <- prometheus.MustNewConstMetric(t.desc, prometheus.GaugeValue, entry.Count,
+				report.Window, report.Dimension, entry.Key)
+		}
+	}
+}
+
+func sameWindow(query, window string) bool {
+	d, err := time.ParseDuration(query)
+	return err == nil && shortDuration(d) == window
+}
+
+// shortDuration formats durations like 24h rather than 24h0m0s.
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
+func objectKey(object v1.ObjectReference) string {
+	if object.Namespace == "" {
+		return object.Kind + "/" + object.Name
+	}
+	return object.Kind + "/" + object.Namespace + "/" + object.Name
+}
@@ -0,0 +1,82 @@
+package topn
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/caicloud/event_exporter/pkg/utils"
+)
+
+func newEvent(name, reason, component string, last time.Time) *v1.Event {
+	return &v1.Event{
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: name},
+		Reason:         reason,
+		Source:         v1.EventSource{Component: component},
+		LastTimestamp:  meta_v1.NewTime(last),
+	}
+}
+
+func TestTopN(t *testing.T) {
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	top, err := New([]time.Duration{5 * time.Minute, time.Hour}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	top.now = func() time.Time { return now }
+
+	top.Observe(newEvent("nginx-0", "BackOff", "kubelet", now), 10)
+	top.Observe(newEvent("nginx-1", "BackOff", "kubelet", now.Add(-time.Minute)), 5)
+	top.Observe(newEvent("nginx-1", "Unhealthy", "kubelet", now.Add(-30*time.Minute)), 20)
+	top.Observe(newEvent("etcd-0", "FailedMount", "kubelet", now.Add(-2*time.Hour)), 100)
+	top.Observe(newEvent("etcd-0", "FailedScheduling", "default-scheduler", now), 1)
+
+	reports := top.Reports(2)
+	want := []Report{
+		{Window: "5m", Dimension: ByObject, Top: []Entry{{Key: "Pod/default/nginx-0", Count: 10}, {Key: "Pod/default/nginx-1", Count: 5}}},
+		{Window: "5m", Dimension: ByReason, Top: []Entry{{Key: "BackOff", Count: 15}, {Key: "FailedScheduling", Count: 1}}},
+		{Window: "5m", Dimension: BySource, Top: []Entry{{Key: "kubelet", Count: 15}, {Key: "default-scheduler", Count: 1}}},
+		{Window: "1h", Dimension: ByObject, Top: []Entry{{Key: "Pod/default/nginx-1", Count: 25}, {Key: "Pod/default/nginx-0", Count: 10}}},
+		{Window: "1h", Dimension: ByReason, Top: []Entry{{Key: "Unhealthy", Count: 20}, {Key: "BackOff", Count: 15}}},
+		{Window: "1h", Dimension: BySource, Top: []Entry{{Key: "kubelet", Count: 35}, {Key: "default-scheduler", Count: 1}}},
+	}
+	if !reflect.DeepEqual(reports, want) {
+		t.Errorf("Reports() = %v, want %v", reports, want)
+	}
+
+	recorder := httptest.NewRecorder()
+	top.ServeHTTP(recorder, httptest.NewRequest("GET", "/top?window=60m&dimension=reason&n=1", nil))
+	var served []Report
+	if err := json.NewDecoder(recorder.Body).Decode(&served); err != nil {
+		t.Fatal(err)
+	}
+	wantServed := []Report{{Window: "1h", Dimension: ByReason, Top: []Entry{{Key: "Unhealthy", Count: 20}}}}
+	if !reflect.DeepEqual(served, wantServed) {
+		t.Errorf("ServeHTTP() = %v, want %v", served, wantServed)
+	}
+
+	one, err := New([]time.Duration{5 * time.Minute}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	one.now = top.now
+	one.Observe(newEvent("nginx-0", "BackOff", "kubelet", now), 10)
+	var testcases utils.MetricsTestCases = map[string]utils.MetricsTestCase{
+		"TopEvents": {
+			Target: one,
+			Want: `
+# HELP kube_event_top_events Number of events of the top keys of a dimension within a window
+# TYPE kube_event_top_events gauge
+kube_event_top_events{dimension="object",key="Pod/default/nginx-0",window="5m"} 10
+kube_event_top_events{dimension="reason",key="BackOff",window="5m"} 10
+kube_event_top_events{dimension="source",key="kubelet",window="5m"} 10
+`,
+		},
+	}
+	testcases.Test(t)
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2020 CaiCloud, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workload
+
+import (
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	appsV1 "k8s.io/client-go/listers/apps/v1"
+	coreV1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// deletedPodTTL is how long the workload and node of deleted pods are
+// remembered.
+const deletedPodTTL = 10 * time.Minute
+
+// Reference identifies the workload that owns an involved object.
+type Reference struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// deletedPod is what is remembered of a deleted pod.
+type deletedPod struct {
+	workload Reference
+	node     string
+	deleted  time.Time
+}
+
+// Resolver resolves the top level workload of event involved objects, e.g. the
+// Deployment of a Pod that is owned by one of its ReplicaSets.
+type Resolver struct {
+	podLister        coreV1.PodLister
+	replicaSetLister appsV1.ReplicaSetLister
+	synced           []cache.InformerSynced
+	now              func() time.Time
+
+	locker sync.Mutex
+	// deleted are the recently deleted pods by namespace and name, as the
+	// events of evicted or preempted pods often arrive after their deletion.
+	deleted map[string]deletedPod
+}
+
+// NewResolver returns a resolver backed by pod and replica set informers of
+// the given factory. It must be called before the factory is started.
+func NewResolver(factory informers.SharedInformerFactory) *Resolver {
+	pods := factory.Core().V1().Pods()
+	replicaSets := factory.Apps().V1().ReplicaSets()
+	r := &Resolver{
+		podLister:        pods.Lister(),
+		replicaSetLister: replicaSets.Lister(),
+		synced:           []cache.InformerSynced{pods.Informer().HasSynced, replicaSets.Informer().HasSynced},
+		now:              time.Now,
+		deleted:          make(map[string]deletedPod),
+	}
+	pods.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: r.podDeleted,
+	})
+	return r
+}
+
+// HasSynced reports whether the pod and replica set informers have synced.
+// Until they have, pods resolve to themselves.
+func (r *Resolver) HasSynced() bool {
+	for _, synced := range r.synced {
+		if !synced() {
+			return false
+		}
+	}
+	return true
+}
+
+// Resolve returns the workload that owns the object. Objects that are not
+// pods, or pods that are unknown or were deleted for a while, resolve to
+// themselves.
+func (r *Resolver) Resolve(object v1.ObjectReference) Reference {
+	if pod := r.pod(object); pod != nil {
+		return r.owner(pod)
+	}
+	if d, ok := r.deletedPod(object); ok {
+		return d.workload
+	}
+	return Reference{Kind: object.Kind, Namespace: object.Namespace, Name: object.Name}
+}
+
+func (r *Resolver) owner(pod *v1.Pod) Reference {
+	self := Reference{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name}
+	owner := meta_v1.GetControllerOf(pod)
+	if owner == nil {
+		return self
+	}
+	ref := Reference{Kind: owner.Kind, Namespace: pod.Namespace, Name: owner.Name}
+	if owner.Kind != "ReplicaSet" {
+		return ref
+	}
+	rs, err := r.replicaSetLister.ReplicaSets(pod.Namespace).Get(owner.Name)
+	if err != nil {
+		return ref
+	}
+	if owner := meta_v1.GetControllerOf(rs); owner != nil {
+		return Reference{Kind: owner.Kind, Namespace: rs.Namespace, Name: owner.Name}
+	}
+	return ref
+}
+
+// NodeName returns the node the object is bound to: the node itself for Node
+// objects and the node a pod is scheduled on for pods.
+func (r *Resolver) NodeName(object v1.ObjectReference) string {
+	if object.Kind == "Node" {
+		return object.Name
+	}
+	if pod := r.pod(object); pod != nil {
+		return pod.Spec.NodeName
+	}
+	if d, ok := r.deletedPod(object); ok {
+		return d.node
+	}
+	return ""
+}
+
+func (r *Resolver) pod(object v1.ObjectReference) *v1.Pod {
+	if object.Kind != "Pod" {
+		return nil
+	}
+	pod, err := r.podLister.Pods(object.Namespace).Get(object.Name)
+	if err != nil {
+		return nil
+	}
+	return pod
+}
+
+// podDeleted remembers the workload and node of a deleted pod, and forgets
+// the pods deleted for longer than deletedPodTTL.
+func (r *Resolver) podDeleted(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		return
+	}
+	d := deletedPod{workload: r.owner(pod), node: pod.Spec.NodeName, deleted: r.now()}
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	for key, old := range r.deleted {
+		if d.deleted.Sub(old.deleted) > deletedPodTTL {
+			delete(r.deleted, key)
+		}
+	}
+	r.deleted[pod.Namespace+"/"+pod.Name] = d
+}
+
+func (r *Resolver) deletedPod(object v1.ObjectReference) (deletedPod, bool) {
+	if object.Kind != "Pod" {
+		return deletedPod{}, false
+	}
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	d, ok := r.deleted[object.Namespace+"/"+object.Name]
+	if !ok || r.now().Sub(d.deleted) > deletedPodTTL {
+		return deletedPod{}, false
+	}
+	return d, true
+}
@@ -0,0 +1,114 @@
+package workload
+
+import (
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+func controllerRef(kind, name string) []meta_v1.OwnerReference {
+	controller := true
+	return []meta_v1.OwnerReference{{Kind: kind, Name: name, Controller: &controller}}
+}
+
+func TestResolver(t *testing.T) {
+	factory := informers.NewSharedInformerFactory(nil, 0)
+	resolver := NewResolver(factory)
+	pods := factory.Core().V1().Pods().Informer().GetIndexer()
+	replicaSets := factory.Apps().V1().ReplicaSets().Informer().GetIndexer()
+
+	_ = pods.Add(&v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{Namespace: "default", Name: "nginx-5d8f4b6c7-abcde", OwnerReferences: controllerRef("ReplicaSet", "nginx-5d8f4b6c7")},
+		Spec:       v1.PodSpec{NodeName: "node-1"},
+	})
+	_ = pods.Add(&v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{Namespace: "default", Name: "etcd-0", OwnerReferences: controllerRef("StatefulSet", "etcd")},
+	})
+	_ = pods.Add(&v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{Namespace: "default", Name: "standalone"},
+	})
+	_ = replicaSets.Add(&appsv1.ReplicaSet{
+		ObjectMeta: meta_v1.ObjectMeta{Namespace: "default", Name: "nginx-5d8f4b6c7", OwnerReferences: controllerRef("Deployment", "nginx")},
+	})
+
+	tests := []struct {
+		name   string
+		object v1.ObjectReference
+		want   Reference
+	}{
+		{
+			name:   "deployment pod",
+			object: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "nginx-5d8f4b6c7-abcde"},
+			want:   Reference{Kind: "Deployment", Namespace: "default", Name: "nginx"},
+		},
+		{
+			name:   "statefulset pod",
+			object: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "etcd-0"},
+			want:   Reference{Kind: "StatefulSet", Namespace: "default", Name: "etcd"},
+		},
+		{
+			name:   "pod without controller",
+			object: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "standalone"},
+			want:   Reference{Kind: "Pod", Namespace: "default", Name: "standalone"},
+		},
+		{
+			name:   "deleted pod",
+			object: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "gone"},
+			want:   Reference{Kind: "Pod", Namespace: "default", Name: "gone"},
+		},
+		{
+			name:   "node",
+			object: v1.ObjectReference{Kind: "Node", Name: "node-1"},
+			want:   Reference{Kind: "Node", Name: "node-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolver.Resolve(tt.object); got != tt.want {
+				t.Errorf("Resolve() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := resolver.NodeName(v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "nginx-5d8f4b6c7-abcde"}); got != "node-1" {
+		t.Errorf("NodeName() = %q, want node-1", got)
+	}
+}
+
+func TestResolverDeletedPods(t *testing.T) {
+	factory := informers.NewSharedInformerFactory(nil, 0)
+	resolver := NewResolver(factory)
+	if resolver.HasSynced() {
+		t.Error("HasSynced() = true before the informers were started")
+	}
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	resolver.now = func() time.Time { return now }
+
+	pod := &v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{Namespace: "default", Name: "etcd-0", OwnerReferences: controllerRef("StatefulSet", "etcd")},
+		Spec:       v1.PodSpec{NodeName: "node-1"},
+	}
+	resolver.podDeleted(cache.DeletedFinalStateUnknown{Key: "default/etcd-0", Obj: pod})
+	object := v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "etcd-0"}
+	if got, want := resolver.Resolve(object), (Reference{Kind: "StatefulSet", Namespace: "default", Name: "etcd"}); got != want {
+		t.Errorf("Resolve() = %v, want %v", got, want)
+	}
+	if got := resolver.NodeName(object); got != "node-1" {
+		t.Errorf("NodeName() = %q, want node-1", got)
+	}
+
+	// Deleted pods are forgotten after a while.
+	now = now.Add(deletedPodTTL + time.Second)
+	if got, want := resolver.Resolve(object), (Reference{Kind: "Pod", Namespace: "default", Name: "etcd-0"}); got != want {
+		t.Errorf("Resolve() = %v, want %v", got, want)
+	}
+	resolver.podDeleted(&v1.Pod{ObjectMeta: meta_v1.ObjectMeta{Namespace: "default", Name: "other"}})
+	if len(resolver.deleted) != 1 {
+		t.Errorf("got %d deleted pods, want 1", len(resolver.deleted))
+	}
+}